@@ -1,12 +1,19 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/sflewis2970/datastore-service/config"
 	"github.com/sflewis2970/datastore-service/controllers"
 	"github.com/sflewis2970/datastore-service/router"
+	"github.com/sflewis2970/datastore-service/tracing"
 )
 
 func main() {
@@ -19,18 +26,69 @@ func main() {
 		log.Fatal("Error getting config data: ", cfgDataErr)
 	}
 
+	// Export the tracing spans
+	shutdownTracing, tracingErr := tracing.Setup(cfgData.Tracing)
+	if tracingErr != nil {
+		log.Fatal("Error setting up tracing: ", tracingErr)
+	}
+
 	// Initialize controller
 	controllers.New()
 
 	// Create App
 	msgRouter := router.New()
 
-	// Start Server
-	log.Print("Datastore service is ready...")
-
+	// Create Server
 	log.Print("Host: ", cfgData.Host)
 	log.Print("Port: ", cfgData.Port)
 	addr := cfgData.Host + ":" + cfgData.Port
 	log.Print("The address used the service is: ", addr)
-	log.Fatal(http.ListenAndServe(addr, msgRouter.MuxRouter))
+
+	server := &http.Server{
+		Addr:         addr,
+		Handler:      msgRouter.Handler,
+		ReadTimeout:  time.Duration(cfgData.Server.ReadTimeout) * time.Second,
+		WriteTimeout: time.Duration(cfgData.Server.WriteTimeout) * time.Second,
+		IdleTimeout:  time.Duration(cfgData.Server.IdleTimeout) * time.Second,
+	}
+
+	// On SIGINT or SIGTERM stop accepting requests, let the requests in flight finish and only
+	// then close the datastore connections
+	stopped := make(chan struct{})
+	go func() {
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+		sig := <-signals
+		log.Print("Shutting down, signal: ", sig)
+		controllers.Drain()
+
+		drainCtx, cancel := context.WithTimeout(context.Background(), time.Duration(cfgData.Server.DrainTimeout)*time.Second)
+		defer cancel()
+
+		shutdownErr := server.Shutdown(drainCtx)
+		if shutdownErr != nil {
+			log.Print("Error draining requests: ", shutdownErr)
+		}
+
+		controllers.Close()
+
+		// Flush the spans of the last requests
+		tracingErr := shutdownTracing(context.Background())
+		if tracingErr != nil {
+			log.Print("Error flushing tracing spans: ", tracingErr)
+		}
+
+		close(stopped)
+	}()
+
+	// Start Server
+	log.Print("Datastore service is ready...")
+	serveErr := server.ListenAndServe()
+	if !errors.Is(serveErr, http.ErrServerClosed) {
+		log.Fatal(serveErr)
+	}
+
+	<-stopped
+	log.Print("Datastore service stopped")
 }
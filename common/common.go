@@ -2,8 +2,11 @@ package common
 
 import (
 	"log"
+	"net/http"
 	"os"
 	"time"
+
+	"github.com/gorilla/mux"
 )
 
 // Build formatted time string
@@ -21,3 +24,34 @@ func GetWorkingDir() (string, error) {
 
 	return workingDir, nil
 }
+
+// Route label of the requests that matched no route
+const UNMATCHED_ROUTE string = "unmatched"
+
+// Get the template of the route the router matches the request to, so the question IDs in the
+// paths are not told apart
+func MatchRouteTemplate(router *mux.Router, r *http.Request) string {
+	var routeMatch mux.RouteMatch
+	if router.Match(r, &routeMatch) && routeMatch.Route != nil {
+		if pathTemplate, templateErr := routeMatch.Route.GetPathTemplate(); templateErr == nil {
+			return pathTemplate
+		}
+	}
+
+	return UNMATCHED_ROUTE
+}
+
+// StatusRecorder records the status code written by a handler
+type StatusRecorder struct {
+	http.ResponseWriter
+	Status int
+}
+
+func NewStatusRecorder(rw http.ResponseWriter) *StatusRecorder {
+	return &StatusRecorder{ResponseWriter: rw, Status: http.StatusOK}
+}
+
+func (sr *StatusRecorder) WriteHeader(status int) {
+	sr.Status = status
+	sr.ResponseWriter.WriteHeader(status)
+}
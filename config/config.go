@@ -21,6 +21,8 @@ const (
 	GOCACHE_DRIVER    string = "gocache"
 	REDIS_DRIVER      string = "redis"
 	POSTGRESQL_DRIVER string = "postgres"
+	MYSQL_DRIVER      string = "mysql"
+	SQLITE_DRIVER     string = "sqlite"
 )
 
 // Config variable keys
@@ -31,7 +33,7 @@ const (
 	// System environment
 	ENV string = "ENV"
 
-	// The choices for activedriver are: "go-cache", "redis", "postgres"
+	// The choices for activedriver are: "go-cache", "redis", "postgres", "mysql", "sqlite"
 	ACTIVEDRIVER string = "ACTIVEDRIVER"
 
 	DEFAULT_EXPIRATION string = "expiration"
@@ -44,6 +46,52 @@ const (
 	POSTGRES_HOST      string = "postgres_host"
 	POSTGRES_PORT      string = "postgres_port"
 	POSTGRES_USER      string = "postgres_user"
+
+	// PostgreSQL connection pool settings, the lifetimes are in seconds
+	POSTGRES_MAX_OPEN_CONNS     string = "postgres_max_open_conns"
+	POSTGRES_MAX_IDLE_CONNS     string = "postgres_max_idle_conns"
+	POSTGRES_CONN_MAX_LIFETIME  string = "postgres_conn_max_lifetime"
+	POSTGRES_CONN_MAX_IDLE_TIME string = "postgres_conn_max_idle_time"
+	SQLITE_PATH                 string = "sqlite_path"
+
+	// Answer verdict messages
+	CONGRATS_MESSAGE  string = "CONGRATS_MESSAGE"
+	TRY_AGAIN_MESSAGE string = "TRY_AGAIN_MESSAGE"
+
+	// Answer matcher settings
+	MATCHER_MODE             string = "MATCHER_MODE"
+	MATCHER_MAX_DISTANCE     string = "MATCHER_MAX_DISTANCE"
+	MATCHER_MIN_FUZZY_LENGTH string = "MATCHER_MIN_FUZZY_LENGTH"
+
+	// Seconds between two removals of the expired questions, a negative interval disables the reaper
+	REAPER_INTERVAL string = "REAPER_INTERVAL"
+
+	// Seconds a checked out question stays leased before it returns to the pool
+	LEASE_DURATION string = "LEASE_DURATION"
+
+	// Comma-separated categories a question can be stored in, any category is allowed when empty
+	CATEGORIES string = "CATEGORIES"
+
+	// Milliseconds a datastore operation may take, batch inserts and the reaper get their own limit
+	OPERATION_TIMEOUT_MS string = "OPERATION_TIMEOUT_MS"
+	BATCH_TIMEOUT_MS     string = "BATCH_TIMEOUT_MS"
+
+	// HTTP server timeouts in seconds, requests in flight at shutdown are given the drain timeout to finish
+	SERVER_READ_TIMEOUT  string = "SERVER_READ_TIMEOUT"
+	SERVER_WRITE_TIMEOUT string = "SERVER_WRITE_TIMEOUT"
+	SERVER_IDLE_TIMEOUT  string = "SERVER_IDLE_TIMEOUT"
+	SERVER_DRAIN_TIMEOUT string = "SERVER_DRAIN_TIMEOUT"
+
+	// Seconds the datastore readiness check is cached, and the failed checks in a row before the
+	// service is reported not ready
+	SERVER_READY_CHECK_INTERVAL    string = "SERVER_READY_CHECK_INTERVAL"
+	SERVER_READY_FAILURE_THRESHOLD string = "SERVER_READY_FAILURE_THRESHOLD"
+
+	// Span exporter ("none", "stdout" or "file"), the file written by the file exporter and the
+	// ratio of the traces started by the service that are sampled
+	TRACE_EXPORTER     string = "TRACE_EXPORTER"
+	TRACE_FILE         string = "TRACE_FILE"
+	TRACE_SAMPLE_RATIO string = "TRACE_SAMPLE_RATIO"
 )
 
 // Config variable values
@@ -51,6 +99,67 @@ const (
 	PRODUCTION string = "PROD"
 )
 
+// Default answer verdict messages
+const (
+	DEFAULT_CONGRATS_MESSAGE  string = "Congrats! That is correct"
+	DEFAULT_TRY_AGAIN_MESSAGE string = "Nice try! Better luck on the next question"
+)
+
+const DEFAULT_REAPER_INTERVAL int = 60
+
+const DEFAULT_LEASE_DURATION int = 30
+
+// Default datastore operation timeouts, in milliseconds
+const (
+	DEFAULT_OPERATION_TIMEOUT_MS int = 5000
+	DEFAULT_BATCH_TIMEOUT_MS     int = 30000
+)
+
+// Default PostgreSQL connection pool settings
+const (
+	DEFAULT_POSTGRES_MAX_OPEN_CONNS     int = 20
+	DEFAULT_POSTGRES_MAX_IDLE_CONNS     int = 10
+	DEFAULT_POSTGRES_CONN_MAX_LIFETIME  int = 1800
+	DEFAULT_POSTGRES_CONN_MAX_IDLE_TIME int = 300
+)
+
+// Default HTTP server timeouts, in seconds
+const (
+	DEFAULT_SERVER_READ_TIMEOUT  int = 15
+	DEFAULT_SERVER_WRITE_TIMEOUT int = 60
+	DEFAULT_SERVER_IDLE_TIMEOUT  int = 120
+	DEFAULT_SERVER_DRAIN_TIMEOUT int = 30
+)
+
+// Default readiness check settings
+const (
+	DEFAULT_SERVER_READY_CHECK_INTERVAL    int = 5
+	DEFAULT_SERVER_READY_FAILURE_THRESHOLD int = 3
+)
+
+// Default tracing settings, spans are not exported unless an exporter is set
+const (
+	DEFAULT_TRACE_EXPORTER     string  = "none"
+	DEFAULT_TRACE_FILE         string  = "./traces.json"
+	DEFAULT_TRACE_SAMPLE_RATIO float64 = 1
+)
+
+type Server struct {
+	ReadTimeout  int `json:"readtimeout"`
+	WriteTimeout int `json:"writetimeout"`
+	IdleTimeout  int `json:"idletimeout"`
+	DrainTimeout int `json:"draintimeout"`
+
+	ReadyCheckInterval    int `json:"readycheckinterval"`
+	ReadyFailureThreshold int `json:"readyfailurethreshold"`
+}
+
+type Tracing struct {
+	Exporter    string  `json:"exporter"`
+	File        string  `json:"file"`
+	SampleRatio float64 `json:"sampleratio"`
+}
+
 type GoCache struct {
 	DefaultExpiration int `json:"expiration"`
 	CleanupInterval   int `json:"cleanup"`
@@ -63,20 +172,54 @@ type Redis struct {
 	Password string `json:"password"`
 }
 
+type MySQL struct {
+	Connection string `json:"connection"`
+}
+
+type SQLite struct {
+	Path string `json:"path"`
+}
+
 type PostGreSQL struct {
-	Host string `json:"host"`
-	Port int    `json:"port"`
-	User string `json:"user"`
+	Host            string `json:"host"`
+	Port            int    `json:"port"`
+	User            string `json:"user"`
+	MaxOpenConns    int    `json:"maxopenconns"`
+	MaxIdleConns    int    `json:"maxidleconns"`
+	ConnMaxLifetime int    `json:"connmaxlifetime"`
+	ConnMaxIdleTime int    `json:"connmaxidletime"`
+}
+
+type Messages struct {
+	Congrats string `json:"Congrats"`
+	TryAgain string `json:"TryAgain"`
+}
+
+type Matcher struct {
+	Mode           string `json:"mode"`
+	MaxDistance    int    `json:"maxdistance"`
+	MinFuzzyLength int    `json:"minfuzzylength"`
 }
 
 type ConfigData struct {
-	Host         string `json:"host"`
-	Port         string `json:"port"`
-	Env          string `json:"env"`
-	ActiveDriver string `json:"active"`
-	GoCache      GoCache
-	Redis        Redis
-	PostGreSQL   PostGreSQL
+	Host               string   `json:"host"`
+	Port               string   `json:"port"`
+	Env                string   `json:"env"`
+	ActiveDriver       string   `json:"active"`
+	ReaperInterval     int      `json:"reaperinterval"`
+	LeaseDuration      int      `json:"leaseduration"`
+	OperationTimeoutMS int      `json:"operationtimeoutms"`
+	BatchTimeoutMS     int      `json:"batchtimeoutms"`
+	Categories         []string `json:"categories"`
+	Server             Server
+	Tracing            Tracing
+	GoCache            GoCache
+	Redis              Redis
+	MySQL              MySQL
+	PostGreSQL         PostGreSQL
+	SQLite             SQLite
+	Messages           Messages
+	Matcher            Matcher
 }
 
 type config struct {
@@ -148,6 +291,125 @@ func (c *config) getConfigEnv() error {
 	c.cfgData.ActiveDriver = os.Getenv(ACTIVEDRIVER)
 	c.cfgData.Env = os.Getenv(ENV)
 
+	// Answer verdict messages
+	c.cfgData.Messages.Congrats = os.Getenv(CONGRATS_MESSAGE)
+	c.cfgData.Messages.TryAgain = os.Getenv(TRY_AGAIN_MESSAGE)
+
+	// Expired question reaper
+	strVal := os.Getenv(REAPER_INTERVAL)
+	if len(strVal) > 0 {
+		value, convErr := strconv.Atoi(strVal)
+		if convErr != nil {
+			log.Print("Error converting string to int...")
+			return convErr
+		}
+		c.cfgData.ReaperInterval = value
+	}
+
+	// Question checkout lease
+	strVal = os.Getenv(LEASE_DURATION)
+	if len(strVal) > 0 {
+		value, convErr := strconv.Atoi(strVal)
+		if convErr != nil {
+			log.Print("Error converting string to int...")
+			return convErr
+		}
+		c.cfgData.LeaseDuration = value
+	}
+
+	// Datastore operation timeouts
+	strVal = os.Getenv(OPERATION_TIMEOUT_MS)
+	if len(strVal) > 0 {
+		value, convErr := strconv.Atoi(strVal)
+		if convErr != nil {
+			log.Print("Error converting string to int...")
+			return convErr
+		}
+		c.cfgData.OperationTimeoutMS = value
+	}
+
+	strVal = os.Getenv(BATCH_TIMEOUT_MS)
+	if len(strVal) > 0 {
+		value, convErr := strconv.Atoi(strVal)
+		if convErr != nil {
+			log.Print("Error converting string to int...")
+			return convErr
+		}
+		c.cfgData.BatchTimeoutMS = value
+	}
+
+	// HTTP server timeouts
+	serverSettings := []struct {
+		envName string
+		value   *int
+	}{
+		{SERVER_READ_TIMEOUT, &c.cfgData.Server.ReadTimeout},
+		{SERVER_WRITE_TIMEOUT, &c.cfgData.Server.WriteTimeout},
+		{SERVER_IDLE_TIMEOUT, &c.cfgData.Server.IdleTimeout},
+		{SERVER_DRAIN_TIMEOUT, &c.cfgData.Server.DrainTimeout},
+		{SERVER_READY_CHECK_INTERVAL, &c.cfgData.Server.ReadyCheckInterval},
+		{SERVER_READY_FAILURE_THRESHOLD, &c.cfgData.Server.ReadyFailureThreshold},
+	}
+
+	for _, serverSetting := range serverSettings {
+		strVal := os.Getenv(serverSetting.envName)
+		if len(strVal) > 0 {
+			value, convErr := strconv.Atoi(strVal)
+			if convErr != nil {
+				log.Print("Error converting string to int...")
+				return convErr
+			}
+			*serverSetting.value = value
+		}
+	}
+
+	// Tracing settings
+	c.cfgData.Tracing.Exporter = os.Getenv(TRACE_EXPORTER)
+	c.cfgData.Tracing.File = os.Getenv(TRACE_FILE)
+	strVal = os.Getenv(TRACE_SAMPLE_RATIO)
+	if len(strVal) > 0 {
+		value, convErr := strconv.ParseFloat(strVal, 64)
+		if convErr != nil {
+			log.Print("Error converting string to float...")
+			return convErr
+		}
+		c.cfgData.Tracing.SampleRatio = value
+	}
+
+	// Allowed question categories
+	c.cfgData.Categories = nil
+	strVal = os.Getenv(CATEGORIES)
+	if len(strVal) > 0 {
+		for _, category := range strings.Split(strVal, ",") {
+			category = strings.TrimSpace(category)
+			if len(category) > 0 {
+				c.cfgData.Categories = append(c.cfgData.Categories, category)
+			}
+		}
+	}
+
+	// Answer matcher settings
+	c.cfgData.Matcher.Mode = os.Getenv(MATCHER_MODE)
+	strVal = os.Getenv(MATCHER_MAX_DISTANCE)
+	if len(strVal) > 0 {
+		value, convErr := strconv.Atoi(strVal)
+		if convErr != nil {
+			log.Print("Error converting string to int...")
+			return convErr
+		}
+		c.cfgData.Matcher.MaxDistance = value
+	}
+
+	strVal = os.Getenv(MATCHER_MIN_FUZZY_LENGTH)
+	if len(strVal) > 0 {
+		value, convErr := strconv.Atoi(strVal)
+		if convErr != nil {
+			log.Print("Error converting string to int...")
+			return convErr
+		}
+		c.cfgData.Matcher.MinFuzzyLength = value
+	}
+
 	switch c.cfgData.ActiveDriver {
 	case GOCACHE_DRIVER:
 		// Go-cache settings
@@ -223,6 +485,40 @@ func (c *config) getConfigEnv() error {
 			c.cfgData.PostGreSQL.Port = value
 		}
 		c.cfgData.PostGreSQL.User = os.Getenv(POSTGRES_USER)
+
+		// Connection pool settings
+		poolSettings := []struct {
+			envName string
+			value   *int
+		}{
+			{POSTGRES_MAX_OPEN_CONNS, &c.cfgData.PostGreSQL.MaxOpenConns},
+			{POSTGRES_MAX_IDLE_CONNS, &c.cfgData.PostGreSQL.MaxIdleConns},
+			{POSTGRES_CONN_MAX_LIFETIME, &c.cfgData.PostGreSQL.ConnMaxLifetime},
+			{POSTGRES_CONN_MAX_IDLE_TIME, &c.cfgData.PostGreSQL.ConnMaxIdleTime},
+		}
+
+		for _, poolSetting := range poolSettings {
+			strVal := os.Getenv(poolSetting.envName)
+			if len(strVal) > 0 {
+				value, convErr := strconv.Atoi(strVal)
+				if convErr != nil {
+					log.Print("Error converting string to int...")
+					return convErr
+				}
+				*poolSetting.value = value
+			}
+		}
+
+	case MYSQL_DRIVER:
+		// MySQL settings
+		log.Print("Setting mysql environment variables...")
+		c.cfgData.MySQL.Connection = os.Getenv(MYSQL_CONNECTION)
+
+	case SQLITE_DRIVER:
+		// SQLite settings
+		log.Print("Setting sqlite environment variables...")
+		c.cfgData.SQLite.Path = os.Getenv(SQLITE_PATH)
+
 	default:
 		log.Print("Could not find supported driver...")
 		log.Print("no database environment variables set...")
@@ -231,6 +527,85 @@ func (c *config) getConfigEnv() error {
 	return nil
 }
 
+// Fill in the settings that were not provided by the config file or environment
+func (c *config) setDefaults() {
+	if len(c.cfgData.Messages.Congrats) == 0 {
+		c.cfgData.Messages.Congrats = DEFAULT_CONGRATS_MESSAGE
+	}
+
+	if len(c.cfgData.Messages.TryAgain) == 0 {
+		c.cfgData.Messages.TryAgain = DEFAULT_TRY_AGAIN_MESSAGE
+	}
+
+	if c.cfgData.ReaperInterval == 0 {
+		c.cfgData.ReaperInterval = DEFAULT_REAPER_INTERVAL
+	}
+
+	if c.cfgData.LeaseDuration <= 0 {
+		c.cfgData.LeaseDuration = DEFAULT_LEASE_DURATION
+	}
+
+	if c.cfgData.OperationTimeoutMS <= 0 {
+		c.cfgData.OperationTimeoutMS = DEFAULT_OPERATION_TIMEOUT_MS
+	}
+
+	if c.cfgData.BatchTimeoutMS <= 0 {
+		c.cfgData.BatchTimeoutMS = DEFAULT_BATCH_TIMEOUT_MS
+	}
+
+	if c.cfgData.Server.ReadTimeout <= 0 {
+		c.cfgData.Server.ReadTimeout = DEFAULT_SERVER_READ_TIMEOUT
+	}
+
+	if c.cfgData.Server.WriteTimeout <= 0 {
+		c.cfgData.Server.WriteTimeout = DEFAULT_SERVER_WRITE_TIMEOUT
+	}
+
+	if c.cfgData.Server.IdleTimeout <= 0 {
+		c.cfgData.Server.IdleTimeout = DEFAULT_SERVER_IDLE_TIMEOUT
+	}
+
+	if c.cfgData.Server.DrainTimeout <= 0 {
+		c.cfgData.Server.DrainTimeout = DEFAULT_SERVER_DRAIN_TIMEOUT
+	}
+
+	if c.cfgData.Server.ReadyCheckInterval <= 0 {
+		c.cfgData.Server.ReadyCheckInterval = DEFAULT_SERVER_READY_CHECK_INTERVAL
+	}
+
+	if c.cfgData.Server.ReadyFailureThreshold <= 0 {
+		c.cfgData.Server.ReadyFailureThreshold = DEFAULT_SERVER_READY_FAILURE_THRESHOLD
+	}
+
+	if len(c.cfgData.Tracing.Exporter) == 0 {
+		c.cfgData.Tracing.Exporter = DEFAULT_TRACE_EXPORTER
+	}
+
+	if len(c.cfgData.Tracing.File) == 0 {
+		c.cfgData.Tracing.File = DEFAULT_TRACE_FILE
+	}
+
+	if c.cfgData.Tracing.SampleRatio <= 0 || c.cfgData.Tracing.SampleRatio > 1 {
+		c.cfgData.Tracing.SampleRatio = DEFAULT_TRACE_SAMPLE_RATIO
+	}
+
+	if c.cfgData.PostGreSQL.MaxOpenConns <= 0 {
+		c.cfgData.PostGreSQL.MaxOpenConns = DEFAULT_POSTGRES_MAX_OPEN_CONNS
+	}
+
+	if c.cfgData.PostGreSQL.MaxIdleConns <= 0 {
+		c.cfgData.PostGreSQL.MaxIdleConns = DEFAULT_POSTGRES_MAX_IDLE_CONNS
+	}
+
+	if c.cfgData.PostGreSQL.ConnMaxLifetime <= 0 {
+		c.cfgData.PostGreSQL.ConnMaxLifetime = DEFAULT_POSTGRES_CONN_MAX_LIFETIME
+	}
+
+	if c.cfgData.PostGreSQL.ConnMaxIdleTime <= 0 {
+		c.cfgData.PostGreSQL.ConnMaxIdleTime = DEFAULT_POSTGRES_CONN_MAX_IDLE_TIME
+	}
+}
+
 // Exported type functions
 func (c *config) GetData(args ...string) (*ConfigData, error) {
 	if len(args) > 0 {
@@ -253,6 +628,8 @@ func (c *config) GetData(args ...string) (*ConfigData, error) {
 					return nil, getErr
 				}
 			}
+
+			cfg.setDefaults()
 		}
 	}
 
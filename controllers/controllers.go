@@ -1,17 +1,26 @@
 package controllers
 
 import (
+	"context"
 	"log"
-	"sync"
+	"time"
 
 	"github.com/sflewis2970/datastore-service/config"
 	"github.com/sflewis2970/datastore-service/models"
 )
 
 type Controller struct {
-	dbMutex   sync.Mutex
-	dataModel *models.Model
-	cfgData   *config.ConfigData
+	questionLocks questionLocks
+	dataModel     *models.Model
+	cfgData       *config.ConfigData
+
+	// Stop the reaper, reaperDone is closed once it returned
+	stopReaper context.CancelFunc
+	reaperDone chan struct{}
+
+	// Readiness of the service, draining is set to 1 once it is shutting down
+	datastoreCheck datastoreCheck
+	draining       int32
 }
 
 var controller *Controller
@@ -25,6 +34,7 @@ func New(args ...string) {
 	if controller == nil {
 		log.Print("Creating controller object...")
 		controller = new(Controller)
+		controller.questionLocks = newQuestionLocks()
 
 		// Load config data
 		var cfgDataErr error
@@ -36,5 +46,54 @@ func New(args ...string) {
 
 		// Create dataModel
 		controller.dataModel = models.New()
+
+		// Remove expired records in the background
+		if controller.dataModel != nil && controller.cfgData.ReaperInterval > 0 {
+			var reaperCtx context.Context
+			reaperCtx, controller.stopReaper = context.WithCancel(context.Background())
+			controller.reaperDone = make(chan struct{})
+
+			go controller.reap(reaperCtx, time.Duration(controller.cfgData.ReaperInterval)*time.Second)
+		}
+	}
+}
+
+// Close stops the reaper and releases the datastore connections, it is called once the server
+// stopped serving requests
+func Close() {
+	if controller == nil {
+		return
+	}
+
+	if controller.stopReaper != nil {
+		controller.stopReaper()
+		<-controller.reaperDone
+		controller.stopReaper = nil
+	}
+
+	if controller.dataModel != nil {
+		log.Print("Closing datastore...")
+		controller.dataModel.Close()
+	}
+}
+
+// Periodically remove the expired records, the datastores that skip expired records on read
+// would otherwise keep them until they are deleted
+func (c *Controller) reap(ctx context.Context, interval time.Duration) {
+	defer close(c.reaperDone)
+
+	log.Print("Starting expired record reaper, interval: ", interval)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			c.dataModel.Reap(ctx)
+		case <-ctx.Done():
+			log.Print("Stopping expired record reaper...")
+			return
+		}
 	}
 }
@@ -0,0 +1,106 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/sflewis2970/datastore-service/common"
+	"github.com/sflewis2970/datastore-service/models/messages"
+	"github.com/sflewis2970/datastore-service/tracing"
+)
+
+const DECODE_ERROR string = "Request body must be a JSON object: "
+
+// Status code of a request whose client went away before it was answered, no status code is
+// defined for it so the one of nginx is used
+const STATUS_CLIENT_CLOSED_REQUEST int = 499
+
+// HTTP status code of each error kind, an error of no kind is an internal server error
+func errorStatus(err error) int {
+	switch messages.ErrorKind(err) {
+	case messages.ErrNotFound:
+		return http.StatusNotFound
+	case messages.ErrConflict:
+		return http.StatusConflict
+	case messages.ErrPreconditionFailed:
+		return http.StatusPreconditionFailed
+	case messages.ErrInvalid:
+		return http.StatusBadRequest
+	case messages.ErrUnavailable:
+		return http.StatusServiceUnavailable
+	case messages.ErrTimeout:
+		return http.StatusGatewayTimeout
+	case messages.ErrCanceled:
+		return STATUS_CLIENT_CLOSED_REQUEST
+	}
+
+	return http.StatusInternalServerError
+}
+
+// Write the status code and the ErrorResponse of a failed request. The message is the message of
+// the error, or of its kind, the error holds the details.
+func writeError(rw http.ResponseWriter, questionID string, err error) {
+	var eResponse messages.ErrorResponse
+	eResponse.Timestamp = common.GetFormattedTime(time.Now(), "Mon Jan 2 15:04:05 2006")
+	eResponse.QuestionID = questionID
+	eResponse.Code = messages.ErrorCode(err)
+	eResponse.Error = err.Error()
+
+	var dsErr *messages.Error
+	if errors.As(err, &dsErr) {
+		eResponse.Message = dsErr.Message
+		eResponse.Fields = dsErr.Fields
+	} else if errorKind := messages.ErrorKind(err); errorKind != nil {
+		eResponse.Message = errorKind.Error()
+	} else {
+		eResponse.Message = http.StatusText(http.StatusInternalServerError)
+	}
+
+	log.Print("Request failed, code: ", eResponse.Code)
+
+	rw.WriteHeader(errorStatus(err))
+	json.NewEncoder(rw).Encode(eResponse)
+}
+
+// Return the ErrInvalid error of a request that cannot be understood
+func invalidRequestError(cause error) error {
+	return messages.NewError(messages.ErrInvalid, messages.INVALID_CODE, messages.ErrInvalid.Error(), cause)
+}
+
+// Build the decoder of a request body, the body is limited to maxSize bytes and the fields that
+// the request does not have are rejected
+func newDecoder(rw http.ResponseWriter, r *http.Request, maxSize int64) *json.Decoder {
+	decoder := json.NewDecoder(http.MaxBytesReader(rw, r.Body, maxSize))
+	decoder.DisallowUnknownFields()
+
+	return decoder
+}
+
+// Decode the JSON body of a request. An empty body leaves the request unchanged, a body that
+// cannot be decoded is answered with a bad request.
+func decodeRequest(rw http.ResponseWriter, r *http.Request, v interface{}) bool {
+	_, span := tracing.Start(r.Context(), "decode request")
+	decodeErr := newDecoder(rw, r, MAX_REQUEST_BODY_SIZE).Decode(v)
+	if decodeErr != nil && !errors.Is(decodeErr, io.EOF) {
+		tracing.End(span, decodeErr)
+		writeError(rw, "", invalidRequestError(errors.New(DECODE_ERROR+decodeErr.Error())))
+		return false
+	}
+	span.End()
+
+	return true
+}
+
+// Answer a request that failed validation with a bad request, the response holds the field errors
+func validRequest(rw http.ResponseWriter, questionID string, validateErr error) bool {
+	if validateErr != nil {
+		writeError(rw, questionID, validateErr)
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,47 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/sflewis2970/datastore-service/models/messages"
+)
+
+const (
+	ETAG_HEADER     string = "ETag"
+	IF_MATCH_HEADER string = "If-Match"
+)
+
+const IF_MATCH_ERROR string = "If-Match must hold a single strong ETag returned by a read"
+
+// Set the ETag header from the record version, records stored without a version have no ETag
+func setETag(rw http.ResponseWriter, version int64) {
+	if version > 0 {
+		rw.Header().Set(ETAG_HEADER, strconv.Quote(strconv.FormatInt(version, 10)))
+	}
+}
+
+// Get the version required by the If-Match header. The version is 0 when the header is missing
+// or "*", which any stored version matches. A header that is not an ETag returned by a read can
+// never match, the request fails with 412 Precondition Failed.
+func ifMatchVersion(rw http.ResponseWriter, r *http.Request, questionID string) (int64, bool) {
+	ifMatch := strings.TrimSpace(r.Header.Get(IF_MATCH_HEADER))
+	if len(ifMatch) == 0 || ifMatch == "*" {
+		return 0, true
+	}
+
+	unquoted, unquoteErr := strconv.Unquote(ifMatch)
+	if unquoteErr == nil {
+		version, parseErr := strconv.ParseInt(unquoted, 10, 64)
+		if parseErr == nil && version > 0 {
+			return version, true
+		}
+	}
+
+	ifMatchErr := errors.New(IF_MATCH_ERROR)
+	writeError(rw, questionID, messages.NewError(messages.ErrPreconditionFailed, messages.VERSION_MISMATCH_CODE, messages.VERSION_MISMATCH_MSG, ifMatchErr))
+
+	return 0, false
+}
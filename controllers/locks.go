@@ -0,0 +1,49 @@
+package controllers
+
+import (
+	"context"
+	"hash/fnv"
+
+	"github.com/sflewis2970/datastore-service/models/messages"
+	"github.com/sflewis2970/datastore-service/tracing"
+)
+
+// Number of question lock stripes
+const QUESTION_LOCK_STRIPES int = 256
+
+// Locks serializing the requests on the same question, so a read and a write of one question
+// do not interleave. A question ID always maps to the same stripe, requests on unrelated questions
+// rarely share one and run concurrently. Requests that are not about a single question rely on
+// the datastore to keep their records consistent. A stripe is a channel holding the single token
+// of the lock, so a request gives up waiting for it when its context is done.
+type questionLocks [QUESTION_LOCK_STRIPES]chan struct{}
+
+func newQuestionLocks() questionLocks {
+	var qLocks questionLocks
+	for idx := range qLocks {
+		qLocks[idx] = make(chan struct{}, 1)
+	}
+
+	return qLocks
+}
+
+// Lock the stripe of the question ID, the returned function unlocks it. A request whose context
+// is done before it got the lock gets an ErrTimeout or ErrCanceled error. The wait for the lock is
+// traced with a span.
+func (qLocks *questionLocks) lock(ctx context.Context, questionID string) (func(), error) {
+	hash := fnv.New32a()
+	hash.Write([]byte(questionID))
+
+	_, span := tracing.Start(ctx, "question lock", tracing.QuestionIDKey.String(questionID))
+	stripe := qLocks[hash.Sum32()%uint32(QUESTION_LOCK_STRIPES)]
+
+	select {
+	case stripe <- struct{}{}:
+		span.End()
+		return func() { <-stripe }, nil
+	case <-ctx.Done():
+		lockErr := messages.ClassifyError(ctx.Err())
+		tracing.End(span, lockErr)
+		return nil, lockErr
+	}
+}
@@ -0,0 +1,167 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/sflewis2970/datastore-service/config"
+	"github.com/sflewis2970/datastore-service/models/gocache"
+	"github.com/sflewis2970/datastore-service/models/messages"
+)
+
+// Round trip to a datastore running on another host
+const DATASTORE_ROUND_TRIP time.Duration = time.Millisecond
+
+// go-cache driver answering reads after the round trip of a remote datastore
+type remoteDBModel struct {
+	messages.IDBModel
+}
+
+func (dbm remoteDBModel) Get(ctx context.Context, questionID string) (messages.QuestionTable, error) {
+	time.Sleep(DATASTORE_ROUND_TRIP)
+	return dbm.IDBModel.Get(ctx, questionID)
+}
+
+func TestQuestionLockContext(t *testing.T) {
+	// Set config environment variables
+	setConfigEnv(config.GOCACHE_DRIVER)
+
+	// Initialize controllers object
+	New(config.REFRESH_CONFIG_DATA)
+
+	// Hold the lock of the question
+	unlock, lockErr := controller.questionLocks.lock(context.Background(), "lock1")
+	if lockErr != nil {
+		t.Fatalf("Error locking an idle question: %v", lockErr)
+	}
+
+	// A request waiting for the lock gives up once its operation times out or its client left
+	timeoutCtx, cancelTimeout := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancelTimeout()
+
+	canceledCtx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	testCases := []struct {
+		testName       string
+		ctx            context.Context
+		expectedKind   error
+		expectedStatus int
+	}{
+		{testName: "Timed out wait", ctx: timeoutCtx, expectedKind: messages.ErrTimeout, expectedStatus: http.StatusGatewayTimeout},
+		{testName: "Canceled wait", ctx: canceledCtx, expectedKind: messages.ErrCanceled, expectedStatus: STATUS_CLIENT_CLOSED_REQUEST},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			_, waitErr := controller.questionLocks.lock(tc.ctx, "lock1")
+			if !errors.Is(waitErr, tc.expectedKind) {
+				t.Errorf("Waiting for a held lock: got %v, expected: %v", waitErr, tc.expectedKind)
+			}
+
+			request := httptest.NewRequest("GET", "/api/v2/questions/lock1", nil).WithContext(tc.ctx)
+			request = mux.SetURLVars(request, map[string]string{QUESTION_ID_VAR: "lock1"})
+
+			rRecorder := httptest.NewRecorder()
+			GetQuestion(rRecorder, request)
+			if rRecorder.Code != tc.expectedStatus {
+				t.Errorf("handler returned invalid status code: got %d, expected: %d\n", rRecorder.Code, tc.expectedStatus)
+			}
+		})
+	}
+
+	// The lock is free again once unlocked
+	unlock()
+
+	unlock, lockErr = controller.questionLocks.lock(context.Background(), "lock1")
+	if lockErr != nil {
+		t.Errorf("Error locking a released question: %v", lockErr)
+	} else {
+		unlock()
+	}
+}
+
+// BenchmarkConcurrentPeeks peeks at questions from concurrent clients. The "global lock" case
+// serializes every request as the controller did before the question locks.
+func BenchmarkConcurrentPeeks(b *testing.B) {
+	// Set config environment variables
+	setConfigEnv(config.GOCACHE_DRIVER)
+
+	// Initialize controllers object
+	New(config.REFRESH_CONFIG_DATA)
+
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	controller.dataModel.SetDBModel(remoteDBModel{gocache.GetGoCacheModel(controller.cfgData)})
+	defer controller.dataModel.SetDBModel(nil)
+
+	// Store the questions
+	questionIDs := make([]string, 64)
+	for idx := range questionIDs {
+		questionIDs[idx] = "bench" + strconv.Itoa(idx)
+
+		var qRequest messages.QuestionRequest
+		qRequest.QuestionID = questionIDs[idx]
+		qRequest.Question = "What is " + strconv.Itoa(idx) + " + 1?"
+		qRequest.Answer = strconv.Itoa(idx + 1)
+
+		jsonData, _ := json.Marshal(qRequest)
+		request := httptest.NewRequest("POST", "/api/v2/questions", bytes.NewBuffer(jsonData))
+		rRecorder := httptest.NewRecorder()
+		CreateQuestion(rRecorder, request)
+		if rRecorder.Code != http.StatusCreated {
+			b.Fatalf("Storing question %s: got status %d", questionIDs[idx], rRecorder.Code)
+		}
+	}
+
+	var globalMutex sync.Mutex
+	benchCases := []struct {
+		benchName   string
+		handlerFunc http.HandlerFunc
+	}{
+		{benchName: "question locks", handlerFunc: GetQuestion},
+		{benchName: "global lock", handlerFunc: func(rw http.ResponseWriter, r *http.Request) {
+			globalMutex.Lock()
+			defer globalMutex.Unlock()
+
+			GetQuestion(rw, r)
+		}},
+	}
+
+	for _, bc := range benchCases {
+		b.Run(bc.benchName, func(b *testing.B) {
+			var next uint64
+
+			// Clients mostly wait on the datastore, there are more of them than cores
+			b.SetParallelism(16)
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					questionID := questionIDs[atomic.AddUint64(&next, 1)%uint64(len(questionIDs))]
+
+					request := httptest.NewRequest("GET", "/api/v2/questions/"+questionID, nil)
+					request = mux.SetURLVars(request, map[string]string{QUESTION_ID_VAR: questionID})
+
+					rRecorder := httptest.NewRecorder()
+					bc.handlerFunc(rRecorder, request)
+					if rRecorder.Code != http.StatusOK {
+						b.Errorf("Peeking at question %s: got status %d", questionID, rRecorder.Code)
+					}
+				}
+			})
+		})
+	}
+}
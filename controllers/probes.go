@@ -0,0 +1,167 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sflewis2970/datastore-service/common"
+	"github.com/sflewis2970/datastore-service/models/messages"
+)
+
+// Names of the readiness checks
+const (
+	CONFIG_CHECK    string = "config"
+	DATASTORE_CHECK string = "datastore"
+	DRAINING_CHECK  string = "draining"
+)
+
+// Readiness check errors
+const (
+	CONFIG_NOT_LOADED_ERROR string = "config data not loaded"
+	DRAINING_ERROR          string = "the service is shutting down"
+	NOT_ANSWERED_ERROR      string = "the datastore has not answered a ping yet"
+)
+
+// Cached result of the datastore readiness check. The datastore is pinged at most once per check
+// interval, and is only reported failed after failing the threshold of checks in a row so a single
+// slow ping does not take the service out of rotation. Until a first ping succeeded the datastore
+// is reported failed, the threshold only applies once it answered. The ping runs outside the mutex, the probes
+// arriving while it is in flight wait for it instead of pinging again.
+type datastoreCheck struct {
+	mutex     sync.Mutex
+	checkedAt time.Time
+	failures  int
+	checkErr  error
+	answered  bool
+	pinging   chan struct{}
+}
+
+func (dsCheck *datastoreCheck) check(ctx context.Context) messages.ProbeCheck {
+	dsCheck.mutex.Lock()
+	checkInterval := time.Duration(controller.cfgData.Server.ReadyCheckInterval) * time.Second
+	if time.Since(dsCheck.checkedAt) >= checkInterval && dsCheck.pinging == nil {
+		dsCheck.pinging = make(chan struct{})
+		go dsCheck.ping()
+	}
+	pinging := dsCheck.pinging
+	dsCheck.mutex.Unlock()
+
+	// A probe that gives up answers with the last result, the ping goes on for the next probes
+	if pinging != nil {
+		select {
+		case <-pinging:
+		case <-ctx.Done():
+		}
+	}
+
+	dsCheck.mutex.Lock()
+	defer dsCheck.mutex.Unlock()
+
+	var pCheck messages.ProbeCheck
+	pCheck.Name = DATASTORE_CHECK
+	pCheck.Status = messages.PROBE_OK
+	pCheck.CheckedAt = common.GetFormattedTime(dsCheck.checkedAt, "Mon Jan 2 15:04:05 2006")
+	pCheck.Failures = dsCheck.failures
+	if dsCheck.checkErr != nil {
+		pCheck.Error = dsCheck.checkErr.Error()
+	}
+
+	if !dsCheck.answered {
+		pCheck.Status = messages.PROBE_FAILED
+		if len(pCheck.Error) == 0 {
+			pCheck.Error = NOT_ANSWERED_ERROR
+		}
+	} else if dsCheck.failures >= controller.cfgData.Server.ReadyFailureThreshold {
+		pCheck.Status = messages.PROBE_FAILED
+	}
+
+	return pCheck
+}
+
+// Ping the datastore, bounded by the operation timeout rather than by the probe that started the ping
+func (dsCheck *datastoreCheck) ping() {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(controller.cfgData.OperationTimeoutMS)*time.Millisecond)
+	defer cancel()
+
+	_, pingErr := controller.dataModel.Status(ctx)
+
+	dsCheck.mutex.Lock()
+	defer dsCheck.mutex.Unlock()
+
+	// A canceled ping says nothing about the datastore, the next probe pings again
+	if !errors.Is(pingErr, context.Canceled) {
+		dsCheck.checkErr = pingErr
+		dsCheck.checkedAt = time.Now()
+
+		if dsCheck.checkErr != nil {
+			dsCheck.failures++
+		} else {
+			dsCheck.failures = 0
+			dsCheck.answered = true
+		}
+	}
+
+	close(dsCheck.pinging)
+	dsCheck.pinging = nil
+}
+
+// Drain marks the service as shutting down, the readiness probe fails from then on so no new
+// requests are routed to the service while the requests in flight finish
+func Drain() {
+	if controller != nil {
+		atomic.StoreInt32(&controller.draining, 1)
+	}
+}
+
+// Write the probe response, the status code is 503 when a check failed
+func writeProbe(rw http.ResponseWriter, pChecks []messages.ProbeCheck) {
+	var pResponse messages.ProbeResponse
+	pResponse.Timestamp = common.GetFormattedTime(time.Now(), "Mon Jan 2 15:04:05 2006")
+	pResponse.Status = messages.PROBE_OK
+	pResponse.Checks = pChecks
+
+	for _, pCheck := range pChecks {
+		if pCheck.Status != messages.PROBE_OK {
+			pResponse.Status = messages.PROBE_FAILED
+		}
+	}
+
+	if pResponse.Status != messages.PROBE_OK {
+		rw.WriteHeader(http.StatusServiceUnavailable)
+	}
+
+	json.NewEncoder(rw).Encode(pResponse)
+}
+
+// Healthz is the liveness probe, the service is alive as long as it answers
+func Healthz(rw http.ResponseWriter, r *http.Request) {
+	writeProbe(rw, nil)
+}
+
+// Readyz is the readiness probe, the service is ready when its config is loaded, the datastore
+// can be reached and it is not shutting down
+func Readyz(rw http.ResponseWriter, r *http.Request) {
+	if controller == nil || controller.cfgData == nil || controller.dataModel == nil {
+		log.Print("Readiness check failed: ", CONFIG_NOT_LOADED_ERROR)
+		writeProbe(rw, []messages.ProbeCheck{{Name: CONFIG_CHECK, Status: messages.PROBE_FAILED, Error: CONFIG_NOT_LOADED_ERROR}})
+		return
+	}
+
+	pChecks := []messages.ProbeCheck{{Name: CONFIG_CHECK, Status: messages.PROBE_OK}}
+	pChecks = append(pChecks, controller.datastoreCheck.check(r.Context()))
+
+	drainingCheck := messages.ProbeCheck{Name: DRAINING_CHECK, Status: messages.PROBE_OK}
+	if atomic.LoadInt32(&controller.draining) == 1 {
+		drainingCheck.Status = messages.PROBE_FAILED
+		drainingCheck.Error = DRAINING_ERROR
+	}
+	pChecks = append(pChecks, drainingCheck)
+
+	writeProbe(rw, pChecks)
+}
@@ -0,0 +1,204 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sflewis2970/datastore-service/config"
+	"github.com/sflewis2970/datastore-service/models/gocache"
+	"github.com/sflewis2970/datastore-service/models/messages"
+)
+
+// go-cache driver whose ping fails while down is set
+type flakyDBModel struct {
+	messages.IDBModel
+	down *bool
+}
+
+func (dbm flakyDBModel) Ping(ctx context.Context) error {
+	if *dbm.down {
+		return messages.UnavailableError(errors.New("connection refused"))
+	}
+
+	return dbm.IDBModel.Ping(ctx)
+}
+
+// go-cache driver whose ping waits until released, counting the pings
+type blockingDBModel struct {
+	messages.IDBModel
+	release chan struct{}
+	pings   *int32
+}
+
+func (dbm blockingDBModel) Ping(ctx context.Context) error {
+	atomic.AddInt32(dbm.pings, 1)
+
+	select {
+	case <-dbm.release:
+		return dbm.IDBModel.Ping(ctx)
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func ProbeTest(t *testing.T, handlerFunc http.HandlerFunc, expectedStatus int) messages.ProbeResponse {
+	request, reqErr := http.NewRequest("GET", "/readyz", nil)
+	if reqErr != nil {
+		t.Errorf("Could not create request.\n")
+	}
+
+	rRecorder := httptest.NewRecorder()
+	handlerFunc.ServeHTTP(rRecorder, request)
+
+	if rRecorder.Code != expectedStatus {
+		t.Errorf("handler returned invalid status code: got %d, expected: %d\n", rRecorder.Code, expectedStatus)
+	}
+
+	var pResponse messages.ProbeResponse
+	unmarshalErr := json.Unmarshal(rRecorder.Body.Bytes(), &pResponse)
+	if unmarshalErr != nil {
+		t.Errorf(unmarshalErr.Error())
+	}
+
+	return pResponse
+}
+
+// Status of the named check of a probe response
+func checkStatus(pResponse messages.ProbeResponse, name string) string {
+	for _, pCheck := range pResponse.Checks {
+		if pCheck.Name == name {
+			return pCheck.Status
+		}
+	}
+
+	return ""
+}
+
+func TestProbes(t *testing.T) {
+	// Initialize logging
+	log.SetFlags(log.Ldate | log.Lshortfile)
+
+	// Set config environment variables
+	setConfigEnv(config.GOCACHE_DRIVER)
+
+	// Initialize controllers object
+	New(config.REFRESH_CONFIG_DATA)
+
+	// The process is alive
+	pResponse := ProbeTest(t, Healthz, http.StatusOK)
+	if pResponse.Status != messages.PROBE_OK {
+		t.Errorf("Healthz: got status %s", pResponse.Status)
+	}
+
+	// The datastore fails its pings until it is back up
+	down := true
+	controller.dataModel.SetDBModel(flakyDBModel{IDBModel: gocache.GetGoCacheModel(controller.cfgData), down: &down})
+	defer controller.dataModel.SetDBModel(nil)
+
+	// The service is not ready until the datastore answered a first ping, however few pings failed
+	controller.datastoreCheck = datastoreCheck{}
+	threshold := controller.cfgData.Server.ReadyFailureThreshold
+	for failure := 1; failure < threshold; failure++ {
+		controller.datastoreCheck.checkedAt = time.Time{}
+
+		pResponse = ProbeTest(t, Readyz, http.StatusServiceUnavailable)
+		if checkStatus(pResponse, DATASTORE_CHECK) != messages.PROBE_FAILED || pResponse.Checks[1].Failures != failure {
+			t.Errorf("Readyz: datastore check %+v after %d failures before a first answer", pResponse.Checks[1], failure)
+		}
+	}
+
+	// Nor when the probe gives up before the first ping answered
+	release := make(chan struct{})
+	var pings int32
+	controller.dataModel.SetDBModel(blockingDBModel{IDBModel: gocache.GetGoCacheModel(controller.cfgData), release: release, pings: &pings})
+	controller.datastoreCheck.checkedAt = time.Time{}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	request := httptest.NewRequest("GET", "/readyz", nil).WithContext(ctx)
+	rRecorder := httptest.NewRecorder()
+	Readyz(rRecorder, request)
+	if rRecorder.Code != http.StatusServiceUnavailable {
+		t.Errorf("Readyz: got status code %d from a probe giving up before a first answer", rRecorder.Code)
+	}
+
+	// Once the datastore answered, the failures below the threshold are reported and the service
+	// stays ready
+	close(release)
+	ProbeTest(t, Readyz, http.StatusOK)
+
+	controller.dataModel.SetDBModel(flakyDBModel{IDBModel: gocache.GetGoCacheModel(controller.cfgData), down: &down})
+	for failure := 1; failure < threshold; failure++ {
+		controller.datastoreCheck.checkedAt = time.Time{}
+
+		pResponse = ProbeTest(t, Readyz, http.StatusOK)
+		if checkStatus(pResponse, DATASTORE_CHECK) != messages.PROBE_OK || pResponse.Checks[1].Failures != failure {
+			t.Errorf("Readyz: datastore check %+v after %d failures", pResponse.Checks[1], failure)
+		}
+	}
+
+	controller.datastoreCheck.checkedAt = time.Time{}
+	pResponse = ProbeTest(t, Readyz, http.StatusServiceUnavailable)
+	if checkStatus(pResponse, DATASTORE_CHECK) != messages.PROBE_FAILED || len(pResponse.Checks[1].Error) == 0 {
+		t.Errorf("Readyz: datastore check %+v after reaching the threshold", pResponse.Checks[1])
+	}
+
+	// The result is cached until the check interval elapsed
+	down = false
+	ProbeTest(t, Readyz, http.StatusServiceUnavailable)
+
+	controller.datastoreCheck.checkedAt = time.Time{}
+	pResponse = ProbeTest(t, Readyz, http.StatusOK)
+	if pResponse.Status != messages.PROBE_OK || checkStatus(pResponse, CONFIG_CHECK) != messages.PROBE_OK {
+		t.Errorf("Readyz: got %+v once the datastore is back up", pResponse)
+	}
+
+	// A probe giving up does not fail the check, the probes arriving meanwhile share the ping in flight
+	release = make(chan struct{})
+	pings = 0
+	controller.dataModel.SetDBModel(blockingDBModel{IDBModel: gocache.GetGoCacheModel(controller.cfgData), release: release, pings: &pings})
+	controller.datastoreCheck.checkedAt = time.Time{}
+
+	rRecorder = httptest.NewRecorder()
+	Readyz(rRecorder, request)
+	if rRecorder.Code != http.StatusOK {
+		t.Errorf("Readyz: got status code %d from a probe giving up", rRecorder.Code)
+	}
+
+	var waitGroup sync.WaitGroup
+	for probe := 0; probe < 3; probe++ {
+		waitGroup.Add(1)
+		go func() {
+			defer waitGroup.Done()
+			ProbeTest(t, Readyz, http.StatusOK)
+		}()
+	}
+
+	close(release)
+	waitGroup.Wait()
+
+	if atomic.LoadInt32(&pings) != 1 || controller.datastoreCheck.failures != 0 {
+		t.Errorf("Readyz: %d pings, %d failures", atomic.LoadInt32(&pings), controller.datastoreCheck.failures)
+	}
+
+	// A draining service is not ready
+	Drain()
+	defer func() { controller.draining = 0 }()
+
+	pResponse = ProbeTest(t, Readyz, http.StatusServiceUnavailable)
+	if checkStatus(pResponse, DRAINING_CHECK) != messages.PROBE_FAILED {
+		t.Errorf("Readyz: got %+v while draining", pResponse)
+	}
+
+	// The liveness does not depend on the readiness
+	ProbeTest(t, Healthz, http.StatusOK)
+}
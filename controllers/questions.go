@@ -0,0 +1,324 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/sflewis2970/datastore-service/models/messages"
+)
+
+// Route variable holding the question ID for the v2 question resource
+const QUESTION_ID_VAR string = "id"
+
+const QUESTION_ID_MISMATCH_ERROR string = "questionid in the request body does not match the resource path"
+
+// Get the question ID from the request path. When the body also carries a question ID,
+// it must match the one in the path.
+func questionIDFromPath(rw http.ResponseWriter, r *http.Request, qRequest *messages.QuestionRequest) bool {
+	questionID := mux.Vars(r)[QUESTION_ID_VAR]
+
+	if len(qRequest.QuestionID) > 0 && qRequest.QuestionID != questionID {
+		writeError(rw, questionID, invalidRequestError(errors.New(QUESTION_ID_MISMATCH_ERROR)))
+		return false
+	}
+
+	qRequest.QuestionID = questionID
+
+	return true
+}
+
+// Query parameter requesting that CreateQuestion overwrites a stored question with the same question ID
+const UPSERT_PARAM string = "upsert"
+
+// CreateQuestion stores a new question, a question ID is generated when the request does not provide one.
+// A stored question with the same question ID is only overwritten when the upsert query parameter is set.
+func CreateQuestion(rw http.ResponseWriter, r *http.Request) {
+	// Display a log message
+	log.Print("Create question requested...")
+
+	// Question Request
+	var qRequest messages.QuestionRequest
+
+	// Decode request into JSON format
+	if !decodeRequest(rw, r, &qRequest) {
+		return
+	}
+
+	// Generate question ID
+	if len(qRequest.QuestionID) == 0 {
+		qRequest.QuestionID = uuid.NewString()
+	}
+
+	upsert, _ := strconv.ParseBool(r.URL.Query().Get(UPSERT_PARAM))
+	qRequest.Upsert = qRequest.Upsert || upsert
+
+	if !validRequest(rw, qRequest.QuestionID, validateQuestionRequest(qRequest)) {
+		return
+	}
+
+	unlock, lockErr := controller.questionLocks.lock(r.Context(), qRequest.QuestionID)
+	if lockErr != nil {
+		writeError(rw, qRequest.QuestionID, lockErr)
+		return
+	}
+	defer unlock()
+
+	// Send Insert request
+	qResponse, createErr := controller.dataModel.Insert(r.Context(), qRequest)
+	if createErr != nil {
+		writeError(rw, qRequest.QuestionID, createErr)
+		return
+	} else if qRequest.Upsert {
+		rw.Header().Set("Location", r.URL.Path+"/"+qRequest.QuestionID)
+		rw.WriteHeader(http.StatusOK)
+	} else {
+		rw.Header().Set("Location", r.URL.Path+"/"+qRequest.QuestionID)
+		setETag(rw, qResponse.Version)
+		rw.WriteHeader(http.StatusCreated)
+	}
+
+	// Write JSON to stream
+	json.NewEncoder(rw).Encode(qResponse)
+}
+
+// CreateQuestions stores the JSON array of questions in the body, question IDs are generated for
+// the questions that do not provide one. The response holds a result per question.
+func CreateQuestions(rw http.ResponseWriter, r *http.Request) {
+	// Display a log message
+	log.Print("Create questions requested...")
+
+	// Decode request into JSON format
+	qRequests, decoded := decodeBatch(rw, r)
+	if !decoded {
+		return
+	}
+
+	// Generate question IDs
+	for idx := range qRequests {
+		if len(qRequests[idx].QuestionID) == 0 {
+			qRequests[idx].QuestionID = uuid.NewString()
+		}
+	}
+
+	// Send Insert Batch request
+	insertBatch(rw, r, qRequests)
+}
+
+// GetQuestion returns the question identified by the request path, the question is left stored
+func GetQuestion(rw http.ResponseWriter, r *http.Request) {
+	// Display a log message
+	log.Print("Get question requested...")
+
+	// Answer Request
+	var aRequest messages.AnswerRequest
+	aRequest.Peek = true
+	takeQuestion(rw, r, aRequest)
+}
+
+// ConsumeQuestion returns the question identified by the request path and removes it, so only one
+// client receives the question
+func ConsumeQuestion(rw http.ResponseWriter, r *http.Request) {
+	// Display a log message
+	log.Print("Consume question requested...")
+
+	var aRequest messages.AnswerRequest
+	takeQuestion(rw, r, aRequest)
+}
+
+// CheckoutQuestion returns the question identified by the request path and leases it, the answer
+// confirms the lease
+func CheckoutQuestion(rw http.ResponseWriter, r *http.Request) {
+	// Display a log message
+	log.Print("Checkout question requested...")
+
+	var aRequest messages.AnswerRequest
+	aRequest.Lease = true
+	takeQuestion(rw, r, aRequest)
+}
+
+// Read, consume or lease the question identified by the request path, as set in the request
+func takeQuestion(rw http.ResponseWriter, r *http.Request, aRequest messages.AnswerRequest) {
+	aRequest.QuestionID = mux.Vars(r)[QUESTION_ID_VAR]
+	if !validRequest(rw, aRequest.QuestionID, validateQuestionID(aRequest.QuestionID)) {
+		return
+	}
+
+	unlock, lockErr := controller.questionLocks.lock(r.Context(), aRequest.QuestionID)
+	if lockErr != nil {
+		writeError(rw, aRequest.QuestionID, lockErr)
+		return
+	}
+	defer unlock()
+
+	// Send Answer Request
+	aResponse, getErr := controller.dataModel.Get(r.Context(), aRequest)
+	if getErr != nil {
+		writeError(rw, aRequest.QuestionID, getErr)
+		return
+	}
+	setETag(rw, aResponse.Version)
+
+	// Write JSON to stream
+	json.NewEncoder(rw).Encode(aResponse)
+}
+
+// AnswerQuestion checks the submitted answer for the question identified by the request path
+func AnswerQuestion(rw http.ResponseWriter, r *http.Request) {
+	// Display a log message
+	log.Print("Answer question requested...")
+
+	// Check Answer Request
+	var caRequest messages.CheckAnswerRequest
+
+	// Decode request into JSON format
+	if !decodeRequest(rw, r, &caRequest) {
+		return
+	}
+	caRequest.QuestionID = mux.Vars(r)[QUESTION_ID_VAR]
+	if !validRequest(rw, caRequest.QuestionID, validateCheckAnswerRequest(caRequest)) {
+		return
+	}
+
+	unlock, lockErr := controller.questionLocks.lock(r.Context(), caRequest.QuestionID)
+	if lockErr != nil {
+		writeError(rw, caRequest.QuestionID, lockErr)
+		return
+	}
+	defer unlock()
+
+	// Send Check Answer Request
+	caResponse, checkErr := controller.dataModel.CheckAnswer(r.Context(), caRequest)
+	if checkErr != nil {
+		writeError(rw, caRequest.QuestionID, checkErr)
+		return
+	}
+
+	// Write JSON to stream
+	json.NewEncoder(rw).Encode(caResponse)
+}
+
+// ReplaceQuestion overwrites every field of the question identified by the request path. With an
+// If-Match header the question is only replaced when it is still at the version of the ETag.
+func ReplaceQuestion(rw http.ResponseWriter, r *http.Request) {
+	// Display a log message
+	log.Print("Replace question requested...")
+
+	// Question Request
+	var qRequest messages.QuestionRequest
+
+	// Decode request into JSON format
+	if !decodeRequest(rw, r, &qRequest) || !questionIDFromPath(rw, r, &qRequest) {
+		return
+	}
+
+	if !validRequest(rw, qRequest.QuestionID, validateQuestionRequest(qRequest)) {
+		return
+	}
+
+	version, versionOk := ifMatchVersion(rw, r, qRequest.QuestionID)
+	if !versionOk {
+		return
+	} else if version > 0 {
+		qRequest.Version = version
+	}
+
+	unlock, lockErr := controller.questionLocks.lock(r.Context(), qRequest.QuestionID)
+	if lockErr != nil {
+		writeError(rw, qRequest.QuestionID, lockErr)
+		return
+	}
+	defer unlock()
+
+	// Update question
+	qResponse, updateErr := controller.dataModel.Update(r.Context(), qRequest)
+	if updateErr != nil {
+		writeError(rw, qRequest.QuestionID, updateErr)
+		return
+	}
+	setETag(rw, qResponse.Version)
+
+	// Write JSON to stream
+	json.NewEncoder(rw).Encode(qResponse)
+}
+
+// PatchQuestion updates the fields provided in the request of the question identified by the request path.
+// With an If-Match header the question is only updated when it is still at the version of the ETag.
+func PatchQuestion(rw http.ResponseWriter, r *http.Request) {
+	// Display a log message
+	log.Print("Patch question requested...")
+
+	// Question Request
+	var qRequest messages.QuestionRequest
+
+	// Decode request into JSON format
+	if !decodeRequest(rw, r, &qRequest) || !questionIDFromPath(rw, r, &qRequest) {
+		return
+	}
+
+	if !validRequest(rw, qRequest.QuestionID, validatePatchRequest(qRequest)) {
+		return
+	}
+
+	version, versionOk := ifMatchVersion(rw, r, qRequest.QuestionID)
+	if !versionOk {
+		return
+	} else if version > 0 {
+		qRequest.Version = version
+	}
+
+	unlock, lockErr := controller.questionLocks.lock(r.Context(), qRequest.QuestionID)
+	if lockErr != nil {
+		writeError(rw, qRequest.QuestionID, lockErr)
+		return
+	}
+	defer unlock()
+
+	// Patch question
+	qResponse, patchErr := controller.dataModel.Patch(r.Context(), qRequest)
+	if patchErr != nil {
+		writeError(rw, qRequest.QuestionID, patchErr)
+		return
+	}
+	setETag(rw, qResponse.Version)
+
+	// Write JSON to stream
+	json.NewEncoder(rw).Encode(qResponse)
+}
+
+// DeleteQuestion removes the question identified by the request path. With an If-Match header the
+// question is only removed when it is still at the version of the ETag.
+func DeleteQuestion(rw http.ResponseWriter, r *http.Request) {
+	// Display a log message
+	log.Print("Delete question requested...")
+
+	questionID := mux.Vars(r)[QUESTION_ID_VAR]
+	if !validRequest(rw, questionID, validateQuestionID(questionID)) {
+		return
+	}
+
+	version, versionOk := ifMatchVersion(rw, r, questionID)
+	if !versionOk {
+		return
+	}
+
+	unlock, lockErr := controller.questionLocks.lock(r.Context(), questionID)
+	if lockErr != nil {
+		writeError(rw, questionID, lockErr)
+		return
+	}
+	defer unlock()
+
+	// Send delete request
+	_, delErr := controller.dataModel.Delete(r.Context(), questionID, version)
+	if delErr != nil {
+		writeError(rw, questionID, delErr)
+		return
+	}
+
+	rw.WriteHeader(http.StatusNoContent)
+}
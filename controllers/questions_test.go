@@ -0,0 +1,704 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/sflewis2970/datastore-service/config"
+	"github.com/sflewis2970/datastore-service/models/messages"
+)
+
+func QuestionTest(t *testing.T, handlerFunc http.HandlerFunc, method string, questionID string, jsonData []byte, expectedStatus int) *httptest.ResponseRecorder {
+	return ConditionalQuestionTest(t, handlerFunc, method, questionID, "", jsonData, expectedStatus)
+}
+
+// ConditionalQuestionTest sends the request with an If-Match header, an empty ifMatch sends no header
+func ConditionalQuestionTest(t *testing.T, handlerFunc http.HandlerFunc, method string, questionID string, ifMatch string, jsonData []byte, expectedStatus int) *httptest.ResponseRecorder {
+	// Build resource path
+	path := "/api/v2/questions"
+	if len(questionID) > 0 {
+		path = path + "/" + questionID
+	}
+
+	// Create new request
+	request, reqErr := http.NewRequest(method, path, bytes.NewBuffer(jsonData))
+	if reqErr != nil {
+		t.Errorf("Could not create request.\n")
+	}
+
+	// Set route variables
+	if len(questionID) > 0 {
+		request = mux.SetURLVars(request, map[string]string{QUESTION_ID_VAR: questionID})
+	}
+
+	// Set precondition
+	if len(ifMatch) > 0 {
+		request.Header.Set(IF_MATCH_HEADER, ifMatch)
+	}
+
+	// Setup recoder
+	rRecorder := httptest.NewRecorder()
+	handlerFunc.ServeHTTP(rRecorder, request)
+
+	// Check response code
+	status := rRecorder.Code
+	if status != expectedStatus {
+		t.Errorf("handler returned invalid status code: got %d, expected: %d\n", status, expectedStatus)
+	}
+
+	return rRecorder
+}
+
+func TestCreateQuestionGeneratesID(t *testing.T) {
+	// Initialize logging
+	log.SetFlags(log.Ldate | log.Lshortfile)
+
+	// Set config environment variables
+	setConfigEnv(config.GOCACHE_DRIVER)
+
+	// Initialize controllers object
+	New(config.REFRESH_CONFIG_DATA)
+
+	// Build Question Request without a question ID
+	var qRequest messages.QuestionRequest
+	qRequest.Question = "What is the largest planet in the solar system?"
+	qRequest.Category = "science"
+	qRequest.Answer = "Jupiter"
+
+	// Marshal QuestionRequest
+	jsonData, marshalErr := json.Marshal(qRequest)
+	if marshalErr != nil {
+		t.Errorf("New request error: %s", marshalErr.Error())
+	}
+
+	// Send Create request to datastore
+	rRecorder := QuestionTest(t, CreateQuestion, "POST", "", jsonData, http.StatusCreated)
+
+	// Unmarshal data to QuestionResponse
+	var qResponse messages.QuestionResponse
+	unmarshalErr := json.Unmarshal(rRecorder.Body.Bytes(), &qResponse)
+	if unmarshalErr != nil {
+		t.Errorf(unmarshalErr.Error())
+	}
+
+	// Check generated question ID
+	if len(qResponse.QuestionID) == 0 {
+		t.Errorf("A question ID was not generated...")
+	}
+
+	// Check Location header
+	location := rRecorder.Header().Get("Location")
+	if location != "/api/v2/questions/"+qResponse.QuestionID {
+		t.Errorf("Unexpected Location header: %s", location)
+	}
+}
+
+func TestCreateQuestionConflict(t *testing.T) {
+	// Initialize logging
+	log.SetFlags(log.Ldate | log.Lshortfile)
+
+	// Set config environment variables
+	setConfigEnv(config.GOCACHE_DRIVER)
+
+	// Initialize controllers object
+	New(config.REFRESH_CONFIG_DATA)
+
+	// Build Question Request
+	var qRequest messages.QuestionRequest
+	qRequest.QuestionID = "bbbbaaaa"
+	qRequest.Question = "What is the largest planet in the solar system?"
+	qRequest.Category = "science"
+	qRequest.Answer = "Jupiter"
+
+	// Marshal QuestionRequest
+	jsonData, marshalErr := json.Marshal(qRequest)
+	if marshalErr != nil {
+		t.Errorf("New request error: %s", marshalErr.Error())
+	}
+
+	// The first request creates the question, the second one conflicts with it
+	QuestionTest(t, CreateQuestion, "POST", "", jsonData, http.StatusCreated)
+	QuestionTest(t, CreateQuestion, "POST", "", jsonData, http.StatusConflict)
+
+	// An upsert overwrites the question
+	qRequest.Answer = "jupiter"
+	jsonData, marshalErr = json.Marshal(qRequest)
+	if marshalErr != nil {
+		t.Errorf("New request error: %s", marshalErr.Error())
+	}
+
+	request, reqErr := http.NewRequest("POST", "/api/v2/questions?upsert=true", bytes.NewBuffer(jsonData))
+	if reqErr != nil {
+		t.Errorf("Could not create request.\n")
+	}
+
+	rRecorder := httptest.NewRecorder()
+	http.HandlerFunc(CreateQuestion).ServeHTTP(rRecorder, request)
+
+	if rRecorder.Code != http.StatusOK {
+		t.Errorf("handler returned invalid status code: got %d, expected: %d\n", rRecorder.Code, http.StatusOK)
+	}
+
+	rRecorder = QuestionTest(t, GetQuestion, "GET", qRequest.QuestionID, nil, http.StatusOK)
+
+	var aResponse messages.AnswerResponse
+	json.NewDecoder(rRecorder.Body).Decode(&aResponse)
+	if aResponse.Answer != qRequest.Answer || aResponse.Version != messages.INITIAL_VERSION+1 {
+		t.Errorf("Question was not overwritten: %s, %d", aResponse.Answer, aResponse.Version)
+	}
+}
+
+func TestQuestionLifecycle(t *testing.T) {
+	// Initialize logging
+	log.SetFlags(log.Ldate | log.Lshortfile)
+
+	// Set config environment variables
+	setConfigEnv(config.GOCACHE_DRIVER)
+
+	// Initialize controllers object
+	New(config.REFRESH_CONFIG_DATA)
+
+	// Build Question Request
+	var qRequest messages.QuestionRequest
+	qRequest.QuestionID = "bbbbcccc"
+	qRequest.Question = "What is the largest planet in the solar system?"
+	qRequest.Category = "general"
+	qRequest.Answer = "Jupiter"
+
+	// Marshal QuestionRequest
+	jsonData, marshalErr := json.Marshal(qRequest)
+	if marshalErr != nil {
+		t.Errorf("New request error: %s", marshalErr.Error())
+	}
+
+	// Create question
+	QuestionTest(t, CreateQuestion, "POST", "", jsonData, http.StatusCreated)
+
+	// Patch only the category
+	jsonData = []byte(`{"category": "science"}`)
+	QuestionTest(t, PatchQuestion, "PATCH", qRequest.QuestionID, jsonData, http.StatusOK)
+
+	// A body question ID that does not match the path is rejected
+	jsonData = []byte(`{"questionid": "bbbbdddd", "question": "?", "answer": "?"}`)
+	QuestionTest(t, ReplaceQuestion, "PUT", qRequest.QuestionID, jsonData, http.StatusBadRequest)
+
+	// Get question
+	rRecorder := QuestionTest(t, GetQuestion, "GET", qRequest.QuestionID, nil, http.StatusOK)
+
+	var aResponse messages.AnswerResponse
+	unmarshalErr := json.Unmarshal(rRecorder.Body.Bytes(), &aResponse)
+	if unmarshalErr != nil {
+		t.Errorf(unmarshalErr.Error())
+	}
+
+	if aResponse.Category != "science" {
+		t.Errorf("The category was not patched, category returned: %s", aResponse.Category)
+	}
+
+	if aResponse.Question != qRequest.Question {
+		t.Errorf("The question was unexpectedly changed by patch, question returned: %s", aResponse.Question)
+	}
+
+	// A get leaves the question stored, it is only removed once consumed
+	QuestionTest(t, GetQuestion, "GET", qRequest.QuestionID, nil, http.StatusOK)
+	QuestionTest(t, ConsumeQuestion, "POST", qRequest.QuestionID, nil, http.StatusOK)
+	QuestionTest(t, GetQuestion, "GET", qRequest.QuestionID, nil, http.StatusNotFound)
+	QuestionTest(t, ConsumeQuestion, "POST", qRequest.QuestionID, nil, http.StatusNotFound)
+	QuestionTest(t, PatchQuestion, "PATCH", qRequest.QuestionID, []byte(`{"category": "science"}`), http.StatusNotFound)
+	QuestionTest(t, DeleteQuestion, "DELETE", qRequest.QuestionID, nil, http.StatusNotFound)
+}
+
+func TestDeleteQuestion(t *testing.T) {
+	// Initialize logging
+	log.SetFlags(log.Ldate | log.Lshortfile)
+
+	// Set config environment variables
+	setConfigEnv(config.GOCACHE_DRIVER)
+
+	// Initialize controllers object
+	New(config.REFRESH_CONFIG_DATA)
+
+	// Build Question Request
+	var qRequest messages.QuestionRequest
+	qRequest.QuestionID = "bbbbeeee"
+	qRequest.Question = "What is the largest planet in the solar system?"
+	qRequest.Category = "science"
+	qRequest.Answer = "Jupiter"
+
+	// Marshal QuestionRequest
+	jsonData, marshalErr := json.Marshal(qRequest)
+	if marshalErr != nil {
+		t.Errorf("New request error: %s", marshalErr.Error())
+	}
+
+	// Create, replace and delete question
+	QuestionTest(t, CreateQuestion, "POST", "", jsonData, http.StatusCreated)
+	QuestionTest(t, ReplaceQuestion, "PUT", qRequest.QuestionID, jsonData, http.StatusOK)
+	QuestionTest(t, DeleteQuestion, "DELETE", qRequest.QuestionID, nil, http.StatusNoContent)
+	QuestionTest(t, GetQuestion, "GET", qRequest.QuestionID, nil, http.StatusNotFound)
+}
+
+func TestGetQuestionReadOnly(t *testing.T) {
+	// Initialize logging
+	log.SetFlags(log.Ldate | log.Lshortfile)
+
+	// Set config environment variables
+	setConfigEnv(config.GOCACHE_DRIVER)
+
+	// Initialize controllers object
+	New(config.REFRESH_CONFIG_DATA)
+
+	// Build Question Request
+	var qRequest messages.QuestionRequest
+	qRequest.QuestionID = "bbbbffff"
+	qRequest.Question = "What is the largest planet in the solar system?"
+	qRequest.Category = "science"
+	qRequest.Answer = "Jupiter"
+
+	// Marshal QuestionRequest
+	jsonData, marshalErr := json.Marshal(qRequest)
+	if marshalErr != nil {
+		t.Errorf("New request error: %s", marshalErr.Error())
+	}
+
+	// Create question
+	QuestionTest(t, CreateQuestion, "POST", "", jsonData, http.StatusCreated)
+
+	// Get the question twice
+	QuestionTest(t, GetQuestion, "GET", qRequest.QuestionID, nil, http.StatusOK)
+	QuestionTest(t, GetQuestion, "GET", qRequest.QuestionID, nil, http.StatusOK)
+
+	// The question is removed by a consume
+	QuestionTest(t, ConsumeQuestion, "POST", qRequest.QuestionID, nil, http.StatusOK)
+	QuestionTest(t, ConsumeQuestion, "POST", qRequest.QuestionID, nil, http.StatusNotFound)
+}
+
+func TestMultipleChoiceQuestion(t *testing.T) {
+	// Initialize logging
+	log.SetFlags(log.Ldate | log.Lshortfile)
+
+	// Set config environment variables
+	setConfigEnv(config.GOCACHE_DRIVER)
+
+	// Initialize controllers object
+	New(config.REFRESH_CONFIG_DATA)
+
+	// The answer must be one of the options
+	jsonData := []byte(`{"questionid": "bbbbgggg", "question": "Which planet is known as the red planet?", "answer": "Mars", "options": ["Venus", "Jupiter"]}`)
+	QuestionTest(t, CreateQuestion, "POST", "", jsonData, http.StatusBadRequest)
+
+	// The correct option must be one of the options
+	jsonData = []byte(`{"questionid": "bbbbgggg", "question": "Which planet is known as the red planet?", "options": ["Venus", "Mars"], "correctoptions": [2]}`)
+	QuestionTest(t, CreateQuestion, "POST", "", jsonData, http.StatusBadRequest)
+
+	// Create question, the answer is taken from the correct option
+	jsonData = []byte(`{"questionid": "bbbbgggg", "question": "Which planet is known as the red planet?", "options": ["Venus", "Mars", "Jupiter", "Saturn"], "correctoptions": [1], "shuffle": true}`)
+	QuestionTest(t, CreateQuestion, "POST", "", jsonData, http.StatusCreated)
+
+	// Get the question, the correct option follows the shuffled options
+	rRecorder := QuestionTest(t, GetQuestion, "GET", "bbbbgggg", nil, http.StatusOK)
+
+	var aResponse messages.AnswerResponse
+	unmarshalErr := json.Unmarshal(rRecorder.Body.Bytes(), &aResponse)
+	if unmarshalErr != nil {
+		t.Errorf(unmarshalErr.Error())
+	}
+
+	if aResponse.Answer != "Mars" {
+		t.Errorf("Unexpected answer: %s", aResponse.Answer)
+	}
+
+	if len(aResponse.Options) != 4 || len(aResponse.CorrectOptions) != 1 || aResponse.Options[aResponse.CorrectOptions[0]] != "Mars" {
+		t.Errorf("Unexpected options: %v, correct options: %v", aResponse.Options, aResponse.CorrectOptions)
+	}
+
+	// Answer with the text of the correct option
+	rRecorder = QuestionTest(t, AnswerQuestion, "POST", "bbbbgggg", []byte(`{"answer": "mars"}`), http.StatusOK)
+
+	var caResponse messages.CheckAnswerResponse
+	unmarshalErr = json.Unmarshal(rRecorder.Body.Bytes(), &caResponse)
+	if unmarshalErr != nil {
+		t.Errorf(unmarshalErr.Error())
+	}
+
+	if !caResponse.Correct {
+		t.Errorf("The correct option was not accepted...")
+	}
+}
+
+func TestListQuestions(t *testing.T) {
+	// Initialize logging
+	log.SetFlags(log.Ldate | log.Lshortfile)
+
+	// Set config environment variables
+	setConfigEnv(config.GOCACHE_DRIVER)
+
+	// Initialize controllers object
+	New(config.REFRESH_CONFIG_DATA)
+
+	// Create questions in a category of their own
+	questionIDs := []string{"bbbbiiii", "bbbbhhhh", "bbbbjjjj"}
+	for _, questionID := range questionIDs {
+		jsonData := []byte(`{"questionid": "` + questionID + `", "question": "?", "category": "listing", "answer": "!"}`)
+		QuestionTest(t, CreateQuestion, "POST", "", jsonData, http.StatusCreated)
+	}
+
+	// Page through the category two questions at a time
+	listedIDs := make([]string, 0)
+	cursor := ""
+	for page := 0; page < len(questionIDs); page++ {
+		request, reqErr := http.NewRequest("GET", "/api/v2/questions?category=listing&limit=2&cursor="+cursor, nil)
+		if reqErr != nil {
+			t.Errorf("Could not create request.\n")
+		}
+
+		rRecorder := httptest.NewRecorder()
+		http.HandlerFunc(List).ServeHTTP(rRecorder, request)
+
+		if rRecorder.Code != http.StatusOK {
+			t.Errorf("handler returned invalid status code: got %d, expected: %d\n", rRecorder.Code, http.StatusOK)
+		}
+
+		var lResponse messages.ListResponse
+		unmarshalErr := json.Unmarshal(rRecorder.Body.Bytes(), &lResponse)
+		if unmarshalErr != nil {
+			t.Errorf(unmarshalErr.Error())
+		}
+
+		for _, qRecord := range lResponse.Questions {
+			listedIDs = append(listedIDs, qRecord.QuestionID)
+		}
+
+		cursor = lResponse.NextCursor
+		if len(cursor) == 0 {
+			break
+		}
+	}
+
+	expectedIDs := []string{"bbbbhhhh", "bbbbiiii", "bbbbjjjj"}
+	if len(listedIDs) != len(expectedIDs) {
+		t.Fatalf("Unexpected questions listed: %v", listedIDs)
+	}
+
+	for idx := range expectedIDs {
+		if listedIDs[idx] != expectedIDs[idx] {
+			t.Errorf("Unexpected questions listed: %v", listedIDs)
+		}
+	}
+
+	// Invalid limit and cursor are rejected
+	for _, query := range []string{"limit=abc", "limit=501", "cursor=***"} {
+		request, reqErr := http.NewRequest("GET", "/api/v2/questions?"+query, nil)
+		if reqErr != nil {
+			t.Errorf("Could not create request.\n")
+		}
+
+		rRecorder := httptest.NewRecorder()
+		http.HandlerFunc(List).ServeHTTP(rRecorder, request)
+
+		if rRecorder.Code != http.StatusBadRequest {
+			t.Errorf("%s: handler returned invalid status code: got %d, expected: %d\n", query, rRecorder.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestDrawQuestion(t *testing.T) {
+	// Initialize logging
+	log.SetFlags(log.Ldate | log.Lshortfile)
+
+	// Set config environment variables
+	setConfigEnv(config.GOCACHE_DRIVER)
+
+	// Initialize controllers object
+	New(config.REFRESH_CONFIG_DATA)
+
+	// Create questions in a category of their own
+	questionIDs := map[string]bool{"bbbbkkkk": true, "bbbbllll": true, "bbbbmmmm": true}
+	for questionID := range questionIDs {
+		jsonData := []byte(`{"questionid": "` + questionID + `", "question": "?", "category": "drawing", "answer": "!"}`)
+		QuestionTest(t, CreateQuestion, "POST", "", jsonData, http.StatusCreated)
+	}
+
+	// Every question is drawn once, the excluded question is never drawn
+	jsonData := []byte(`{"category": "drawing", "exclude": ["bbbbmmmm"]}`)
+	for idx := 0; idx < 2; idx++ {
+		rRecorder := QuestionTest(t, Draw, "POST", "", jsonData, http.StatusOK)
+
+		var aResponse messages.AnswerResponse
+		unmarshalErr := json.Unmarshal(rRecorder.Body.Bytes(), &aResponse)
+		if unmarshalErr != nil {
+			t.Errorf(unmarshalErr.Error())
+		}
+
+		if aResponse.QuestionID == "bbbbmmmm" || !questionIDs[aResponse.QuestionID] {
+			t.Errorf("Unexpected question drawn: %s", aResponse.QuestionID)
+		}
+		delete(questionIDs, aResponse.QuestionID)
+	}
+
+	QuestionTest(t, Draw, "POST", "", jsonData, http.StatusNotFound)
+}
+
+func TestQuestionTTL(t *testing.T) {
+	// Initialize logging
+	log.SetFlags(log.Ldate | log.Lshortfile)
+
+	// Set config environment variables
+	setConfigEnv(config.GOCACHE_DRIVER)
+
+	// Initialize controllers object
+	New(config.REFRESH_CONFIG_DATA)
+
+	// The TTL must not be negative
+	jsonData := []byte(`{"questionid": "bbbbtttt", "question": "What is 6 * 7?", "answer": "42", "ttl": -1}`)
+	QuestionTest(t, CreateQuestion, "POST", "", jsonData, http.StatusBadRequest)
+
+	// Only one of ttl and expiresat can be set
+	jsonData = []byte(`{"questionid": "bbbbtttt", "question": "What is 6 * 7?", "answer": "42", "ttl": 60, "expiresat": "2099-01-01T00:00:00Z"}`)
+	QuestionTest(t, CreateQuestion, "POST", "", jsonData, http.StatusBadRequest)
+
+	// The expiry time must be in the future
+	jsonData = []byte(`{"questionid": "bbbbtttt", "question": "What is 6 * 7?", "answer": "42", "expiresat": "2000-01-01T00:00:00Z"}`)
+	QuestionTest(t, CreateQuestion, "POST", "", jsonData, http.StatusBadRequest)
+
+	// Create question with a TTL
+	jsonData = []byte(`{"questionid": "bbbbtttt", "question": "What is 6 * 7?", "answer": "42", "ttl": 60}`)
+	QuestionTest(t, CreateQuestion, "POST", "", jsonData, http.StatusCreated)
+
+	// The remaining TTL is reported
+	rRecorder := QuestionTest(t, GetQuestion, "GET", "bbbbtttt", nil, http.StatusOK)
+
+	var aResponse messages.AnswerResponse
+	unmarshalErr := json.Unmarshal(rRecorder.Body.Bytes(), &aResponse)
+	if unmarshalErr != nil {
+		t.Errorf(unmarshalErr.Error())
+	}
+
+	if aResponse.ExpiresAt == nil || aResponse.TTL < 1 || aResponse.TTL > 60 {
+		t.Errorf("Unexpected expiry: %v, ttl: %d", aResponse.ExpiresAt, aResponse.TTL)
+	}
+}
+
+func TestLeaseQuestion(t *testing.T) {
+	// Initialize logging
+	log.SetFlags(log.Ldate | log.Lshortfile)
+
+	// Set config environment variables
+	setConfigEnv(config.GOCACHE_DRIVER)
+
+	// Initialize controllers object
+	New(config.REFRESH_CONFIG_DATA)
+
+	// Create question
+	jsonData := []byte(`{"questionid": "bbbbllll", "question": "What is 9 - 4?", "answer": "5"}`)
+	QuestionTest(t, CreateQuestion, "POST", "", jsonData, http.StatusCreated)
+
+	// Check out the question
+	rRecorder := QuestionTest(t, CheckoutQuestion, "POST", "bbbbllll", nil, http.StatusOK)
+
+	var aResponse messages.AnswerResponse
+	unmarshalErr := json.Unmarshal(rRecorder.Body.Bytes(), &aResponse)
+	if unmarshalErr != nil {
+		t.Errorf(unmarshalErr.Error())
+	}
+
+	if rRecorder.Code != http.StatusOK || len(aResponse.LeaseToken) == 0 || aResponse.LeaseExpiresAt == nil {
+		t.Errorf("Unexpected lease: %d, token: %s, expiry: %v", rRecorder.Code, aResponse.LeaseToken, aResponse.LeaseExpiresAt)
+	}
+
+	// The checked out question is hidden from the other players
+	QuestionTest(t, ConsumeQuestion, "POST", "bbbbllll", nil, http.StatusNotFound)
+	QuestionTest(t, CheckoutQuestion, "POST", "bbbbllll", nil, http.StatusNotFound)
+
+	// An answer with another lease token is rejected
+	QuestionTest(t, AnswerQuestion, "POST", "bbbbllll", []byte(`{"answer": "5", "leasetoken": "not-the-lease"}`), http.StatusConflict)
+
+	// The answer confirms the lease
+	jsonData = []byte(`{"answer": "5", "leasetoken": "` + aResponse.LeaseToken + `"}`)
+	rRecorder = QuestionTest(t, AnswerQuestion, "POST", "bbbbllll", jsonData, http.StatusOK)
+
+	var caResponse messages.CheckAnswerResponse
+	unmarshalErr = json.Unmarshal(rRecorder.Body.Bytes(), &caResponse)
+	if unmarshalErr != nil {
+		t.Errorf(unmarshalErr.Error())
+	}
+
+	if !caResponse.Correct {
+		t.Errorf("The correct answer was not accepted...")
+	}
+
+	// The question was consumed
+	QuestionTest(t, AnswerQuestion, "POST", "bbbbllll", jsonData, http.StatusNotFound)
+}
+
+func TestQuestionVersion(t *testing.T) {
+	// Initialize logging
+	log.SetFlags(log.Ldate | log.Lshortfile)
+
+	// Set config environment variables
+	setConfigEnv(config.GOCACHE_DRIVER)
+
+	// Initialize controllers object
+	New(config.REFRESH_CONFIG_DATA)
+
+	// Create question, the response carries the ETag of the first version
+	jsonData := []byte(`{"questionid": "bbbbvvvv", "question": "What is 2 * 8?", "answer": "16"}`)
+	rRecorder := QuestionTest(t, CreateQuestion, "POST", "", jsonData, http.StatusCreated)
+	if etag := rRecorder.Header().Get(ETAG_HEADER); etag != `"1"` {
+		t.Errorf("Unexpected ETag: %s", etag)
+	}
+
+	// A replace at another version fails
+	jsonData = []byte(`{"question": "What is 2 * 8?", "answer": "sixteen"}`)
+	ConditionalQuestionTest(t, ReplaceQuestion, "PUT", "bbbbvvvv", `"2"`, jsonData, http.StatusPreconditionFailed)
+
+	// A replace at the stored version increments the version
+	rRecorder = ConditionalQuestionTest(t, ReplaceQuestion, "PUT", "bbbbvvvv", `"1"`, jsonData, http.StatusOK)
+	if etag := rRecorder.Header().Get(ETAG_HEADER); etag != `"2"` {
+		t.Errorf("Unexpected ETag: %s", etag)
+	}
+
+	// The first version is now stale
+	ConditionalQuestionTest(t, PatchQuestion, "PATCH", "bbbbvvvv", `"1"`, []byte(`{"category": "math"}`), http.StatusPreconditionFailed)
+	ConditionalQuestionTest(t, DeleteQuestion, "DELETE", "bbbbvvvv", `"1"`, nil, http.StatusPreconditionFailed)
+
+	// An If-Match header that is not a version never matches
+	ConditionalQuestionTest(t, DeleteQuestion, "DELETE", "bbbbvvvv", `W/"2"`, nil, http.StatusPreconditionFailed)
+
+	// A patch without a precondition is applied to the version it read
+	rRecorder = QuestionTest(t, PatchQuestion, "PATCH", "bbbbvvvv", []byte(`{"category": "math"}`), http.StatusOK)
+	if etag := rRecorder.Header().Get(ETAG_HEADER); etag != `"3"` {
+		t.Errorf("Unexpected ETag: %s", etag)
+	}
+
+	// A replace without a precondition also returns the version it wrote
+	rRecorder = QuestionTest(t, ReplaceQuestion, "PUT", "bbbbvvvv", jsonData, http.StatusOK)
+	if etag := rRecorder.Header().Get(ETAG_HEADER); etag != `"4"` {
+		t.Errorf("Unexpected ETag: %s", etag)
+	}
+
+	ConditionalQuestionTest(t, DeleteQuestion, "DELETE", "bbbbvvvv", `"4"`, nil, http.StatusNoContent)
+}
+
+func TestQuestionErrors(t *testing.T) {
+	// Initialize logging
+	log.SetFlags(log.Ldate | log.Lshortfile)
+
+	// Set config environment variables
+	setConfigEnv(config.GOCACHE_DRIVER)
+
+	// Initialize controllers object
+	New(config.REFRESH_CONFIG_DATA)
+
+	// Test cases, every failed request is answered with an ErrorResponse
+	testCases := []struct {
+		testName       string
+		handlerFunc    http.HandlerFunc
+		method         string
+		questionID     string
+		jsonData       []byte
+		expectedStatus int
+		expectedCode   string
+	}{
+		{testName: "Malformed JSON", handlerFunc: CreateQuestion, method: "POST", jsonData: []byte(`{"questionid": "bbbbeeee", "question": `), expectedStatus: http.StatusBadRequest, expectedCode: messages.INVALID_CODE},
+		{testName: "Wrong JSON type", handlerFunc: ReplaceQuestion, method: "PUT", questionID: "bbbbeeee", jsonData: []byte(`{"question": 42}`), expectedStatus: http.StatusBadRequest, expectedCode: messages.INVALID_CODE},
+		{testName: "Invalid question", handlerFunc: CreateQuestion, method: "POST", jsonData: []byte(`{"questionid": "bbbbeeee", "question": "What is 1 + 1?", "answer": "2", "ttl": -1}`), expectedStatus: http.StatusBadRequest, expectedCode: messages.INVALID_CODE},
+		{testName: "Create question", handlerFunc: CreateQuestion, method: "POST", jsonData: []byte(`{"questionid": "bbbbeeee", "question": "What is 1 + 1?", "answer": "2"}`), expectedStatus: http.StatusCreated},
+		{testName: "Existing question", handlerFunc: CreateQuestion, method: "POST", jsonData: []byte(`{"questionid": "bbbbeeee", "question": "What is 1 + 1?", "answer": "2"}`), expectedStatus: http.StatusConflict, expectedCode: messages.RECORD_EXISTS_CODE},
+		{testName: "Question ID mismatch", handlerFunc: PatchQuestion, method: "PATCH", questionID: "bbbbeeee", jsonData: []byte(`{"questionid": "bbbbffff"}`), expectedStatus: http.StatusBadRequest, expectedCode: messages.INVALID_CODE},
+		{testName: "Lease not held", handlerFunc: AnswerQuestion, method: "POST", questionID: "bbbbeeee", jsonData: []byte(`{"answer": "2", "leasetoken": "not-a-lease"}`), expectedStatus: http.StatusConflict, expectedCode: messages.LEASE_NOT_HELD_CODE},
+		{testName: "Delete question", handlerFunc: DeleteQuestion, method: "DELETE", questionID: "bbbbeeee", expectedStatus: http.StatusNoContent},
+		{testName: "Missing question", handlerFunc: GetQuestion, method: "GET", questionID: "bbbbeeee", expectedStatus: http.StatusNotFound, expectedCode: messages.NOT_FOUND_CODE},
+		{testName: "Missing question update", handlerFunc: ReplaceQuestion, method: "PUT", questionID: "bbbbeeee", jsonData: []byte(`{"question": "What is 1 + 1?", "answer": "2"}`), expectedStatus: http.StatusNotFound, expectedCode: messages.NOT_FOUND_CODE},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			rRecorder := QuestionTest(t, tc.handlerFunc, tc.method, tc.questionID, tc.jsonData, tc.expectedStatus)
+			if len(tc.expectedCode) == 0 {
+				return
+			}
+
+			var eResponse messages.ErrorResponse
+			unmarshalErr := json.Unmarshal(rRecorder.Body.Bytes(), &eResponse)
+			if unmarshalErr != nil {
+				t.Errorf(unmarshalErr.Error())
+			}
+
+			if eResponse.Code != tc.expectedCode || len(eResponse.Message) == 0 {
+				t.Errorf("Unexpected error response: %s, %s", eResponse.Code, eResponse.Message)
+			}
+		})
+	}
+}
+
+func TestQuestionValidation(t *testing.T) {
+	// Initialize logging
+	log.SetFlags(log.Ldate | log.Lshortfile)
+
+	// Set config environment variables, with the allowed categories
+	setConfigEnv(config.GOCACHE_DRIVER)
+	os.Setenv(config.CATEGORIES, "science, math")
+	defer func() {
+		os.Unsetenv(config.CATEGORIES)
+		config.Get().GetData(config.REFRESH_CONFIG_DATA)
+	}()
+	config.Get().GetData(config.REFRESH_CONFIG_DATA)
+
+	// Initialize controllers object
+	New(config.REFRESH_CONFIG_DATA)
+
+	// Test cases, the request fails validation with a field error
+	testCases := []struct {
+		testName      string
+		handlerFunc   http.HandlerFunc
+		method        string
+		questionID    string
+		jsonData      []byte
+		expectedField string
+	}{
+		{testName: "Missing question", handlerFunc: CreateQuestion, method: "POST", jsonData: []byte(`{"category": "math", "answer": "2"}`), expectedField: "question"},
+		{testName: "Missing answer", handlerFunc: CreateQuestion, method: "POST", jsonData: []byte(`{"question": "What is 1 + 1?", "category": "math"}`), expectedField: "answer"},
+		{testName: "Category not allowed", handlerFunc: CreateQuestion, method: "POST", jsonData: []byte(`{"question": "What is 1 + 1?", "category": "history", "answer": "2"}`), expectedField: "category"},
+		{testName: "Question ID format", handlerFunc: CreateQuestion, method: "POST", jsonData: []byte(`{"questionid": "bbbb/hhhh", "question": "What is 1 + 1?", "category": "math", "answer": "2"}`), expectedField: "questionid"},
+		{testName: "Question too long", handlerFunc: ReplaceQuestion, method: "PUT", questionID: "bbbbhhhh", jsonData: []byte(`{"question": "` + strings.Repeat("?", MAX_QUESTION_LENGTH+1) + `", "category": "math", "answer": "2"}`), expectedField: "question"},
+		{testName: "Too many options", handlerFunc: PatchQuestion, method: "PATCH", questionID: "bbbbhhhh", jsonData: []byte(`{"options": ["1", "2", "3", "4", "5", "6", "7", "8", "9", "10", "11"]}`), expectedField: "options"},
+		{testName: "Path question ID format", handlerFunc: GetQuestion, method: "GET", questionID: "bbbb.hhhh", expectedField: "questionid"},
+		{testName: "Excluded question ID format", handlerFunc: Draw, method: "POST", jsonData: []byte(`{"exclude": ["bbbb hhhh"]}`), expectedField: "exclude[0]"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			rRecorder := QuestionTest(t, tc.handlerFunc, tc.method, tc.questionID, tc.jsonData, http.StatusBadRequest)
+
+			var eResponse messages.ErrorResponse
+			unmarshalErr := json.Unmarshal(rRecorder.Body.Bytes(), &eResponse)
+			if unmarshalErr != nil {
+				t.Errorf(unmarshalErr.Error())
+			}
+
+			if eResponse.Code != messages.INVALID_CODE || len(eResponse.Fields) != 1 || eResponse.Fields[0].Field != tc.expectedField {
+				t.Errorf("Unexpected field errors: %s, %+v", eResponse.Code, eResponse.Fields)
+			}
+		})
+	}
+
+	// Unknown fields are rejected
+	jsonData := []byte(`{"question": "What is 1 + 1?", "category": "math", "answer": "2", "points": 10}`)
+	QuestionTest(t, CreateQuestion, "POST", "", jsonData, http.StatusBadRequest)
+
+	// A body over the size limit is rejected
+	jsonData = []byte(`{"question": "` + strings.Repeat("?", int(MAX_REQUEST_BODY_SIZE)) + `"}`)
+	QuestionTest(t, CreateQuestion, "POST", "", jsonData, http.StatusBadRequest)
+
+	// A valid question in an allowed category is created
+	jsonData = []byte(`{"question": "What is 1 + 1?", "category": "math", "answer": "2"}`)
+	QuestionTest(t, CreateQuestion, "POST", "", jsonData, http.StatusCreated)
+}
@@ -2,20 +2,62 @@ package controllers
 
 import (
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
+	"strconv"
 
 	"github.com/sflewis2970/datastore-service/models/messages"
+	"github.com/sflewis2970/datastore-service/tracing"
 )
 
+const BATCH_DECODE_ERROR string = "Request body must be a JSON array of questions: "
+
+// Decode the JSON array of questions of a batch request, a body that cannot be decoded is
+// answered with a bad request
+func decodeBatch(rw http.ResponseWriter, r *http.Request) ([]messages.QuestionRequest, bool) {
+	var qRequests []messages.QuestionRequest
+
+	_, span := tracing.Start(r.Context(), "decode request")
+	decodeErr := newDecoder(rw, r, MAX_BATCH_BODY_SIZE).Decode(&qRequests)
+	if decodeErr != nil {
+		tracing.End(span, decodeErr)
+		writeError(rw, "", invalidRequestError(errors.New(BATCH_DECODE_ERROR+decodeErr.Error())))
+		return nil, false
+	}
+	span.SetAttributes(tracing.RecordsKey.Int(len(qRequests)))
+	span.End()
+
+	return qRequests, true
+}
+
+// Insert the questions of a batch. The field errors of every question are passed to the model,
+// which reports the questions failing validation without sending them to the datastore.
+func insertBatch(rw http.ResponseWriter, r *http.Request, qRequests []messages.QuestionRequest) {
+	fieldErrs := make([][]messages.FieldError, len(qRequests))
+	for idx := range qRequests {
+		fieldErrs[idx] = questionFieldErrors(qRequests[idx], false)
+	}
+
+	// Send Insert Batch request
+	biResponse, insertErr := controller.dataModel.InsertBatch(r.Context(), qRequests, fieldErrs)
+	if insertErr != nil {
+		writeError(rw, "", insertErr)
+		return
+	}
+
+	// Write JSON to stream
+	json.NewEncoder(rw).Encode(biResponse)
+}
+
 func Status(rw http.ResponseWriter, r *http.Request) {
 	// Display a log message
 	log.Print("client requesting server status...")
 
 	// Get Datastore Server Status
-	sResponse, statusErr := controller.dataModel.Status()
+	sResponse, statusErr := controller.dataModel.Status(r.Context())
 	if statusErr != nil {
-		rw.WriteHeader(http.StatusInternalServerError)
+		rw.WriteHeader(errorStatus(statusErr))
 	}
 
 	// Write JSON to stream
@@ -23,9 +65,6 @@ func Status(rw http.ResponseWriter, r *http.Request) {
 }
 
 func Insert(rw http.ResponseWriter, r *http.Request) {
-	controller.dbMutex.Lock()
-	defer controller.dbMutex.Unlock()
-
 	// Display a log message
 	log.Print("Insert action requested...")
 
@@ -33,23 +72,115 @@ func Insert(rw http.ResponseWriter, r *http.Request) {
 	var qRequest messages.QuestionRequest
 
 	// Decode request into JSON format
-	json.NewDecoder(r.Body).Decode(&qRequest)
+	if !decodeRequest(rw, r, &qRequest) {
+		return
+	}
+
+	if !validRequest(rw, qRequest.QuestionID, validateQuestionRequest(qRequest)) {
+		return
+	}
+
+	unlock, lockErr := controller.questionLocks.lock(r.Context(), qRequest.QuestionID)
+	if lockErr != nil {
+		writeError(rw, qRequest.QuestionID, lockErr)
+		return
+	}
+	defer unlock()
 
 	// Send Insert request
-	qResponse, insertErr := controller.dataModel.Insert(qRequest)
+	qResponse, insertErr := controller.dataModel.Insert(r.Context(), qRequest)
 	if insertErr != nil {
-		rw.WriteHeader(http.StatusInternalServerError)
+		writeError(rw, qRequest.QuestionID, insertErr)
+		return
 	}
 
 	// Write JSON to stream
 	json.NewEncoder(rw).Encode(qResponse)
 }
 
-// Get receives a request and
-func Get(rw http.ResponseWriter, r *http.Request) {
-	controller.dbMutex.Lock()
-	defer controller.dbMutex.Unlock()
+// InsertBatch inserts the JSON array of questions in the body, the response holds a result per question
+func InsertBatch(rw http.ResponseWriter, r *http.Request) {
+	// Display a log message
+	log.Print("Insert batch action requested...")
+
+	// Decode request into JSON format
+	qRequests, decoded := decodeBatch(rw, r)
+	if !decoded {
+		return
+	}
+
+	// Send Insert Batch request
+	insertBatch(rw, r, qRequests)
+}
+
+// List query parameters
+const (
+	CATEGORY_PARAM string = "category"
+	CURSOR_PARAM   string = "cursor"
+	LIMIT_PARAM    string = "limit"
+)
+
+const LIMIT_PARAM_ERROR string = "limit must be a number: "
+
+// List returns a page of questions, filtered by the category query parameter. The nextcursor of
+// the response is passed in the cursor query parameter to get the next page.
+func List(rw http.ResponseWriter, r *http.Request) {
+	// Display a log message
+	log.Print("List action requested...")
+
+	query := r.URL.Query()
+
+	limit := 0
+	if len(query.Get(LIMIT_PARAM)) > 0 {
+		var convErr error
+		limit, convErr = strconv.Atoi(query.Get(LIMIT_PARAM))
+		if convErr != nil {
+			limitErr := errors.New(LIMIT_PARAM_ERROR + query.Get(LIMIT_PARAM))
+			writeError(rw, "", messages.NewError(messages.ErrInvalid, messages.INVALID_CODE, messages.INVALID_LIST_REQUEST_MSG, limitErr))
+			return
+		}
+	}
+
+	// Send List request
+	lResponse, listErr := controller.dataModel.List(r.Context(), query.Get(CATEGORY_PARAM), query.Get(CURSOR_PARAM), limit)
+	if listErr != nil {
+		writeError(rw, "", listErr)
+		return
+	}
+
+	// Write JSON to stream
+	json.NewEncoder(rw).Encode(lResponse)
+}
+
+// Draw consumes a random question, the body optionally holds a category and question IDs to exclude
+func Draw(rw http.ResponseWriter, r *http.Request) {
+	// Display a log message
+	log.Print("Draw action requested...")
+
+	// Draw Request
+	var dRequest messages.DrawRequest
+
+	// Decode request into JSON format
+	if !decodeRequest(rw, r, &dRequest) {
+		return
+	}
+
+	if !validRequest(rw, "", validateDrawRequest(dRequest)) {
+		return
+	}
+
+	// Send Draw request
+	aResponse, drawErr := controller.dataModel.Draw(r.Context(), dRequest)
+	if drawErr != nil {
+		writeError(rw, "", drawErr)
+		return
+	}
+
+	// Write JSON to stream
+	json.NewEncoder(rw).Encode(aResponse)
+}
 
+func Get(rw http.ResponseWriter, r *http.Request) {
 	// Answer Request
 	var aRequest messages.AnswerRequest
 
@@ -57,35 +188,104 @@ func Get(rw http.ResponseWriter, r *http.Request) {
 	log.Print("data received from client...")
 
 	// Decode request into JSON format
-	json.NewDecoder(r.Body).Decode(&aRequest)
+	if !decodeRequest(rw, r, &aRequest) {
+		return
+	}
+
+	if !validRequest(rw, aRequest.QuestionID, validateQuestionID(aRequest.QuestionID)) {
+		return
+	}
+
+	unlock, lockErr := controller.questionLocks.lock(r.Context(), aRequest.QuestionID)
+	if lockErr != nil {
+		writeError(rw, aRequest.QuestionID, lockErr)
+		return
+	}
+	defer unlock()
 
 	// Send Answer Request
-	aResponse, getErr := controller.dataModel.Get(aRequest)
+	aResponse, getErr := controller.dataModel.Get(r.Context(), aRequest)
 	if getErr != nil {
-		rw.WriteHeader(http.StatusInternalServerError)
+		writeError(rw, aRequest.QuestionID, getErr)
+		return
 	}
+	setETag(rw, aResponse.Version)
 
 	// Write JSON to stream
 	json.NewEncoder(rw).Encode(aResponse)
 }
 
-func Update(rw http.ResponseWriter, r *http.Request) {
-	controller.dbMutex.Lock()
-	defer controller.dbMutex.Unlock()
+func CheckAnswer(rw http.ResponseWriter, r *http.Request) {
+	// Check Answer Request
+	var caRequest messages.CheckAnswerRequest
+
+	// Display a log message
+	log.Print("received answer from client...")
+
+	// Decode request into JSON format
+	if !decodeRequest(rw, r, &caRequest) {
+		return
+	}
+
+	if !validRequest(rw, caRequest.QuestionID, validateCheckAnswerRequest(caRequest)) {
+		return
+	}
 
+	unlock, lockErr := controller.questionLocks.lock(r.Context(), caRequest.QuestionID)
+	if lockErr != nil {
+		writeError(rw, caRequest.QuestionID, lockErr)
+		return
+	}
+	defer unlock()
+
+	// Send Check Answer Request
+	caResponse, checkErr := controller.dataModel.CheckAnswer(r.Context(), caRequest)
+	if checkErr != nil {
+		writeError(rw, caRequest.QuestionID, checkErr)
+		return
+	}
+
+	// Write JSON to stream
+	json.NewEncoder(rw).Encode(caResponse)
+}
+
+func Update(rw http.ResponseWriter, r *http.Request) {
 	var question messages.QuestionRequest
 
 	// Display a log message
 	log.Print("received update request from client...")
 
 	// Decode request into JSON format
-	json.NewDecoder(r.Body).Decode(&question)
+	if !decodeRequest(rw, r, &question) {
+		return
+	}
+
+	if !validRequest(rw, question.QuestionID, validateQuestionRequest(question)) {
+		return
+	}
+
+	// The If-Match header takes precedence over the version in the body
+	version, versionOk := ifMatchVersion(rw, r, question.QuestionID)
+	if !versionOk {
+		return
+	} else if version > 0 {
+		question.Version = version
+	}
+
+	unlock, lockErr := controller.questionLocks.lock(r.Context(), question.QuestionID)
+	if lockErr != nil {
+		writeError(rw, question.QuestionID, lockErr)
+		return
+	}
+	defer unlock()
 
 	// Update question
-	qResponse, updateErr := controller.dataModel.Update(question)
+	qResponse, updateErr := controller.dataModel.Update(r.Context(), question)
 	if updateErr != nil {
-		rw.WriteHeader(http.StatusInternalServerError)
+		writeError(rw, question.QuestionID, updateErr)
+		return
 	}
+	setETag(rw, qResponse.Version)
 
 	// Display a log message
 	log.Print("sending response to client...")
@@ -94,20 +294,78 @@ func Update(rw http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(rw).Encode(qResponse)
 }
 
-func Delete(rw http.ResponseWriter, r *http.Request) {
-	controller.dbMutex.Lock()
-	defer controller.dbMutex.Unlock()
+// Patch updates the fields provided in the body of the question with the body questionid, the
+// remaining fields keep their stored values
+func Patch(rw http.ResponseWriter, r *http.Request) {
+	var question messages.QuestionRequest
 
+	// Display a log message
+	log.Print("received patch request from client...")
+
+	// Decode request into JSON format
+	if !decodeRequest(rw, r, &question) {
+		return
+	}
+
+	// The question ID is validated with the fields of the patch
+	if !validRequest(rw, question.QuestionID, validatePatchRequest(question)) {
+		return
+	}
+
+	// The If-Match header takes precedence over the version in the body
+	version, versionOk := ifMatchVersion(rw, r, question.QuestionID)
+	if !versionOk {
+		return
+	} else if version > 0 {
+		question.Version = version
+	}
+
+	unlock, lockErr := controller.questionLocks.lock(r.Context(), question.QuestionID)
+	if lockErr != nil {
+		writeError(rw, question.QuestionID, lockErr)
+		return
+	}
+	defer unlock()
+
+	// Patch question
+	qResponse, patchErr := controller.dataModel.Patch(r.Context(), question)
+	if patchErr != nil {
+		writeError(rw, question.QuestionID, patchErr)
+		return
+	}
+	setETag(rw, qResponse.Version)
+
+	// Write JSON to stream
+	json.NewEncoder(rw).Encode(qResponse)
+}
+
+func Delete(rw http.ResponseWriter, r *http.Request) {
 	// Display a log message
 	log.Print("data received from client...")
 
 	// Get question ID from query parameter
 	questionID := r.URL.Query().Get("questionid")
+	if !validRequest(rw, questionID, validateQuestionID(questionID)) {
+		return
+	}
+
+	version, versionOk := ifMatchVersion(rw, r, questionID)
+	if !versionOk {
+		return
+	}
+
+	unlock, lockErr := controller.questionLocks.lock(r.Context(), questionID)
+	if lockErr != nil {
+		writeError(rw, questionID, lockErr)
+		return
+	}
+	defer unlock()
 
 	// Send delete request
-	qResponse, delErr := controller.dataModel.Delete(questionID)
+	qResponse, delErr := controller.dataModel.Delete(r.Context(), questionID, version)
 	if delErr != nil {
-		rw.WriteHeader(http.StatusInternalServerError)
+		writeError(rw, questionID, delErr)
+		return
 	}
 
 	// Display a log message
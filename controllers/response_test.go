@@ -37,6 +37,13 @@ func setConfigEnv(activeDriver string) error {
 		return setErr
 	}
 
+	// Set answer matcher environment variable
+	setErr = os.Setenv(config.MATCHER_MAX_DISTANCE, "1")
+	if setErr != nil {
+		log.Print("Error setting config vars...")
+		return setErr
+	}
+
 	// Set Go-cache environment variable
 	switch os.Getenv(config.ACTIVEDRIVER) {
 	case "go-cache":
@@ -102,7 +109,7 @@ func InsertTest(t *testing.T, jsonData []byte) []byte {
 	return rRecorder.Body.Bytes()
 }
 
-func GetTest(t *testing.T, jsonData []byte) []byte {
+func GetTest(t *testing.T, jsonData []byte, expectedStatus int) []byte {
 	// Create new request
 	request, reqErr := http.NewRequest("GET", "/api/v1/ds/get", bytes.NewBuffer(jsonData))
 	if reqErr != nil {
@@ -116,8 +123,74 @@ func GetTest(t *testing.T, jsonData []byte) []byte {
 
 	// Check response code
 	status := rRecorder.Code
-	if status != http.StatusOK {
-		t.Errorf("handler returned invalid status code: got %d, expected: %d\n", status, http.StatusOK)
+	if status != expectedStatus {
+		t.Errorf("handler returned invalid status code: got %d, expected: %d\n", status, expectedStatus)
+	}
+
+	// Unmarshal JSON
+	return rRecorder.Body.Bytes()
+}
+
+func CheckAnswerTest(t *testing.T, jsonData []byte, expectedStatus int) []byte {
+	// Create new request
+	request, reqErr := http.NewRequest("POST", "/api/v1/ds/checkanswer", bytes.NewBuffer(jsonData))
+	if reqErr != nil {
+		t.Errorf("Could not create request.\n")
+	}
+
+	// Setup recoder
+	rRecorder := httptest.NewRecorder()
+	handler := http.HandlerFunc(CheckAnswer)
+	handler.ServeHTTP(rRecorder, request)
+
+	// Check response code
+	status := rRecorder.Code
+	if status != expectedStatus {
+		t.Errorf("handler returned invalid status code: got %d, expected: %d\n", status, expectedStatus)
+	}
+
+	// Unmarshal JSON
+	return rRecorder.Body.Bytes()
+}
+
+func UpdateTest(t *testing.T, jsonData []byte, expectedStatus int) []byte {
+	// Create new request
+	request, reqErr := http.NewRequest("PUT", "/api/v1/ds/update", bytes.NewBuffer(jsonData))
+	if reqErr != nil {
+		t.Errorf("Could not create request.\n")
+	}
+
+	// Setup recoder
+	rRecorder := httptest.NewRecorder()
+	handler := http.HandlerFunc(Update)
+	handler.ServeHTTP(rRecorder, request)
+
+	// Check response code
+	status := rRecorder.Code
+	if status != expectedStatus {
+		t.Errorf("handler returned invalid status code: got %d, expected: %d\n", status, expectedStatus)
+	}
+
+	// Unmarshal JSON
+	return rRecorder.Body.Bytes()
+}
+
+func DeleteTest(t *testing.T, questionID string, expectedStatus int) []byte {
+	// Create new request
+	request, reqErr := http.NewRequest("DELETE", "/api/v1/ds/delete?questionid="+questionID, nil)
+	if reqErr != nil {
+		t.Errorf("Could not create request.\n")
+	}
+
+	// Setup recoder
+	rRecorder := httptest.NewRecorder()
+	handler := http.HandlerFunc(Delete)
+	handler.ServeHTTP(rRecorder, request)
+
+	// Check response code
+	status := rRecorder.Code
+	if status != expectedStatus {
+		t.Errorf("handler returned invalid status code: got %d, expected: %d\n", status, expectedStatus)
 	}
 
 	// Unmarshal JSON
@@ -207,6 +280,58 @@ func TestInsert(t *testing.T) {
 	if len(qResponse.Error) > 0 {
 		t.Errorf("An error occurred inserting record...")
 	}
+
+	// Inserting the question again conflicts with the stored question
+	request, reqErr := http.NewRequest("GET", "/api/v1/ds/insert", bytes.NewBuffer(jsonData))
+	if reqErr != nil {
+		t.Errorf("Could not create request.\n")
+	}
+
+	rRecorder := httptest.NewRecorder()
+	http.HandlerFunc(Insert).ServeHTTP(rRecorder, request)
+
+	if rRecorder.Code != http.StatusConflict {
+		t.Errorf("handler returned invalid status code: got %d, expected: %d\n", rRecorder.Code, http.StatusConflict)
+	}
+
+	// An upsert overwrites the stored question
+	qRequest.Upsert = true
+	jsonData, marshalErr = json.Marshal(qRequest)
+	if marshalErr != nil {
+		t.Errorf("New request error: %s", marshalErr.Error())
+	}
+
+	InsertTest(t, jsonData)
+
+	// A malformed request is rejected instead of inserting an empty question
+	request, reqErr = http.NewRequest("GET", "/api/v1/ds/insert", bytes.NewBufferString(`{"questionid": `))
+	if reqErr != nil {
+		t.Errorf("Could not create request.\n")
+	}
+
+	rRecorder = httptest.NewRecorder()
+	http.HandlerFunc(Insert).ServeHTTP(rRecorder, request)
+
+	var eResponse messages.ErrorResponse
+	json.NewDecoder(rRecorder.Body).Decode(&eResponse)
+	if rRecorder.Code != http.StatusBadRequest || eResponse.Code != messages.INVALID_CODE {
+		t.Errorf("Malformed request not rejected: %d, %s", rRecorder.Code, eResponse.Code)
+	}
+
+	// A question without a question ID is rejected with a field error
+	request, reqErr = http.NewRequest("GET", "/api/v1/ds/insert", bytes.NewBufferString(`{"question": "Who was Pandora?", "answer": "The first woman"}`))
+	if reqErr != nil {
+		t.Errorf("Could not create request.\n")
+	}
+
+	rRecorder = httptest.NewRecorder()
+	http.HandlerFunc(Insert).ServeHTTP(rRecorder, request)
+
+	eResponse = messages.ErrorResponse{}
+	json.NewDecoder(rRecorder.Body).Decode(&eResponse)
+	if rRecorder.Code != http.StatusBadRequest || len(eResponse.Fields) != 1 || eResponse.Fields[0].Field != "questionid" {
+		t.Errorf("Question without a question ID not rejected: %d, %+v", rRecorder.Code, eResponse.Fields)
+	}
 }
 
 func TestGetBeforeInsert(t *testing.T) {
@@ -231,7 +356,7 @@ func TestGetBeforeInsert(t *testing.T) {
 	}
 
 	// Send AddQuestion request to datastore
-	bodyBytes := GetTest(t, jsonData)
+	bodyBytes := GetTest(t, jsonData, http.StatusNotFound)
 
 	var aResponse messages.AnswerResponse
 	unmarshalErr := json.Unmarshal(bodyBytes, &aResponse)
@@ -303,7 +428,7 @@ func TestGetAfterInsert(t *testing.T) {
 	}
 
 	// Send CheckAnswer request to datastore
-	bodyBytes = GetTest(t, jsonData)
+	bodyBytes = GetTest(t, jsonData, http.StatusOK)
 
 	// Build AnswerResponse
 	var aResponse messages.AnswerResponse
@@ -371,7 +496,7 @@ func TestGetAfterDelete(t *testing.T) {
 	}
 
 	// Send Get request to datastore
-	bodyBytes = GetTest(t, jsonData)
+	bodyBytes = GetTest(t, jsonData, http.StatusOK)
 
 	// Build AnswerResponse
 	var aResponse messages.AnswerResponse
@@ -386,7 +511,7 @@ func TestGetAfterDelete(t *testing.T) {
 	}
 
 	// Send Get(2nd) request to datastore
-	bodyBytes = GetTest(t, jsonData)
+	bodyBytes = GetTest(t, jsonData, http.StatusNotFound)
 
 	// Build AnswerResponse
 	unmarshalErr = json.Unmarshal(bodyBytes, &aResponse)
@@ -401,3 +526,466 @@ func TestGetAfterDelete(t *testing.T) {
 		t.Errorf("'No results returned' message did NOT returned...")
 	}
 }
+
+func TestUpdateBeforeInsert(t *testing.T) {
+	// Initialize logging
+	log.SetFlags(log.Ldate | log.Lshortfile)
+
+	// Set config environment variables
+	setConfigEnv(config.GOCACHE_DRIVER)
+
+	// Initialize controllers object
+	New(config.REFRESH_CONFIG_DATA)
+
+	// Simulate a client sending an update for a question that has not been stored
+	var qRequest messages.QuestionRequest
+
+	// Build Question Request
+	qRequest.QuestionID = "aaaaffff"
+	qRequest.Question = "According to Greek mythology, who was the first woman on earth?"
+	qRequest.Category = "mythology"
+	qRequest.Answer = "Pandora"
+
+	// Marshal QuestionRequest
+	jsonData, marshalErr := json.Marshal(qRequest)
+	if marshalErr != nil {
+		t.Errorf("New request error: %s", marshalErr.Error())
+	}
+
+	// Send Update request to datastore
+	bodyBytes := UpdateTest(t, jsonData, http.StatusNotFound)
+
+	// Unmarshal data to QuestionResponse
+	var qResponse messages.QuestionResponse
+	unmarshalErr := json.Unmarshal(bodyBytes, &qResponse)
+	if unmarshalErr != nil {
+		t.Errorf(unmarshalErr.Error())
+	}
+
+	// Check Message field
+	if qResponse.Message != messages.NO_RESULTS_RETURNED_MSG {
+		t.Errorf("The message unexpectedly returned the wrong message, message returned: %s", qResponse.Message)
+	}
+}
+
+func TestUpdateAfterInsert(t *testing.T) {
+	// Initialize logging
+	log.SetFlags(log.Ldate | log.Lshortfile)
+
+	// Set config environment variables
+	setConfigEnv(config.GOCACHE_DRIVER)
+
+	// Initialize controllers object
+	New(config.REFRESH_CONFIG_DATA)
+
+	// Simulate a client sending a QuestionRequest to the datastore server
+	var qRequest messages.QuestionRequest
+
+	// Build Question Request
+	qRequest.QuestionID = "aaaagggg"
+	qRequest.Question = "According to Greek mythology, who was the first woman on earth?"
+	qRequest.Category = "general"
+	qRequest.Answer = "Pandora"
+
+	// Marshal QuestionRequest
+	jsonData, marshalErr := json.Marshal(qRequest)
+	if marshalErr != nil {
+		t.Errorf("New request error: %s", marshalErr.Error())
+	}
+
+	// Send Insert request to datastore
+	InsertTest(t, jsonData)
+
+	// Update the category of the stored question
+	qRequest.Category = "mythology"
+	jsonData, marshalErr = json.Marshal(qRequest)
+	if marshalErr != nil {
+		t.Errorf("New request error: %s", marshalErr.Error())
+	}
+
+	// Send Update request to datastore
+	bodyBytes := UpdateTest(t, jsonData, http.StatusOK)
+
+	// Unmarshal data to QuestionResponse
+	var qResponse messages.QuestionResponse
+	unmarshalErr := json.Unmarshal(bodyBytes, &qResponse)
+	if unmarshalErr != nil {
+		t.Errorf(unmarshalErr.Error())
+	}
+
+	// Check RecordsAffected field
+	if qResponse.RecordsAffected != "1" {
+		t.Errorf("Unexpected number of records affected: %s", qResponse.RecordsAffected)
+	}
+
+	// Send Get request to datastore
+	var aRequest messages.AnswerRequest
+	aRequest.QuestionID = qRequest.QuestionID
+	jsonData, marshalErr = json.Marshal(aRequest)
+	if marshalErr != nil {
+		t.Errorf("New request error: %s", marshalErr.Error())
+	}
+
+	bodyBytes = GetTest(t, jsonData, http.StatusOK)
+
+	var aResponse messages.AnswerResponse
+	unmarshalErr = json.Unmarshal(bodyBytes, &aResponse)
+	if unmarshalErr != nil {
+		t.Errorf(unmarshalErr.Error())
+	}
+
+	// Check Category field
+	if aResponse.Category != qRequest.Category {
+		t.Errorf("The category was not updated, category returned: %s", aResponse.Category)
+	}
+}
+
+func TestPatchAfterInsert(t *testing.T) {
+	// Initialize logging
+	log.SetFlags(log.Ldate | log.Lshortfile)
+
+	// Set config environment variables
+	setConfigEnv(config.GOCACHE_DRIVER)
+
+	// Initialize controllers object
+	New(config.REFRESH_CONFIG_DATA)
+
+	// Build Question Request
+	var qRequest messages.QuestionRequest
+	qRequest.QuestionID = "aaaapppp"
+	qRequest.Question = "According to Greek mythology, who was the first woman on earth?"
+	qRequest.Category = "general"
+	qRequest.Answer = "Pandora"
+
+	// Marshal QuestionRequest
+	jsonData, marshalErr := json.Marshal(qRequest)
+	if marshalErr != nil {
+		t.Errorf("New request error: %s", marshalErr.Error())
+	}
+
+	// Send Insert request to datastore
+	InsertTest(t, jsonData)
+
+	// Patch only the category
+	request, reqErr := http.NewRequest("PATCH", "/api/v1/ds/update", bytes.NewBufferString(`{"questionid": "aaaapppp", "category": "mythology"}`))
+	if reqErr != nil {
+		t.Errorf("Could not create request.\n")
+	}
+
+	rRecorder := httptest.NewRecorder()
+	http.HandlerFunc(Patch).ServeHTTP(rRecorder, request)
+	if rRecorder.Code != http.StatusOK {
+		t.Errorf("handler returned invalid status code: got %d, expected: %d\n", rRecorder.Code, http.StatusOK)
+	}
+
+	// The fields left out of the patch keep their stored values
+	bodyBytes := GetTest(t, []byte(`{"questionid": "aaaapppp"}`), http.StatusOK)
+
+	var aResponse messages.AnswerResponse
+	unmarshalErr := json.Unmarshal(bodyBytes, &aResponse)
+	if unmarshalErr != nil {
+		t.Errorf(unmarshalErr.Error())
+	}
+
+	if aResponse.Category != "mythology" || aResponse.Question != qRequest.Question || aResponse.Answer != qRequest.Answer {
+		t.Errorf("Unexpected patched question: %+v", aResponse)
+	}
+}
+
+func TestDeleteBeforeInsert(t *testing.T) {
+	// Initialize logging
+	log.SetFlags(log.Ldate | log.Lshortfile)
+
+	// Set config environment variables
+	setConfigEnv(config.GOCACHE_DRIVER)
+
+	// Initialize controllers object
+	New(config.REFRESH_CONFIG_DATA)
+
+	// Send Delete request for a question that has not been stored
+	bodyBytes := DeleteTest(t, "aaaahhhh", http.StatusNotFound)
+
+	// Unmarshal data to QuestionResponse
+	var qResponse messages.QuestionResponse
+	unmarshalErr := json.Unmarshal(bodyBytes, &qResponse)
+	if unmarshalErr != nil {
+		t.Errorf(unmarshalErr.Error())
+	}
+
+	// Check Message field
+	if qResponse.Message != messages.NO_RESULTS_RETURNED_MSG {
+		t.Errorf("The message unexpectedly returned the wrong message, message returned: %s", qResponse.Message)
+	}
+}
+
+func TestDeleteAfterInsert(t *testing.T) {
+	// Initialize logging
+	log.SetFlags(log.Ldate | log.Lshortfile)
+
+	// Set config environment variables
+	setConfigEnv(config.GOCACHE_DRIVER)
+
+	// Initialize controllers object
+	New(config.REFRESH_CONFIG_DATA)
+
+	// Simulate a client sending a QuestionRequest to the datastore server
+	var qRequest messages.QuestionRequest
+
+	// Build Question Request
+	qRequest.QuestionID = "aaaaiiii"
+	qRequest.Question = "According to Greek mythology, who was the first woman on earth?"
+	qRequest.Category = "general"
+	qRequest.Answer = "Pandora"
+
+	// Marshal QuestionRequest
+	jsonData, marshalErr := json.Marshal(qRequest)
+	if marshalErr != nil {
+		t.Errorf("New request error: %s", marshalErr.Error())
+	}
+
+	// Send Insert request to datastore
+	InsertTest(t, jsonData)
+
+	// Send Delete request to datastore
+	bodyBytes := DeleteTest(t, qRequest.QuestionID, http.StatusOK)
+
+	// Unmarshal data to QuestionResponse
+	var qResponse messages.QuestionResponse
+	unmarshalErr := json.Unmarshal(bodyBytes, &qResponse)
+	if unmarshalErr != nil {
+		t.Errorf(unmarshalErr.Error())
+	}
+
+	// Check RecordsAffected field
+	if qResponse.RecordsAffected != "1" {
+		t.Errorf("Unexpected number of records affected: %s", qResponse.RecordsAffected)
+	}
+
+	// A second delete finds nothing to remove
+	DeleteTest(t, qRequest.QuestionID, http.StatusNotFound)
+}
+
+func TestPeekAfterInsert(t *testing.T) {
+	// Initialize logging
+	log.SetFlags(log.Ldate | log.Lshortfile)
+
+	// Set config environment variables
+	setConfigEnv(config.GOCACHE_DRIVER)
+
+	// Initialize controllers object
+	New(config.REFRESH_CONFIG_DATA)
+
+	// Simulate a client sending a QuestionRequest to the datastore server
+	var qRequest messages.QuestionRequest
+
+	// Build Question Request
+	qRequest.QuestionID = "aaaajjjj"
+	qRequest.Question = "According to Greek mythology, who was the first woman on earth?"
+	qRequest.Category = "general"
+	qRequest.Answer = "Pandora"
+
+	// Marshal QuestionRequest
+	jsonData, marshalErr := json.Marshal(qRequest)
+	if marshalErr != nil {
+		t.Errorf("New request error: %s", marshalErr.Error())
+	}
+
+	// Send Insert request to datastore
+	InsertTest(t, jsonData)
+
+	// Build a peek AnswerRequest
+	var aRequest messages.AnswerRequest
+	aRequest.QuestionID = qRequest.QuestionID
+	aRequest.Peek = true
+	jsonData, marshalErr = json.Marshal(aRequest)
+	if marshalErr != nil {
+		t.Errorf("New request error: %s", marshalErr.Error())
+	}
+
+	// Peeking twice returns the question both times
+	for idx := 0; idx < 2; idx++ {
+		bodyBytes := GetTest(t, jsonData, http.StatusOK)
+
+		var aResponse messages.AnswerResponse
+		unmarshalErr := json.Unmarshal(bodyBytes, &aResponse)
+		if unmarshalErr != nil {
+			t.Errorf(unmarshalErr.Error())
+		}
+
+		if aResponse.Answer != qRequest.Answer {
+			t.Errorf("Unexpectedly, question was NOT returned by peek %d...", idx+1)
+		}
+	}
+
+	// A regular get still consumes the question
+	aRequest.Peek = false
+	jsonData, marshalErr = json.Marshal(aRequest)
+	if marshalErr != nil {
+		t.Errorf("New request error: %s", marshalErr.Error())
+	}
+
+	GetTest(t, jsonData, http.StatusOK)
+
+	var aResponse messages.AnswerResponse
+	unmarshalErr := json.Unmarshal(GetTest(t, jsonData, http.StatusNotFound), &aResponse)
+	if unmarshalErr != nil {
+		t.Errorf(unmarshalErr.Error())
+	}
+
+	if aResponse.Message != messages.NO_RESULTS_RETURNED_MSG {
+		t.Errorf("'No results returned' message did NOT returned...")
+	}
+}
+
+func TestCheckAnswer(t *testing.T) {
+	// Initialize logging
+	log.SetFlags(log.Ldate | log.Lshortfile)
+
+	// Set config environment variables
+	setConfigEnv(config.GOCACHE_DRIVER)
+
+	// Initialize controllers object
+	New(config.REFRESH_CONFIG_DATA)
+
+	// Test cases
+	testCases := []struct {
+		testName        string
+		questionID      string
+		answer          string
+		expectedCorrect bool
+		expectedMessage string
+	}{
+		{testName: "Correct answer", questionID: "aaaakkkk", answer: "Pandora", expectedCorrect: true, expectedMessage: config.DEFAULT_CONGRATS_MESSAGE},
+		{testName: "Wrong answer", questionID: "aaaallll", answer: "Athena", expectedCorrect: false, expectedMessage: config.DEFAULT_TRY_AGAIN_MESSAGE},
+		{testName: "Normalized answer", questionID: "aaaammmm", answer: " pándora. ", expectedCorrect: true, expectedMessage: config.DEFAULT_CONGRATS_MESSAGE},
+		{testName: "Misspelled answer", questionID: "aaaannnn", answer: "Pandra", expectedCorrect: true, expectedMessage: config.DEFAULT_CONGRATS_MESSAGE},
+		{testName: "Alternate answer", questionID: "aaaaoooo", answer: "anesidora", expectedCorrect: true, expectedMessage: config.DEFAULT_CONGRATS_MESSAGE},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			// Build Question Request
+			var qRequest messages.QuestionRequest
+			qRequest.QuestionID = tc.questionID
+			qRequest.Question = "According to Greek mythology, who was the first woman on earth?"
+			qRequest.Category = "general"
+			qRequest.Answer = "Pandora"
+			qRequest.AlternateAnswers = messages.StringList{"Anesidora"}
+
+			jsonData, marshalErr := json.Marshal(qRequest)
+			if marshalErr != nil {
+				t.Errorf("New request error: %s", marshalErr.Error())
+			}
+
+			// Send Insert request to datastore
+			InsertTest(t, jsonData)
+
+			// Build Check Answer Request
+			var caRequest messages.CheckAnswerRequest
+			caRequest.QuestionID = tc.questionID
+			caRequest.Answer = tc.answer
+
+			jsonData, marshalErr = json.Marshal(caRequest)
+			if marshalErr != nil {
+				t.Errorf("New request error: %s", marshalErr.Error())
+			}
+
+			// Send Check Answer request to datastore
+			var caResponse messages.CheckAnswerResponse
+			unmarshalErr := json.Unmarshal(CheckAnswerTest(t, jsonData, http.StatusOK), &caResponse)
+			if unmarshalErr != nil {
+				t.Errorf(unmarshalErr.Error())
+			}
+
+			if caResponse.Correct != tc.expectedCorrect {
+				t.Errorf("Unexpected verdict: got %t, expected: %t", caResponse.Correct, tc.expectedCorrect)
+			}
+
+			if caResponse.Message != tc.expectedMessage {
+				t.Errorf("Unexpected message: %s", caResponse.Message)
+			}
+
+			// The question is consumed by the check
+			unmarshalErr = json.Unmarshal(CheckAnswerTest(t, jsonData, http.StatusNotFound), &caResponse)
+			if unmarshalErr != nil {
+				t.Errorf(unmarshalErr.Error())
+			}
+
+			if caResponse.Message != messages.NO_RESULTS_RETURNED_MSG {
+				t.Errorf("'No results returned' message did NOT returned...")
+			}
+		})
+	}
+}
+
+func TestInsertBatch(t *testing.T) {
+	// Initialize logging
+	log.SetFlags(log.Ldate | log.Lshortfile)
+
+	// Set config environment variables
+	setConfigEnv(config.GOCACHE_DRIVER)
+
+	// Initialize controllers object
+	New(config.REFRESH_CONFIG_DATA)
+
+	// Build batch, only the first question is valid
+	qRequests := []messages.QuestionRequest{
+		{QuestionID: "aaaapppp", Question: "What is 4 / 2?", Answer: "2"},
+		{Question: "What is 6 / 2?", Answer: "3"},
+		{QuestionID: "aaaapppp", Question: "What is 8 / 2?", Answer: "4"},
+		{QuestionID: "aaaaqqqq", Question: "What is 10 / 2?", Answer: "5", Options: messages.StringList{"4", "6"}},
+	}
+	expectedStatus := []string{messages.BATCH_ITEM_CREATED, messages.BATCH_ITEM_INVALID, messages.BATCH_ITEM_INVALID, messages.BATCH_ITEM_INVALID}
+
+	jsonData, marshalErr := json.Marshal(qRequests)
+	if marshalErr != nil {
+		t.Errorf("New request error: %s", marshalErr.Error())
+	}
+
+	// Send Insert Batch request to datastore
+	request, reqErr := http.NewRequest("POST", "/api/v1/ds/insertbatch", bytes.NewBuffer(jsonData))
+	if reqErr != nil {
+		t.Errorf("Could not create request.\n")
+	}
+
+	rRecorder := httptest.NewRecorder()
+	http.HandlerFunc(InsertBatch).ServeHTTP(rRecorder, request)
+
+	if rRecorder.Code != http.StatusOK {
+		t.Errorf("handler returned invalid status code: got %d, expected: %d\n", rRecorder.Code, http.StatusOK)
+	}
+
+	var biResponse messages.BatchInsertResponse
+	unmarshalErr := json.Unmarshal(rRecorder.Body.Bytes(), &biResponse)
+	if unmarshalErr != nil {
+		t.Errorf(unmarshalErr.Error())
+	}
+
+	if biResponse.Inserted != 1 || biResponse.Failed != 3 || len(biResponse.Results) != len(qRequests) {
+		t.Fatalf("Unexpected batch response: %+v", biResponse)
+	}
+
+	for idx, result := range biResponse.Results {
+		if result.Index != idx || result.Status != expectedStatus[idx] {
+			t.Errorf("Unexpected result for question %d: %+v", idx, result)
+		}
+	}
+
+	// The field errors of a question are returned with its result
+	if len(biResponse.Results[1].Fields) == 0 || biResponse.Results[1].Fields[0].Field != "questionid" {
+		t.Errorf("Unexpected field errors: %+v", biResponse.Results[1].Fields)
+	}
+
+	// A body that is not an array is rejected
+	request, reqErr = http.NewRequest("POST", "/api/v1/ds/insertbatch", bytes.NewBufferString(`{"questionid": "aaaapppp"}`))
+	if reqErr != nil {
+		t.Errorf("Could not create request.\n")
+	}
+
+	rRecorder = httptest.NewRecorder()
+	http.HandlerFunc(InsertBatch).ServeHTTP(rRecorder, request)
+
+	if rRecorder.Code != http.StatusBadRequest {
+		t.Errorf("handler returned invalid status code: got %d, expected: %d\n", rRecorder.Code, http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,184 @@
+package controllers
+
+import (
+	"regexp"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/sflewis2970/datastore-service/models/messages"
+)
+
+// Request field limits
+const (
+	MAX_QUESTION_ID_LENGTH int   = 64
+	MAX_QUESTION_LENGTH    int   = 1000
+	MAX_ANSWER_LENGTH      int   = 200
+	MAX_CATEGORY_LENGTH    int   = 50
+	MAX_LEASE_TOKEN_LENGTH int   = 64
+	MAX_OPTIONS            int   = 10
+	MAX_ALTERNATE_ANSWERS  int   = 20
+	MAX_EXCLUDED_QUESTIONS int   = 500
+	MAX_REQUEST_BODY_SIZE  int64 = 1 << 20
+	MAX_BATCH_BODY_SIZE    int64 = 16 << 20
+)
+
+// Field validation errors
+const (
+	REQUIRED_FIELD_ERROR       string = "is required"
+	MAX_LENGTH_ERROR           string = "too long, maximum characters: "
+	MAX_ITEMS_ERROR            string = "too many items, maximum items: "
+	QUESTION_ID_FORMAT_ERROR   string = "must only hold letters, digits, '-' and '_'"
+	CATEGORY_NOT_ALLOWED_ERROR string = "is not an allowed category"
+)
+
+// Question IDs are used in URL paths and as datastore keys
+var questionIDFormat = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// Field errors of a request, in field order
+type fieldErrors []messages.FieldError
+
+func (fErrs *fieldErrors) add(field string, fieldErr string) {
+	*fErrs = append(*fErrs, messages.FieldError{Field: field, Error: fieldErr})
+}
+
+func (fErrs *fieldErrors) checkRequired(field string, value string) bool {
+	if len(value) == 0 {
+		fErrs.add(field, REQUIRED_FIELD_ERROR)
+		return false
+	}
+
+	return true
+}
+
+func (fErrs *fieldErrors) checkLength(field string, value string, maxLength int) {
+	if utf8.RuneCountInString(value) > maxLength {
+		fErrs.add(field, MAX_LENGTH_ERROR+strconv.Itoa(maxLength))
+	}
+}
+
+func (fErrs *fieldErrors) checkItems(field string, values []string, maxItems int, maxLength int) {
+	if len(values) > maxItems {
+		fErrs.add(field, MAX_ITEMS_ERROR+strconv.Itoa(maxItems))
+		return
+	}
+
+	for idx, value := range values {
+		fErrs.checkLength(field+"["+strconv.Itoa(idx)+"]", value, maxLength)
+	}
+}
+
+func (fErrs *fieldErrors) checkQuestionID(field string, questionID string) {
+	if utf8.RuneCountInString(questionID) > MAX_QUESTION_ID_LENGTH {
+		fErrs.add(field, MAX_LENGTH_ERROR+strconv.Itoa(MAX_QUESTION_ID_LENGTH))
+	} else if !questionIDFormat.MatchString(questionID) {
+		fErrs.add(field, QUESTION_ID_FORMAT_ERROR)
+	}
+}
+
+// A category is allowed when no categories are configured or it is one of them
+func (fErrs *fieldErrors) checkCategory(field string, category string) {
+	fErrs.checkLength(field, category, MAX_CATEGORY_LENGTH)
+
+	if len(controller.cfgData.Categories) == 0 {
+		return
+	}
+
+	for _, allowed := range controller.cfgData.Categories {
+		if category == allowed {
+			return
+		}
+	}
+
+	fErrs.add(field, CATEGORY_NOT_ALLOWED_ERROR)
+}
+
+// Return the ErrInvalid error of the field errors, nil when there are none
+func (fErrs fieldErrors) err(message string) error {
+	if len(fErrs) == 0 {
+		return nil
+	}
+
+	invalidErr := messages.NewError(messages.ErrInvalid, messages.INVALID_CODE, message, nil)
+	invalidErr.Fields = fErrs
+
+	return invalidErr
+}
+
+// Validate the fields of a question. A partial question only holds the fields being changed, so
+// only its question ID is required.
+func questionFieldErrors(qRequest messages.QuestionRequest, partial bool) fieldErrors {
+	var fErrs fieldErrors
+
+	if fErrs.checkRequired("questionid", qRequest.QuestionID) {
+		fErrs.checkQuestionID("questionid", qRequest.QuestionID)
+	}
+
+	if partial || fErrs.checkRequired("question", qRequest.Question) {
+		fErrs.checkLength("question", qRequest.Question, MAX_QUESTION_LENGTH)
+	}
+
+	// The answer of a multiple-choice question can be given by its correct options
+	if partial || len(qRequest.CorrectOptions) > 0 || fErrs.checkRequired("answer", qRequest.Answer) {
+		fErrs.checkLength("answer", qRequest.Answer, MAX_ANSWER_LENGTH)
+	}
+
+	if !partial || len(qRequest.Category) > 0 {
+		fErrs.checkCategory("category", qRequest.Category)
+	}
+
+	fErrs.checkItems("alternateanswers", qRequest.AlternateAnswers, MAX_ALTERNATE_ANSWERS, MAX_ANSWER_LENGTH)
+	fErrs.checkItems("options", qRequest.Options, MAX_OPTIONS, MAX_ANSWER_LENGTH)
+
+	return fErrs
+}
+
+// Validate a question before it is stored
+func validateQuestionRequest(qRequest messages.QuestionRequest) error {
+	return questionFieldErrors(qRequest, false).err(messages.INVALID_QUESTION_MSG)
+}
+
+// Validate the fields provided to patch a question
+func validatePatchRequest(qRequest messages.QuestionRequest) error {
+	return questionFieldErrors(qRequest, true).err(messages.INVALID_QUESTION_MSG)
+}
+
+// Validate the question ID of a request, from its body, query or path
+func validateQuestionID(questionID string) error {
+	var fErrs fieldErrors
+	if fErrs.checkRequired("questionid", questionID) {
+		fErrs.checkQuestionID("questionid", questionID)
+	}
+
+	return fErrs.err(messages.ErrInvalid.Error())
+}
+
+// Validate a check answer request
+func validateCheckAnswerRequest(caRequest messages.CheckAnswerRequest) error {
+	var fErrs fieldErrors
+	if fErrs.checkRequired("questionid", caRequest.QuestionID) {
+		fErrs.checkQuestionID("questionid", caRequest.QuestionID)
+	}
+
+	fErrs.checkLength("answer", caRequest.Answer, MAX_ANSWER_LENGTH)
+	fErrs.checkLength("leasetoken", caRequest.LeaseToken, MAX_LEASE_TOKEN_LENGTH)
+
+	return fErrs.err(messages.ErrInvalid.Error())
+}
+
+// Validate a draw request, the category is optional
+func validateDrawRequest(dRequest messages.DrawRequest) error {
+	var fErrs fieldErrors
+	if len(dRequest.Category) > 0 {
+		fErrs.checkCategory("category", dRequest.Category)
+	}
+
+	if len(dRequest.Exclude) > MAX_EXCLUDED_QUESTIONS {
+		fErrs.add("exclude", MAX_ITEMS_ERROR+strconv.Itoa(MAX_EXCLUDED_QUESTIONS))
+	} else {
+		for idx, questionID := range dRequest.Exclude {
+			fErrs.checkQuestionID("exclude["+strconv.Itoa(idx)+"]", questionID)
+		}
+	}
+
+	return fErrs.err(messages.ErrInvalid.Error())
+}
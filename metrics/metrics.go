@@ -0,0 +1,100 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/sflewis2970/datastore-service/common"
+)
+
+// Prefix of the metric names
+const NAMESPACE string = "datastore"
+
+// Registry holds the metrics exposed by Handler, along with the Go runtime and process metrics
+var Registry = prometheus.NewRegistry()
+
+// Request metrics, labeled with the route template so the question IDs in the paths do not each get their own series
+var (
+	RequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: NAMESPACE,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests served, by route, method and status code.",
+	}, []string{"route", "method", "status"})
+
+	RequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: NAMESPACE,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency, by route, method and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method", "status"})
+)
+
+// Datastore operation metrics, recorded by the model for every driver
+var (
+	OperationDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: NAMESPACE,
+		Name:      "operation_duration_seconds",
+		Help:      "Datastore operation latency, by driver and operation.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"driver", "operation"})
+
+	OperationErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: NAMESPACE,
+		Name:      "operation_errors_total",
+		Help:      "Failed datastore operations, by driver, operation and error code.",
+	}, []string{"driver", "operation", "code"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		RequestsTotal,
+		RequestDuration,
+		OperationDuration,
+		OperationErrors,
+	)
+}
+
+// Register adds the collector of a driver to the registry. A driver created again replaces the
+// collector registered by the previous one.
+func Register(collector prometheus.Collector) {
+	Registry.Unregister(collector)
+	Registry.MustRegister(collector)
+}
+
+// ObserveOperation records the latency of a datastore operation started at start, and counts it
+// as failed when code is not empty
+func ObserveOperation(driver string, operation string, start time.Time, code string) {
+	OperationDuration.WithLabelValues(driver, operation).Observe(time.Since(start).Seconds())
+
+	if len(code) > 0 {
+		OperationErrors.WithLabelValues(driver, operation, code).Inc()
+	}
+}
+
+// Handler serves the metrics in the Prometheus exposition format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
+
+// Middleware counts the requests and records their latency by route, method and status code. It wraps
+// the router so the requests no route matches are counted too.
+func Middleware(router *mux.Router, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		route := common.MatchRouteTemplate(router, r)
+		recorder := common.NewStatusRecorder(rw)
+
+		next.ServeHTTP(recorder, r)
+
+		status := strconv.Itoa(recorder.Status)
+		RequestsTotal.WithLabelValues(route, r.Method, status).Inc()
+		RequestDuration.WithLabelValues(route, r.Method, status).Observe(time.Since(start).Seconds())
+	})
+}
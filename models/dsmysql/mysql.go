@@ -0,0 +1,847 @@
+package dsmysql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/sflewis2970/datastore-service/config"
+	"github.com/sflewis2970/datastore-service/metrics"
+	"github.com/sflewis2970/datastore-service/models/messages"
+)
+
+const (
+	MYSQL_DB_NAME_MSG     string = "MYSQL: "
+	MYSQL_DB_NAME         string = "main"
+	MYSQL_METRICS_DB_NAME string = "mysql"
+)
+
+const (
+	MYSQL_GET_CONFIG_ERROR       string = "Getting config error...: "
+	MYSQL_GET_CONFIG_DATA_ERROR  string = "Getting config data error...: "
+	MYSQL_PARSE_DSN_ERROR        string = "Error parsing connection string...: "
+	MYSQL_OPEN_ERROR             string = "Error opening database..."
+	MYSQL_CLOSE_ERROR            string = "Error closing database...: "
+	MYSQL_CREATE_TABLE_ERROR     string = "Error creating table...: "
+	MYSQL_INSERT_ERROR           string = "Error inserting record..."
+	MYSQL_UPSERT_ERROR           string = "Error storing record...: "
+	MYSQL_RECORD_EXISTS_ERROR    string = "Record already exists...: "
+	MYSQL_INSERT_BATCH_ERROR     string = "Error inserting records...: "
+	MYSQL_GET_ERROR              string = "Error getting record..."
+	MYSQL_LEASE_ERROR            string = "Error leasing record...: "
+	MYSQL_CONFIRM_ERROR          string = "Error confirming lease...: "
+	MYSQL_CONSUME_ERROR          string = "Error consuming record..."
+	MYSQL_REAP_ERROR             string = "Error removing expired records...: "
+	MYSQL_DRAW_ERROR             string = "Error drawing record...: "
+	MYSQL_LIST_ERROR             string = "Error listing records...: "
+	MYSQL_TRANSACTION_ERROR      string = "Transaction error...: "
+	MYSQL_UPDATE_ERROR           string = "Error updating record..."
+	MYSQL_ROWS_NOT_WRITTEN_ERROR string = "No record written...: "
+	MYSQL_DELETE_ERROR           string = "Error deleting record..."
+	MYSQL_RESULTS_ERROR          string = "Error getting results...: "
+	MYSQL_ROWS_AFFECTED_ERROR    string = "Error getting rows affected...: "
+	MYSQL_PING_ERROR             string = "Error pinging database server..."
+	MYSQL_DRAW_CONFLICT_ERROR    string = "Records kept being drawn by other clients..."
+)
+
+// Number of candidates Draw tries to consume before giving up, a candidate is lost when another
+// client consumes, checks out or updates it first
+const MYSQL_DRAW_ATTEMPTS int = 5
+
+// The trivia table is created when it does not exist, along with its indexes. MySQL only accepts
+// expression defaults on TEXT columns.
+const createTableQuery string = `CREATE TABLE IF NOT EXISTS trivia (
+	question_id       VARCHAR(64) NOT NULL PRIMARY KEY,
+	question          TEXT NOT NULL,
+	category          VARCHAR(64) NOT NULL DEFAULT '',
+	answer            TEXT NOT NULL,
+	alternate_answers TEXT NOT NULL DEFAULT ('[]'),
+	options           TEXT NOT NULL DEFAULT ('[]'),
+	correct_options   TEXT NOT NULL DEFAULT ('[]'),
+	shuffle           BOOLEAN NOT NULL DEFAULT FALSE,
+	expires_at        DATETIME(6),
+	lease_token       VARCHAR(64),
+	lease_expires_at  DATETIME(6),
+	version           BIGINT NOT NULL DEFAULT 1,
+	INDEX trivia_category_idx (category, question_id),
+	INDEX trivia_expires_at_idx (expires_at)
+);`
+
+// Columns added to the trivia table after it was first released. Tables created by an earlier
+// version get the missing columns when the database is opened.
+var addedColumns = []struct {
+	name       string
+	definition string
+}{
+	{name: "alternate_answers", definition: "TEXT NOT NULL DEFAULT ('[]')"},
+	{name: "options", definition: "TEXT NOT NULL DEFAULT ('[]')"},
+	{name: "correct_options", definition: "TEXT NOT NULL DEFAULT ('[]')"},
+	{name: "shuffle", definition: "BOOLEAN NOT NULL DEFAULT FALSE"},
+	{name: "expires_at", definition: "DATETIME(6)"},
+	{name: "lease_token", definition: "VARCHAR(64)"},
+	{name: "lease_expires_at", definition: "DATETIME(6)"},
+	{name: "version", definition: "BIGINT NOT NULL DEFAULT 1"},
+}
+
+// Indexes added to the trivia table after it was first released
+var addedIndexes = []struct {
+	name    string
+	columns string
+}{
+	{name: "trivia_category_idx", columns: "category, question_id"},
+	{name: "trivia_expires_at_idx", columns: "expires_at"},
+}
+
+// Filter skipping the expired records the reaper has not removed yet, times are stored in UTC
+const NOT_EXPIRED string = "(expires_at IS NULL OR expires_at > UTC_TIMESTAMP(6))"
+
+// Filter skipping the checked out records, a record returns to the pool when its lease expires
+const NOT_LEASED string = "(lease_expires_at IS NULL OR lease_expires_at <= UTC_TIMESTAMP(6))"
+
+// Record columns read by scanQuestionTable
+const QUESTION_COLUMNS string = "question, category, answer, alternate_answers, options, correct_options, shuffle, expires_at, version"
+
+// Columns overwritten by Upsert when the question ID is already stored, the version of the stored
+// record is incremented
+const UPSERT_COLUMNS string = "question = VALUES(question), category = VALUES(category), answer = VALUES(answer), alternate_answers = VALUES(alternate_answers), options = VALUES(options), correct_options = VALUES(correct_options), shuffle = VALUES(shuffle), expires_at = VALUES(expires_at), version = version + 1"
+
+// Implemented by *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// Scan the QUESTION_COLUMNS of a row into a record
+func scanQuestionTable(row rowScanner) (messages.QuestionTable, error) {
+	var qTable messages.QuestionTable
+	scanErr := row.Scan(&qTable.Question, &qTable.Category, &qTable.Answer, &qTable.AlternateAnswers, &qTable.Options, &qTable.CorrectOptions, &qTable.Shuffle, &qTable.ExpiresAt, &qTable.Version)
+
+	return qTable, scanErr
+}
+
+// Scan the question ID followed by the QUESTION_COLUMNS of a row into a record
+func scanQuestionRecord(row rowScanner) (messages.QuestionRecord, error) {
+	var qRecord messages.QuestionRecord
+	scanErr := row.Scan(&qRecord.QuestionID, &qRecord.Question, &qRecord.Category, &qRecord.Answer, &qRecord.AlternateAnswers, &qRecord.Options, &qRecord.CorrectOptions, &qRecord.Shuffle, &qRecord.ExpiresAt, &qRecord.Version)
+
+	return qRecord, scanErr
+}
+
+// Server error number of a duplicate primary key
+const MYSQL_DUPLICATE_ENTRY_ERROR_NUMBER uint16 = 1062
+
+// Report whether the error is a duplicate primary key error
+func isDuplicateKey(execErr error) bool {
+	var mysqlErr *mysql.MySQLError
+	return errors.As(execErr, &mysqlErr) && mysqlErr.Number == MYSQL_DUPLICATE_ENTRY_ERROR_NUMBER
+}
+
+// MySQL error numbers of a server refusing connections and of statements giving up waiting
+const (
+	MYSQL_TOO_MANY_CONNECTIONS_ERROR_NUMBER uint16 = 1040
+	MYSQL_SERVER_SHUTDOWN_ERROR_NUMBER      uint16 = 1053
+	MYSQL_LOCK_WAIT_TIMEOUT_ERROR_NUMBER    uint16 = 1205
+	MYSQL_QUERY_TIMEOUT_ERROR_NUMBER        uint16 = 3024
+)
+
+// Give a kind to an error returned by the MySQL client
+func dbError(dbErr error) error {
+	if errors.Is(dbErr, mysql.ErrInvalidConn) {
+		return messages.UnavailableError(dbErr)
+	}
+
+	var mysqlErr *mysql.MySQLError
+	if errors.As(dbErr, &mysqlErr) {
+		switch mysqlErr.Number {
+		case MYSQL_TOO_MANY_CONNECTIONS_ERROR_NUMBER, MYSQL_SERVER_SHUTDOWN_ERROR_NUMBER:
+			return messages.UnavailableError(dbErr)
+		case MYSQL_LOCK_WAIT_TIMEOUT_ERROR_NUMBER, MYSQL_QUERY_TIMEOUT_ERROR_NUMBER:
+			return messages.TimeoutError(dbErr)
+		}
+	}
+
+	return messages.ClassifyError(dbErr)
+}
+
+type dbModel struct {
+	cfgData *config.ConfigData
+
+	// Connection pool shared by every operation, opened by the first one
+	dbMutex sync.Mutex
+	db      *sql.DB
+}
+
+// Build the data source name from the configured connection string. When the connection
+// string does not name a database the default database is used. ClientFoundRows makes
+// MySQL report matched rows on update, the same as PostgreSQL does, instead of changed rows.
+// ParseTime scans DATETIME columns into time.Time.
+func (dbm *dbModel) dataSourceName() (string, error) {
+	mysqlCfg, parseErr := mysql.ParseDSN(dbm.cfgData.MySQL.Connection)
+	if parseErr != nil {
+		log.Print(MYSQL_DB_NAME_MSG+MYSQL_PARSE_DSN_ERROR, parseErr.Error())
+		return "", dbError(parseErr)
+	}
+
+	if len(mysqlCfg.DBName) == 0 {
+		mysqlCfg.DBName = MYSQL_DB_NAME
+	}
+	mysqlCfg.ClientFoundRows = true
+	mysqlCfg.ParseTime = true
+
+	return mysqlCfg.FormatDSN(), nil
+}
+
+// Read the names listed by a query of the information schema of the trivia table
+func schemaNames(ctx context.Context, db *sql.DB, queryStr string) (map[string]bool, error) {
+	rows, queryErr := db.QueryContext(ctx, queryStr)
+	if queryErr != nil {
+		return nil, queryErr
+	}
+	defer rows.Close()
+
+	names := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		scanErr := rows.Scan(&name)
+		if scanErr != nil {
+			return nil, scanErr
+		}
+		names[name] = true
+	}
+
+	return names, rows.Err()
+}
+
+// Create the trivia table, and add the columns and indexes missing from tables created by an
+// earlier version. MySQL has no ADD COLUMN IF NOT EXISTS, the existing ones are read from the
+// information schema.
+func ensureSchema(ctx context.Context, db *sql.DB) error {
+	_, execErr := db.ExecContext(ctx, createTableQuery)
+	if execErr != nil {
+		return execErr
+	}
+
+	columns, columnsErr := schemaNames(ctx, db, "SELECT column_name FROM information_schema.columns WHERE table_schema = DATABASE() AND table_name = 'trivia';")
+	if columnsErr != nil {
+		return columnsErr
+	}
+
+	for _, column := range addedColumns {
+		if columns[column.name] {
+			continue
+		}
+
+		log.Print("Adding column to trivia table: ", column.name)
+		_, execErr = db.ExecContext(ctx, "ALTER TABLE trivia ADD COLUMN "+column.name+" "+column.definition+";")
+		if execErr != nil {
+			return execErr
+		}
+	}
+
+	indexes, indexesErr := schemaNames(ctx, db, "SELECT DISTINCT index_name FROM information_schema.statistics WHERE table_schema = DATABASE() AND table_name = 'trivia';")
+	if indexesErr != nil {
+		return indexesErr
+	}
+
+	for _, index := range addedIndexes {
+		if indexes[index.name] {
+			continue
+		}
+
+		log.Print("Adding index to trivia table: ", index.name)
+		_, execErr = db.ExecContext(ctx, "CREATE INDEX "+index.name+" ON trivia ("+index.columns+");")
+		if execErr != nil {
+			return execErr
+		}
+	}
+
+	return nil
+}
+
+// Open database, the connection pool is opened once and then shared until Close. The schema is
+// brought up to date when the pool is opened.
+func (dbm *dbModel) Open(driverName string) (*sql.DB, error) {
+	dbm.dbMutex.Lock()
+	defer dbm.dbMutex.Unlock()
+
+	if dbm.db != nil {
+		return dbm.db, nil
+	}
+
+	log.Println("Opening MySQL database")
+
+	// Open database connection
+	dataSourceName, dsnErr := dbm.dataSourceName()
+	if dsnErr != nil {
+		return nil, dsnErr
+	}
+
+	db, openErr := sql.Open(driverName, dataSourceName)
+	if openErr != nil {
+		log.Print(MYSQL_DB_NAME_MSG+MYSQL_OPEN_ERROR, openErr.Error())
+		return nil, dbError(openErr)
+	}
+
+	// Make sure the trivia table and its columns exist
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(dbm.cfgData.OperationTimeoutMS)*time.Millisecond)
+	defer cancel()
+
+	schemaErr := ensureSchema(ctx, db)
+	if schemaErr != nil {
+		log.Print(MYSQL_DB_NAME_MSG+MYSQL_CREATE_TABLE_ERROR, schemaErr.Error())
+		db.Close()
+		return nil, dbError(schemaErr)
+	}
+
+	// Connection pool stats, the go_sql metrics labeled with the mysql database name
+	metrics.Register(collectors.NewDBStatsCollector(db, MYSQL_METRICS_DB_NAME))
+
+	dbm.db = db
+
+	return db, nil
+}
+
+// Close the connection pool, the next operation opens a new one
+func (dbm *dbModel) Close() error {
+	dbm.dbMutex.Lock()
+	defer dbm.dbMutex.Unlock()
+
+	if dbm.db == nil {
+		return nil
+	}
+
+	log.Println("Closing MySQL database")
+
+	closeErr := dbm.db.Close()
+	dbm.db = nil
+	if closeErr != nil {
+		log.Print(MYSQL_DB_NAME_MSG+MYSQL_CLOSE_ERROR, closeErr.Error())
+		return closeErr
+	}
+
+	return nil
+}
+
+// Ping database server by verifying the database connection is active
+func (dbm *dbModel) Ping(ctx context.Context) error {
+	db, openErr := dbm.Open(dbm.cfgData.ActiveDriver)
+	if openErr != nil {
+		log.Print(MYSQL_DB_NAME_MSG+MYSQL_OPEN_ERROR, openErr.Error())
+		return openErr
+	}
+
+	pingErr := db.PingContext(ctx)
+	if pingErr != nil {
+		log.Print(MYSQL_DB_NAME_MSG+MYSQL_PING_ERROR, pingErr.Error())
+		return messages.UnavailableError(pingErr)
+	}
+
+	return nil
+}
+
+// Insert a single record into table
+func (dbm *dbModel) Insert(ctx context.Context, qRequest messages.QuestionRequest) (int64, error) {
+	db, openErr := dbm.Open(dbm.cfgData.ActiveDriver)
+	if openErr != nil {
+		log.Print(MYSQL_DB_NAME_MSG+MYSQL_OPEN_ERROR, openErr.Error())
+		return messages.RESULTS_DEFAULT, openErr
+	}
+
+	log.Print("Adding a new record to the database")
+	queryStr := "INSERT INTO trivia (question_id, " + QUESTION_COLUMNS + ") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?);"
+	sqlDB, execErr := db.ExecContext(ctx, queryStr, qRequest.QuestionID, qRequest.Question, qRequest.Category, qRequest.Answer, qRequest.AlternateAnswers, qRequest.Options, qRequest.CorrectOptions, qRequest.ShuffleOptions(), qRequest.ExpiresAt, messages.INITIAL_VERSION)
+	if isDuplicateKey(execErr) {
+		log.Print(MYSQL_DB_NAME_MSG+MYSQL_RECORD_EXISTS_ERROR, qRequest.QuestionID)
+		return messages.RESULTS_DEFAULT, messages.ErrRecordExists
+	} else if execErr != nil {
+		log.Print(MYSQL_DB_NAME_MSG + MYSQL_INSERT_ERROR)
+		return messages.RESULTS_DEFAULT, dbError(execErr)
+	}
+
+	rowsAffected, rowsAffectedErr := sqlDB.RowsAffected()
+	if rowsAffectedErr != nil {
+		log.Print(MYSQL_DB_NAME_MSG+MYSQL_ROWS_AFFECTED_ERROR, rowsAffectedErr.Error())
+		return messages.RESULTS_DEFAULT, dbError(rowsAffectedErr)
+	}
+
+	return rowsAffected, nil
+}
+
+// Insert a single record into table, overwriting the stored record with the same question ID
+func (dbm *dbModel) Upsert(ctx context.Context, qRequest messages.QuestionRequest) (int64, error) {
+	db, openErr := dbm.Open(dbm.cfgData.ActiveDriver)
+	if openErr != nil {
+		log.Print(MYSQL_DB_NAME_MSG+MYSQL_OPEN_ERROR, openErr.Error())
+		return messages.RESULTS_DEFAULT, openErr
+	}
+
+	log.Print("Storing a record in the database")
+	queryStr := "INSERT INTO trivia (question_id, " + QUESTION_COLUMNS + ") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?) " + "ON DUPLICATE KEY UPDATE " + UPSERT_COLUMNS
+	sqlDB, execErr := db.ExecContext(ctx, queryStr, qRequest.QuestionID, qRequest.Question, qRequest.Category, qRequest.Answer, qRequest.AlternateAnswers, qRequest.Options, qRequest.CorrectOptions, qRequest.ShuffleOptions(), qRequest.ExpiresAt, messages.INITIAL_VERSION)
+	if execErr != nil {
+		log.Print(MYSQL_DB_NAME_MSG+MYSQL_UPSERT_ERROR, execErr.Error())
+		return messages.RESULTS_DEFAULT, dbError(execErr)
+	}
+
+	rowsAffected, rowsAffectedErr := sqlDB.RowsAffected()
+	if rowsAffectedErr != nil {
+		log.Print(MYSQL_DB_NAME_MSG+MYSQL_ROWS_AFFECTED_ERROR, rowsAffectedErr.Error())
+		return messages.RESULTS_DEFAULT, dbError(rowsAffectedErr)
+	}
+
+	return rowsAffected, nil
+}
+
+// Insert several records into table. The records are written by a prepared statement in a
+// single transaction, a failed row does not abort the transaction so every record gets its own
+// result. Records whose question ID is already stored are reported with messages.ErrRecordExists.
+func (dbm *dbModel) InsertBatch(ctx context.Context, qRequests []messages.QuestionRequest) ([]error, error) {
+	db, openErr := dbm.Open(dbm.cfgData.ActiveDriver)
+	if openErr != nil {
+		log.Print(MYSQL_DB_NAME_MSG+MYSQL_OPEN_ERROR, openErr.Error())
+		return nil, openErr
+	}
+
+	log.Print("Adding new records to the database, count: ", len(qRequests))
+	tx, txErr := db.BeginTx(ctx, nil)
+	if txErr != nil {
+		log.Print(MYSQL_DB_NAME_MSG+MYSQL_TRANSACTION_ERROR, txErr.Error())
+		return nil, dbError(txErr)
+	}
+	defer tx.Rollback()
+
+	queryStr := "INSERT INTO trivia (question_id, " + QUESTION_COLUMNS + ") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?);"
+	stmt, prepareErr := tx.PrepareContext(ctx, queryStr)
+	if prepareErr != nil {
+		log.Print(MYSQL_DB_NAME_MSG+MYSQL_INSERT_BATCH_ERROR, prepareErr.Error())
+		return nil, dbError(prepareErr)
+	}
+	defer stmt.Close()
+
+	itemErrs := make([]error, len(qRequests))
+	for idx, qRequest := range qRequests {
+		_, execErr := stmt.ExecContext(ctx, qRequest.QuestionID, qRequest.Question, qRequest.Category, qRequest.Answer, qRequest.AlternateAnswers, qRequest.Options, qRequest.CorrectOptions, qRequest.ShuffleOptions(), qRequest.ExpiresAt, messages.INITIAL_VERSION)
+		if isDuplicateKey(execErr) {
+			itemErrs[idx] = messages.ErrRecordExists
+		} else if execErr != nil {
+			log.Print(MYSQL_DB_NAME_MSG+MYSQL_INSERT_BATCH_ERROR, execErr.Error())
+			itemErrs[idx] = dbError(execErr)
+		}
+	}
+
+	commitErr := tx.Commit()
+	if commitErr != nil {
+		log.Print(MYSQL_DB_NAME_MSG+MYSQL_TRANSACTION_ERROR, commitErr.Error())
+		return nil, dbError(commitErr)
+	}
+
+	return itemErrs, nil
+}
+
+// Get a single record from table
+func (dbm *dbModel) Get(ctx context.Context, questionID string) (messages.QuestionTable, error) {
+	db, openErr := dbm.Open(dbm.cfgData.ActiveDriver)
+	if openErr != nil {
+		log.Print(MYSQL_DB_NAME_MSG+MYSQL_OPEN_ERROR, openErr.Error())
+		return messages.QuestionTable{}, openErr
+	}
+
+	log.Print("Getting a single record from the database")
+	queryStr := "SELECT " + QUESTION_COLUMNS + " FROM trivia WHERE question_id = ? AND " + NOT_EXPIRED + ";"
+	qTable, scanErr := scanQuestionTable(db.QueryRowContext(ctx, queryStr, questionID))
+	if scanErr == sql.ErrNoRows {
+		return messages.QuestionTable{}, messages.ErrNotFound
+	} else if scanErr != nil {
+		log.Print(MYSQL_DB_NAME_MSG+MYSQL_GET_ERROR, scanErr.Error())
+		return messages.QuestionTable{}, dbError(scanErr)
+	}
+
+	return qTable, nil
+}
+
+// Consume a single record from table. MySQL has no DELETE ... RETURNING, the row is locked
+// with SELECT ... FOR UPDATE and deleted in the same transaction.
+func (dbm *dbModel) Consume(ctx context.Context, questionID string) (messages.QuestionTable, error) {
+	db, openErr := dbm.Open(dbm.cfgData.ActiveDriver)
+	if openErr != nil {
+		log.Print(MYSQL_DB_NAME_MSG+MYSQL_OPEN_ERROR, openErr.Error())
+		return messages.QuestionTable{}, openErr
+	}
+
+	log.Print("Consuming a single record from the database")
+	tx, txErr := db.BeginTx(ctx, nil)
+	if txErr != nil {
+		log.Print(MYSQL_DB_NAME_MSG+MYSQL_TRANSACTION_ERROR, txErr.Error())
+		return messages.QuestionTable{}, dbError(txErr)
+	}
+	defer tx.Rollback()
+
+	queryStr := "SELECT " + QUESTION_COLUMNS + " FROM trivia WHERE question_id = ? AND " + NOT_EXPIRED + " AND " + NOT_LEASED + " FOR UPDATE;"
+	qTable, scanErr := scanQuestionTable(tx.QueryRowContext(ctx, queryStr, questionID))
+	if scanErr == sql.ErrNoRows {
+		return messages.QuestionTable{}, messages.ErrNotFound
+	} else if scanErr != nil {
+		log.Print(MYSQL_DB_NAME_MSG+MYSQL_CONSUME_ERROR, scanErr.Error())
+		return messages.QuestionTable{}, dbError(scanErr)
+	}
+
+	queryStr = "DELETE FROM trivia WHERE question_id = ?"
+	_, execErr := tx.ExecContext(ctx, queryStr, questionID)
+	if execErr != nil {
+		log.Print(MYSQL_DB_NAME_MSG+MYSQL_CONSUME_ERROR, execErr.Error())
+		return messages.QuestionTable{}, dbError(execErr)
+	}
+
+	commitErr := tx.Commit()
+	if commitErr != nil {
+		log.Print(MYSQL_DB_NAME_MSG+MYSQL_TRANSACTION_ERROR, commitErr.Error())
+		return messages.QuestionTable{}, dbError(commitErr)
+	}
+
+	return qTable, nil
+}
+
+// Lease a single record from table, the record stays in the table but cannot be consumed or
+// drawn until the lease expires
+func (dbm *dbModel) Lease(ctx context.Context, questionID string, leaseToken string, leaseExpiresAt time.Time) (messages.QuestionTable, error) {
+	db, openErr := dbm.Open(dbm.cfgData.ActiveDriver)
+	if openErr != nil {
+		log.Print(MYSQL_DB_NAME_MSG+MYSQL_OPEN_ERROR, openErr.Error())
+		return messages.QuestionTable{}, openErr
+	}
+
+	log.Print("Leasing a single record from the database")
+	tx, txErr := db.BeginTx(ctx, nil)
+	if txErr != nil {
+		log.Print(MYSQL_DB_NAME_MSG+MYSQL_TRANSACTION_ERROR, txErr.Error())
+		return messages.QuestionTable{}, dbError(txErr)
+	}
+	defer tx.Rollback()
+
+	queryStr := "SELECT " + QUESTION_COLUMNS + " FROM trivia WHERE question_id = ? AND " + NOT_EXPIRED + " AND " + NOT_LEASED + " FOR UPDATE;"
+	qTable, scanErr := scanQuestionTable(tx.QueryRowContext(ctx, queryStr, questionID))
+	if scanErr == sql.ErrNoRows {
+		return messages.QuestionTable{}, messages.ErrNotFound
+	} else if scanErr != nil {
+		log.Print(MYSQL_DB_NAME_MSG+MYSQL_LEASE_ERROR, scanErr.Error())
+		return messages.QuestionTable{}, dbError(scanErr)
+	}
+
+	queryStr = "UPDATE trivia SET lease_token = ?, lease_expires_at = ? WHERE question_id = ?"
+	_, execErr := tx.ExecContext(ctx, queryStr, leaseToken, leaseExpiresAt, questionID)
+	if execErr != nil {
+		log.Print(MYSQL_DB_NAME_MSG+MYSQL_LEASE_ERROR, execErr.Error())
+		return messages.QuestionTable{}, dbError(execErr)
+	}
+
+	commitErr := tx.Commit()
+	if commitErr != nil {
+		log.Print(MYSQL_DB_NAME_MSG+MYSQL_TRANSACTION_ERROR, commitErr.Error())
+		return messages.QuestionTable{}, dbError(commitErr)
+	}
+
+	return qTable, nil
+}
+
+// Confirm the lease of a single record, the record is returned and deleted when the lease
+// token matches an unexpired lease
+func (dbm *dbModel) Confirm(ctx context.Context, questionID string, leaseToken string) (messages.QuestionTable, error) {
+	db, openErr := dbm.Open(dbm.cfgData.ActiveDriver)
+	if openErr != nil {
+		log.Print(MYSQL_DB_NAME_MSG+MYSQL_OPEN_ERROR, openErr.Error())
+		return messages.QuestionTable{}, openErr
+	}
+
+	log.Print("Confirming the lease of a single record from the database")
+	tx, txErr := db.BeginTx(ctx, nil)
+	if txErr != nil {
+		log.Print(MYSQL_DB_NAME_MSG+MYSQL_TRANSACTION_ERROR, txErr.Error())
+		return messages.QuestionTable{}, dbError(txErr)
+	}
+	defer tx.Rollback()
+
+	queryStr := "SELECT " + QUESTION_COLUMNS + " FROM trivia WHERE question_id = ? AND lease_token = ? AND lease_expires_at > UTC_TIMESTAMP(6) AND " + NOT_EXPIRED + " FOR UPDATE;"
+	qTable, scanErr := scanQuestionTable(tx.QueryRowContext(ctx, queryStr, questionID, leaseToken))
+	if scanErr == sql.ErrNoRows {
+		return messages.QuestionTable{}, messages.ErrNotFound
+	} else if scanErr != nil {
+		log.Print(MYSQL_DB_NAME_MSG+MYSQL_CONFIRM_ERROR, scanErr.Error())
+		return messages.QuestionTable{}, dbError(scanErr)
+	}
+
+	queryStr = "DELETE FROM trivia WHERE question_id = ?"
+	_, execErr := tx.ExecContext(ctx, queryStr, questionID)
+	if execErr != nil {
+		log.Print(MYSQL_DB_NAME_MSG+MYSQL_CONFIRM_ERROR, execErr.Error())
+		return messages.QuestionTable{}, dbError(execErr)
+	}
+
+	commitErr := tx.Commit()
+	if commitErr != nil {
+		log.Print(MYSQL_DB_NAME_MSG+MYSQL_TRANSACTION_ERROR, commitErr.Error())
+		return messages.QuestionTable{}, dbError(commitErr)
+	}
+
+	return qTable, nil
+}
+
+// List records in question ID order, the primary key and the category index serve the query
+func (dbm *dbModel) List(ctx context.Context, lRequest messages.ListRequest) ([]messages.QuestionRecord, error) {
+	db, openErr := dbm.Open(dbm.cfgData.ActiveDriver)
+	if openErr != nil {
+		log.Print(MYSQL_DB_NAME_MSG+MYSQL_OPEN_ERROR, openErr.Error())
+		return nil, openErr
+	}
+
+	log.Print("Listing records from the database, after ID: ", lRequest.After)
+	queryStr := "SELECT question_id, " + QUESTION_COLUMNS + " FROM trivia WHERE question_id > ? AND " + NOT_EXPIRED + " ORDER BY question_id LIMIT ?;"
+	args := []interface{}{lRequest.After, lRequest.Limit}
+	if len(lRequest.Category) > 0 {
+		queryStr = "SELECT question_id, " + QUESTION_COLUMNS + " FROM trivia WHERE category = ? AND question_id > ? AND " + NOT_EXPIRED + " ORDER BY question_id LIMIT ?;"
+		args = []interface{}{lRequest.Category, lRequest.After, lRequest.Limit}
+	}
+
+	rows, queryErr := db.QueryContext(ctx, queryStr, args...)
+	if queryErr != nil {
+		log.Print(MYSQL_DB_NAME_MSG+MYSQL_LIST_ERROR, queryErr.Error())
+		return nil, dbError(queryErr)
+	}
+	defer rows.Close()
+
+	qRecords := make([]messages.QuestionRecord, 0)
+	for rows.Next() {
+		qRecord, scanErr := scanQuestionRecord(rows)
+		if scanErr != nil {
+			log.Print(MYSQL_DB_NAME_MSG+MYSQL_RESULTS_ERROR, scanErr.Error())
+			return nil, dbError(scanErr)
+		}
+
+		qRecords = append(qRecords, qRecord)
+	}
+
+	rowsErr := rows.Err()
+	if rowsErr != nil {
+		log.Print(MYSQL_DB_NAME_MSG+MYSQL_RESULTS_ERROR, rowsErr.Error())
+		return nil, dbError(rowsErr)
+	}
+
+	return qRecords, nil
+}
+
+// Draw a random record and consume it. The candidate is picked at a random offset of the records
+// without locking them, then deleted by its primary key provided it is still unleased, unexpired
+// and at the version read. A candidate taken by a concurrent client is replaced by another one.
+func (dbm *dbModel) Draw(ctx context.Context, dRequest messages.DrawRequest) (messages.QuestionRecord, error) {
+	db, openErr := dbm.Open(dbm.cfgData.ActiveDriver)
+	if openErr != nil {
+		log.Print(MYSQL_DB_NAME_MSG+MYSQL_OPEN_ERROR, openErr.Error())
+		return messages.QuestionRecord{}, openErr
+	}
+
+	log.Print("Drawing a record from the database, category: ", dRequest.Category)
+	filters := []string{NOT_EXPIRED, NOT_LEASED}
+	args := make([]interface{}, 0)
+	if len(dRequest.Category) > 0 {
+		filters = append(filters, "category = ?")
+		args = append(args, dRequest.Category)
+	}
+
+	if len(dRequest.Exclude) > 0 {
+		placeholders := make([]string, len(dRequest.Exclude))
+		for idx, questionID := range dRequest.Exclude {
+			placeholders[idx] = "?"
+			args = append(args, questionID)
+		}
+		filters = append(filters, "question_id NOT IN ("+strings.Join(placeholders, ", ")+")")
+	}
+
+	whereStr := " WHERE " + strings.Join(filters, " AND ")
+
+	for attempt := 0; attempt < MYSQL_DRAW_ATTEMPTS; attempt++ {
+		var count int64
+		queryStr := "SELECT COUNT(*) FROM trivia" + whereStr + ";"
+		countErr := db.QueryRowContext(ctx, queryStr, args...).Scan(&count)
+		if countErr != nil {
+			log.Print(MYSQL_DB_NAME_MSG+MYSQL_DRAW_ERROR, countErr.Error())
+			return messages.QuestionRecord{}, dbError(countErr)
+		}
+
+		if count == 0 {
+			return messages.QuestionRecord{}, messages.ErrNotFound
+		}
+
+		queryStr = "SELECT question_id, " + QUESTION_COLUMNS + " FROM trivia" + whereStr + " ORDER BY question_id LIMIT 1 OFFSET ?;"
+		qRecord, scanErr := scanQuestionRecord(db.QueryRowContext(ctx, queryStr, append(args, rand.Int63n(count))...))
+		if scanErr == sql.ErrNoRows {
+			// Records were taken since they were counted
+			continue
+		} else if scanErr != nil {
+			log.Print(MYSQL_DB_NAME_MSG+MYSQL_DRAW_ERROR, scanErr.Error())
+			return messages.QuestionRecord{}, dbError(scanErr)
+		}
+
+		queryStr = "DELETE FROM trivia WHERE question_id = ? AND version = ? AND " + NOT_EXPIRED + " AND " + NOT_LEASED + ";"
+		sqlDB, execErr := db.ExecContext(ctx, queryStr, qRecord.QuestionID, qRecord.Version)
+		if execErr != nil {
+			log.Print(MYSQL_DB_NAME_MSG+MYSQL_DRAW_ERROR, execErr.Error())
+			return messages.QuestionRecord{}, dbError(execErr)
+		}
+
+		rowsAffected, rowsAffectedErr := sqlDB.RowsAffected()
+		if rowsAffectedErr != nil {
+			log.Print(MYSQL_DB_NAME_MSG+MYSQL_ROWS_AFFECTED_ERROR, rowsAffectedErr.Error())
+			return messages.QuestionRecord{}, dbError(rowsAffectedErr)
+		}
+
+		if rowsAffected > 0 {
+			return qRecord, nil
+		}
+	}
+
+	return messages.QuestionRecord{}, messages.NewError(messages.ErrConflict, messages.CONFLICT_CODE, MYSQL_DRAW_CONFLICT_ERROR, nil)
+}
+
+// Tell why a write affected no rows, the record is missing or, when the write was conditional, at
+// another version
+func (dbm *dbModel) noRowsError(ctx context.Context, questionID string, version int64) error {
+	if version == 0 {
+		return messages.ErrNotFound
+	}
+
+	_, getErr := dbm.Get(ctx, questionID)
+	if getErr != nil {
+		return getErr
+	}
+
+	return messages.ErrVersionMismatch
+}
+
+// Update a single record in table, a version other than 0 must match the stored version.
+// The version written is returned with the rows affected.
+func (dbm *dbModel) Update(ctx context.Context, qRequest messages.QuestionRequest) (int64, int64, error) {
+	db, openErr := dbm.Open(dbm.cfgData.ActiveDriver)
+	if openErr != nil {
+		log.Print(MYSQL_DB_NAME_MSG+MYSQL_OPEN_ERROR, openErr.Error())
+		return messages.RESULTS_DEFAULT, messages.RESULTS_DEFAULT, openErr
+	}
+
+	log.Println("Updating a single record in the database")
+	tx, txErr := db.BeginTx(ctx, nil)
+	if txErr != nil {
+		log.Print(MYSQL_DB_NAME_MSG+MYSQL_TRANSACTION_ERROR, txErr.Error())
+		return messages.RESULTS_DEFAULT, messages.RESULTS_DEFAULT, dbError(txErr)
+	}
+	defer tx.Rollback()
+
+	queryStr := "UPDATE trivia SET question = ?, category = ?, answer = ?, alternate_answers = ?, options = ?, correct_options = ?, shuffle = ?, expires_at = ?, version = version + 1 WHERE question_id = ? AND " + NOT_EXPIRED + " AND (? = 0 OR version = ?)"
+	sqlDB, execErr := tx.ExecContext(ctx, queryStr, qRequest.Question, qRequest.Category, qRequest.Answer, qRequest.AlternateAnswers, qRequest.Options, qRequest.CorrectOptions, qRequest.ShuffleOptions(), qRequest.ExpiresAt, qRequest.QuestionID, qRequest.Version, qRequest.Version)
+	if execErr != nil {
+		log.Print(MYSQL_DB_NAME_MSG+MYSQL_UPDATE_ERROR, execErr.Error())
+		return messages.RESULTS_DEFAULT, messages.RESULTS_DEFAULT, dbError(execErr)
+	}
+
+	rowsAffected, rowsAffectedErr := sqlDB.RowsAffected()
+	if rowsAffectedErr != nil {
+		log.Print(MYSQL_DB_NAME_MSG+MYSQL_ROWS_AFFECTED_ERROR, rowsAffectedErr.Error())
+		return messages.RESULTS_DEFAULT, messages.RESULTS_DEFAULT, dbError(rowsAffectedErr)
+	}
+
+	if rowsAffected == messages.RESULTS_DEFAULT {
+		// Release the transaction before the record is read again outside of it
+		tx.Rollback()
+		noRowsErr := dbm.noRowsError(ctx, qRequest.QuestionID, qRequest.Version)
+		log.Print(MYSQL_DB_NAME_MSG+MYSQL_ROWS_NOT_WRITTEN_ERROR, noRowsErr.Error())
+		return messages.RESULTS_DEFAULT, messages.RESULTS_DEFAULT, noRowsErr
+	}
+
+	// MySQL has no UPDATE ... RETURNING, the row stays locked until the version written is read back
+	var version int64
+	queryStr = "SELECT version FROM trivia WHERE question_id = ?"
+	scanErr := tx.QueryRowContext(ctx, queryStr, qRequest.QuestionID).Scan(&version)
+	if scanErr != nil {
+		log.Print(MYSQL_DB_NAME_MSG+MYSQL_UPDATE_ERROR, scanErr.Error())
+		return messages.RESULTS_DEFAULT, messages.RESULTS_DEFAULT, dbError(scanErr)
+	}
+
+	commitErr := tx.Commit()
+	if commitErr != nil {
+		log.Print(MYSQL_DB_NAME_MSG+MYSQL_TRANSACTION_ERROR, commitErr.Error())
+		return messages.RESULTS_DEFAULT, messages.RESULTS_DEFAULT, dbError(commitErr)
+	}
+
+	return rowsAffected, version, nil
+}
+
+// Delete a single record from table, a version other than 0 must match the stored version
+func (dbm *dbModel) Delete(ctx context.Context, questionID string, version int64) (int64, error) {
+	db, openErr := dbm.Open(dbm.cfgData.ActiveDriver)
+	if openErr != nil {
+		log.Print(MYSQL_DB_NAME_MSG+MYSQL_OPEN_ERROR, openErr.Error())
+		return messages.RESULTS_DEFAULT, openErr
+	}
+
+	log.Println("deleting a single record from the database")
+	queryStr := "DELETE FROM trivia WHERE question_id = ? AND " + NOT_EXPIRED + " AND (? = 0 OR version = ?)"
+	sqlDB, execErr := db.ExecContext(ctx, queryStr, questionID, version, version)
+	if execErr != nil {
+		log.Print(MYSQL_DB_NAME_MSG+MYSQL_DELETE_ERROR, execErr.Error())
+		return messages.RESULTS_DEFAULT, dbError(execErr)
+	}
+
+	rowsAffected, rowsAffectedErr := sqlDB.RowsAffected()
+	if rowsAffectedErr != nil {
+		log.Print(MYSQL_DB_NAME_MSG+MYSQL_ROWS_AFFECTED_ERROR, rowsAffectedErr.Error())
+		return messages.RESULTS_DEFAULT, nil
+	}
+
+	if rowsAffected == messages.RESULTS_DEFAULT {
+		noRowsErr := dbm.noRowsError(ctx, questionID, version)
+		log.Print(MYSQL_DB_NAME_MSG+MYSQL_ROWS_NOT_WRITTEN_ERROR, noRowsErr.Error())
+		return messages.RESULTS_DEFAULT, noRowsErr
+	}
+
+	return rowsAffected, nil
+}
+
+// Reap removes the records whose expiry time has passed
+func (dbm *dbModel) Reap(ctx context.Context) (int64, error) {
+	db, openErr := dbm.Open(dbm.cfgData.ActiveDriver)
+	if openErr != nil {
+		log.Print(MYSQL_DB_NAME_MSG+MYSQL_OPEN_ERROR, openErr.Error())
+		return messages.RESULTS_DEFAULT, openErr
+	}
+
+	log.Println("removing expired records from the database")
+	queryStr := "DELETE FROM trivia WHERE expires_at <= UTC_TIMESTAMP(6)"
+	sqlDB, execErr := db.ExecContext(ctx, queryStr)
+	if execErr != nil {
+		log.Print(MYSQL_DB_NAME_MSG+MYSQL_REAP_ERROR, execErr.Error())
+		return messages.RESULTS_DEFAULT, dbError(execErr)
+	}
+
+	rowsAffected, rowsAffectedErr := sqlDB.RowsAffected()
+	if rowsAffectedErr != nil {
+		log.Print(MYSQL_DB_NAME_MSG+MYSQL_ROWS_AFFECTED_ERROR, rowsAffectedErr.Error())
+		return messages.RESULTS_DEFAULT, nil
+	}
+
+	return rowsAffected, nil
+}
+
+func GetMySQLModel(cfgData *config.ConfigData) *dbModel {
+	log.Print("Creating MySQL database model")
+
+	// Initialize MySQL database model
+	mySQLModel := new(dbModel)
+
+	// Assign config data
+	mySQLModel.cfgData = cfgData
+
+	return mySQLModel
+}
@@ -1,38 +1,209 @@
 package dspostgresql
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
+	"strings"
+	"sync"
+	"time"
 
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
+	"github.com/prometheus/client_golang/prometheus/collectors"
 	"github.com/sflewis2970/datastore-service/config"
+	"github.com/sflewis2970/datastore-service/metrics"
 	"github.com/sflewis2970/datastore-service/models/messages"
 )
 
 const (
-	POSTGRESQL_DB_NAME_MSG string = "POSTGRESQL: "
+	POSTGRESQL_DB_NAME_MSG     string = "POSTGRESQL: "
+	POSTGRESQL_METRICS_DB_NAME string = "postgres"
 )
 
+// Number of records affected by a successful single record operation
+const RECORD_AFFECTED int64 = 1
+
+const (
+	POSTGRESQL_GET_CONFIG_ERROR       string = "Getting config error...: "
+	POSTGRESQL_GET_CONFIG_DATA_ERROR  string = "Getting config data error...: "
+	POSTGRESQL_OPEN_ERROR             string = "Error opening database..."
+	POSTGRESQL_CLOSE_ERROR            string = "Error closing database...: "
+	POSTGRESQL_CREATE_TABLE_ERROR     string = "Error creating table...: "
+	POSTGRESQL_INSERT_ERROR           string = "Error inserting record..."
+	POSTGRESQL_UPSERT_ERROR           string = "Error storing record...: "
+	POSTGRESQL_RECORD_EXISTS_ERROR    string = "Record already exists...: "
+	POSTGRESQL_INSERT_BATCH_ERROR     string = "Error inserting records...: "
+	POSTGRESQL_TRANSACTION_ERROR      string = "Transaction error...: "
+	POSTGRESQL_GET_ERROR              string = "Error getting record..."
+	POSTGRESQL_CONSUME_ERROR          string = "Error consuming record..."
+	POSTGRESQL_LEASE_ERROR            string = "Error leasing record...: "
+	POSTGRESQL_CONFIRM_ERROR          string = "Error confirming lease...: "
+	POSTGRESQL_REAP_ERROR             string = "Error removing expired records...: "
+	POSTGRESQL_DRAW_ERROR             string = "Error drawing record...: "
+	POSTGRESQL_LIST_ERROR             string = "Error listing records...: "
+	POSTGRESQL_UPDATE_ERROR           string = "Error updating record..."
+	POSTGRESQL_ROWS_NOT_WRITTEN_ERROR string = "No record written...: "
+	POSTGRESQL_DELETE_ERROR           string = "Error deleting record..."
+	POSTGRESQL_RESULTS_ERROR          string = "Error getting results...: "
+	POSTGRESQL_ROWS_AFFECTED_ERROR    string = "Error getting rows affected...: "
+	POSTGRESQL_PING_ERROR             string = "Error pinging database server..."
+)
+
+// The trivia table is created when it does not exist, along with its indexes
+const createTableQuery string = `CREATE TABLE IF NOT EXISTS trivia (
+	question_id       VARCHAR(64) NOT NULL PRIMARY KEY,
+	question          TEXT NOT NULL,
+	category          VARCHAR(64) NOT NULL DEFAULT '',
+	answer            TEXT NOT NULL,
+	alternate_answers TEXT NOT NULL DEFAULT '[]',
+	options           TEXT NOT NULL DEFAULT '[]',
+	correct_options   TEXT NOT NULL DEFAULT '[]',
+	shuffle           BOOLEAN NOT NULL DEFAULT FALSE,
+	expires_at        TIMESTAMPTZ,
+	lease_token       VARCHAR(64),
+	lease_expires_at  TIMESTAMPTZ,
+	version           BIGINT NOT NULL DEFAULT 1
+);`
+
+// Indexes serving the category filter of List and the reaper
+const createIndexQuery string = `CREATE INDEX IF NOT EXISTS trivia_category_idx ON trivia (category, question_id);
+CREATE INDEX IF NOT EXISTS trivia_expires_at_idx ON trivia (expires_at);`
+
+// Columns added to the trivia table after it was first released. Tables created by an earlier
+// version get the missing columns when the database is opened.
+var addedColumns = []struct {
+	name       string
+	definition string
+}{
+	{name: "alternate_answers", definition: "TEXT NOT NULL DEFAULT '[]'"},
+	{name: "options", definition: "TEXT NOT NULL DEFAULT '[]'"},
+	{name: "correct_options", definition: "TEXT NOT NULL DEFAULT '[]'"},
+	{name: "shuffle", definition: "BOOLEAN NOT NULL DEFAULT FALSE"},
+	{name: "expires_at", definition: "TIMESTAMPTZ"},
+	{name: "lease_token", definition: "VARCHAR(64)"},
+	{name: "lease_expires_at", definition: "TIMESTAMPTZ"},
+	{name: "version", definition: "BIGINT NOT NULL DEFAULT 1"},
+}
+
+// Number of rows written by a single multi-row insert, each row uses QUESTION_PARAMS of the
+// 65535 parameters allowed in a statement
+const POSTGRESQL_BATCH_CHUNK_SIZE int = 1000
+
+// Number of parameters of a row inserted by InsertBatch
+const QUESTION_PARAMS int = 10
+
+// Filter skipping the expired records the reaper has not removed yet
+const NOT_EXPIRED string = "(expires_at IS NULL OR expires_at > now())"
+
+// Filter skipping the checked out records, a record returns to the pool when its lease expires
+const NOT_LEASED string = "(lease_expires_at IS NULL OR lease_expires_at <= now())"
+
+// Record columns read by scanQuestionTable
+const QUESTION_COLUMNS string = "question, category, answer, alternate_answers, options, correct_options, shuffle, expires_at, version"
+
+// Columns overwritten by Upsert when the question ID is already stored, the version of the stored
+// record is incremented
+const UPSERT_COLUMNS string = "question = EXCLUDED.question, category = EXCLUDED.category, answer = EXCLUDED.answer, alternate_answers = EXCLUDED.alternate_answers, options = EXCLUDED.options, correct_options = EXCLUDED.correct_options, shuffle = EXCLUDED.shuffle, expires_at = EXCLUDED.expires_at, version = trivia.version + 1"
+
+// Error code of a unique constraint violation
+const POSTGRESQL_UNIQUE_VIOLATION pq.ErrorCode = "23505"
+
+// Report whether the error is a duplicate primary key error
+func isDuplicateKey(execErr error) bool {
+	var pqErr *pq.Error
+	return errors.As(execErr, &pqErr) && pqErr.Code == POSTGRESQL_UNIQUE_VIOLATION
+}
+
+// PostgreSQL error codes of a server refusing or dropping connections and of a canceled statement
 const (
-	POSTGRESQL_GET_CONFIG_ERROR      string = "Getting config error...: "
-	POSTGRESQL_GET_CONFIG_DATA_ERROR string = "Getting config data error...: "
-	POSTGRESQL_OPEN_ERROR            string = "Error opening database..."
-	POSTGRESQL_INSERT_ERROR          string = "Error inserting record..."
-	POSTGRESQL_GET_ERROR             string = "Error getting record..."
-	POSTGRESQL_UPDATE_ERROR          string = "Error updating record..."
-	POSTGRESQL_DELETE_ERROR          string = "Error deleting record..."
-	POSTGRESQL_RESULTS_ERROR         string = "Error getting results...: "
-	POSTGRESQL_ROWS_AFFECTED_ERROR   string = "Error getting rows affected...: "
-	POSTGRESQL_PING_ERROR            string = "Error pinging database server..."
+	POSTGRESQL_CONNECTION_EXCEPTION_CLASS string       = "08"
+	POSTGRESQL_ADMIN_SHUTDOWN             pq.ErrorCode = "57P01"
+	POSTGRESQL_CRASH_SHUTDOWN             pq.ErrorCode = "57P02"
+	POSTGRESQL_CANNOT_CONNECT_NOW         pq.ErrorCode = "57P03"
+	POSTGRESQL_TOO_MANY_CONNECTIONS       pq.ErrorCode = "53300"
+	POSTGRESQL_QUERY_CANCELED             pq.ErrorCode = "57014"
 )
 
+// Give a kind to an error returned by the PostgreSQL client
+func dbError(pgErr error) error {
+	var pqErr *pq.Error
+	if errors.As(pgErr, &pqErr) {
+		switch {
+		case pqErr.Code == POSTGRESQL_QUERY_CANCELED:
+			return messages.TimeoutError(pgErr)
+		case string(pqErr.Code.Class()) == POSTGRESQL_CONNECTION_EXCEPTION_CLASS,
+			pqErr.Code == POSTGRESQL_ADMIN_SHUTDOWN,
+			pqErr.Code == POSTGRESQL_CRASH_SHUTDOWN,
+			pqErr.Code == POSTGRESQL_CANNOT_CONNECT_NOW,
+			pqErr.Code == POSTGRESQL_TOO_MANY_CONNECTIONS:
+			return messages.UnavailableError(pgErr)
+		}
+	}
+
+	return messages.ClassifyError(pgErr)
+}
+
+// Implemented by *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// Scan the QUESTION_COLUMNS of a row into a record
+func scanQuestionTable(row rowScanner) (messages.QuestionTable, error) {
+	var qTable messages.QuestionTable
+	scanErr := row.Scan(&qTable.Question, &qTable.Category, &qTable.Answer, &qTable.AlternateAnswers, &qTable.Options, &qTable.CorrectOptions, &qTable.Shuffle, &qTable.ExpiresAt, &qTable.Version)
+
+	return qTable, scanErr
+}
+
+// Scan the question ID followed by the QUESTION_COLUMNS of a row into a record
+func scanQuestionRecord(row rowScanner) (messages.QuestionRecord, error) {
+	var qRecord messages.QuestionRecord
+	scanErr := row.Scan(&qRecord.QuestionID, &qRecord.Question, &qRecord.Category, &qRecord.Answer, &qRecord.AlternateAnswers, &qRecord.Options, &qRecord.CorrectOptions, &qRecord.Shuffle, &qRecord.ExpiresAt, &qRecord.Version)
+
+	return qRecord, scanErr
+}
+
 type dbModel struct {
 	cfgData *config.ConfigData
+
+	// Connection pool shared by every operation, opened by the first one
+	dbMutex sync.Mutex
+	db      *sql.DB
+}
+
+// Create the trivia table, add the columns missing from tables created by an earlier version and
+// create the indexes. Every statement is idempotent.
+func ensureSchema(ctx context.Context, db *sql.DB) error {
+	_, execErr := db.ExecContext(ctx, createTableQuery)
+	if execErr != nil {
+		return execErr
+	}
+
+	for _, column := range addedColumns {
+		_, execErr = db.ExecContext(ctx, "ALTER TABLE trivia ADD COLUMN IF NOT EXISTS "+column.name+" "+column.definition+";")
+		if execErr != nil {
+			return execErr
+		}
+	}
+
+	_, execErr = db.ExecContext(ctx, createIndexQuery)
+
+	return execErr
 }
 
-// Open database
+// Open database, the connection pool is opened once and then shared until Close. The schema is
+// brought up to date when the pool is opened.
 func (dbm *dbModel) Open(driverName string) (*sql.DB, error) {
+	dbm.dbMutex.Lock()
+	defer dbm.dbMutex.Unlock()
+
+	if dbm.db != nil {
+		return dbm.db, nil
+	}
+
 	log.Println("Opening PostgreSQL database")
 
 	// Open database connection
@@ -41,93 +212,429 @@ func (dbm *dbModel) Open(driverName string) (*sql.DB, error) {
 
 	if openErr != nil {
 		log.Print(POSTGRESQL_DB_NAME_MSG+POSTGRESQL_OPEN_ERROR, openErr.Error())
-		return nil, openErr
+		return nil, dbError(openErr)
 	}
 
+	// Size the connection pool
+	db.SetMaxOpenConns(dbm.cfgData.PostGreSQL.MaxOpenConns)
+	db.SetMaxIdleConns(dbm.cfgData.PostGreSQL.MaxIdleConns)
+	db.SetConnMaxLifetime(time.Duration(dbm.cfgData.PostGreSQL.ConnMaxLifetime) * time.Second)
+	db.SetConnMaxIdleTime(time.Duration(dbm.cfgData.PostGreSQL.ConnMaxIdleTime) * time.Second)
+
+	// Make sure the trivia table and its columns exist
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(dbm.cfgData.OperationTimeoutMS)*time.Millisecond)
+	defer cancel()
+
+	schemaErr := ensureSchema(ctx, db)
+	if schemaErr != nil {
+		log.Print(POSTGRESQL_DB_NAME_MSG+POSTGRESQL_CREATE_TABLE_ERROR, schemaErr.Error())
+		db.Close()
+		return nil, dbError(schemaErr)
+	}
+
+	// Connection pool stats, the go_sql metrics labeled with the postgres database name
+	metrics.Register(collectors.NewDBStatsCollector(db, POSTGRESQL_METRICS_DB_NAME))
+
+	dbm.db = db
+
 	return db, nil
 }
 
+// Close the connection pool, the next operation opens a new one
+func (dbm *dbModel) Close() error {
+	dbm.dbMutex.Lock()
+	defer dbm.dbMutex.Unlock()
+
+	if dbm.db == nil {
+		return nil
+	}
+
+	log.Println("Closing PostgreSQL database")
+
+	closeErr := dbm.db.Close()
+	dbm.db = nil
+	if closeErr != nil {
+		log.Print(POSTGRESQL_DB_NAME_MSG+POSTGRESQL_CLOSE_ERROR, closeErr.Error())
+		return closeErr
+	}
+
+	return nil
+}
+
 // Ping database server by verifying the database connection is active
-func (dbm *dbModel) Ping() error {
+func (dbm *dbModel) Ping(ctx context.Context) error {
 	db, openErr := dbm.Open(dbm.cfgData.ActiveDriver)
 	if openErr != nil {
 		log.Print(POSTGRESQL_DB_NAME_MSG+POSTGRESQL_OPEN_ERROR, openErr.Error())
 		return openErr
 	}
-	defer db.Close()
 
-	pingErr := db.Ping()
-	if openErr != nil {
-		log.Print(POSTGRESQL_DB_NAME_MSG + POSTGRESQL_PING_ERROR)
-		return pingErr
+	pingErr := db.PingContext(ctx)
+	if pingErr != nil {
+		log.Print(POSTGRESQL_DB_NAME_MSG+POSTGRESQL_PING_ERROR, pingErr.Error())
+		return messages.UnavailableError(pingErr)
 	}
 
 	return nil
 }
 
 // Insert a single record into table
-func (dbm *dbModel) Insert(qRequest messages.QuestionRequest) (int64, error) {
+func (dbm *dbModel) Insert(ctx context.Context, qRequest messages.QuestionRequest) (int64, error) {
 	db, openErr := dbm.Open(dbm.cfgData.ActiveDriver)
 	if openErr != nil {
 		log.Print(POSTGRESQL_DB_NAME_MSG+POSTGRESQL_OPEN_ERROR, openErr.Error())
 		return messages.RESULTS_DEFAULT, openErr
 	}
-	defer db.Close()
 
 	log.Print("Adding a new record to the database")
-	queryStr := "insert into trivia VALUES ($1, $2, $3, $4);"
-	sqlDB, execErr := db.Exec(queryStr, qRequest.QuestionID, qRequest.Question, qRequest.Category, qRequest.Answer)
-	if execErr != nil {
+	queryStr := "INSERT INTO trivia (question_id, " + QUESTION_COLUMNS + ") VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10);"
+	sqlDB, execErr := db.ExecContext(ctx, queryStr, qRequest.QuestionID, qRequest.Question, qRequest.Category, qRequest.Answer, qRequest.AlternateAnswers, qRequest.Options, qRequest.CorrectOptions, qRequest.ShuffleOptions(), qRequest.ExpiresAt, messages.INITIAL_VERSION)
+	if isDuplicateKey(execErr) {
+		log.Print(POSTGRESQL_DB_NAME_MSG+POSTGRESQL_RECORD_EXISTS_ERROR, qRequest.QuestionID)
+		return messages.RESULTS_DEFAULT, messages.ErrRecordExists
+	} else if execErr != nil {
 		log.Print(POSTGRESQL_DB_NAME_MSG + POSTGRESQL_INSERT_ERROR)
-		return messages.RESULTS_DEFAULT, execErr
+		return messages.RESULTS_DEFAULT, dbError(execErr)
 	}
 
 	rowsAffected, rowsAffectedErr := sqlDB.RowsAffected()
 	if rowsAffectedErr != nil {
 		log.Print(POSTGRESQL_DB_NAME_MSG+POSTGRESQL_ROWS_AFFECTED_ERROR, rowsAffectedErr.Error())
-		return messages.RESULTS_DEFAULT, rowsAffectedErr
+		return messages.RESULTS_DEFAULT, dbError(rowsAffectedErr)
 	}
 
 	return rowsAffected, nil
 }
 
+// Insert a single record into table, overwriting the stored record with the same question ID
+func (dbm *dbModel) Upsert(ctx context.Context, qRequest messages.QuestionRequest) (int64, error) {
+	db, openErr := dbm.Open(dbm.cfgData.ActiveDriver)
+	if openErr != nil {
+		log.Print(POSTGRESQL_DB_NAME_MSG+POSTGRESQL_OPEN_ERROR, openErr.Error())
+		return messages.RESULTS_DEFAULT, openErr
+	}
+
+	log.Print("Storing a record in the database")
+	queryStr := "INSERT INTO trivia (question_id, " + QUESTION_COLUMNS + ") VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) " + "ON CONFLICT (question_id) DO UPDATE SET " + UPSERT_COLUMNS
+	sqlDB, execErr := db.ExecContext(ctx, queryStr, qRequest.QuestionID, qRequest.Question, qRequest.Category, qRequest.Answer, qRequest.AlternateAnswers, qRequest.Options, qRequest.CorrectOptions, qRequest.ShuffleOptions(), qRequest.ExpiresAt, messages.INITIAL_VERSION)
+	if execErr != nil {
+		log.Print(POSTGRESQL_DB_NAME_MSG+POSTGRESQL_UPSERT_ERROR, execErr.Error())
+		return messages.RESULTS_DEFAULT, dbError(execErr)
+	}
+
+	rowsAffected, rowsAffectedErr := sqlDB.RowsAffected()
+	if rowsAffectedErr != nil {
+		log.Print(POSTGRESQL_DB_NAME_MSG+POSTGRESQL_ROWS_AFFECTED_ERROR, rowsAffectedErr.Error())
+		return messages.RESULTS_DEFAULT, dbError(rowsAffectedErr)
+	}
+
+	return rowsAffected, nil
+}
+
+// Insert several records into table. The records are written by multi-row inserts in a single
+// transaction, records whose question ID is already stored are skipped and reported with
+// messages.ErrRecordExists.
+func (dbm *dbModel) InsertBatch(ctx context.Context, qRequests []messages.QuestionRequest) ([]error, error) {
+	db, openErr := dbm.Open(dbm.cfgData.ActiveDriver)
+	if openErr != nil {
+		log.Print(POSTGRESQL_DB_NAME_MSG+POSTGRESQL_OPEN_ERROR, openErr.Error())
+		return nil, openErr
+	}
+
+	log.Print("Adding new records to the database, count: ", len(qRequests))
+	tx, txErr := db.BeginTx(ctx, nil)
+	if txErr != nil {
+		log.Print(POSTGRESQL_DB_NAME_MSG+POSTGRESQL_TRANSACTION_ERROR, txErr.Error())
+		return nil, dbError(txErr)
+	}
+	defer tx.Rollback()
+
+	itemErrs := make([]error, len(qRequests))
+	for start := 0; start < len(qRequests); start += POSTGRESQL_BATCH_CHUNK_SIZE {
+		end := start + POSTGRESQL_BATCH_CHUNK_SIZE
+		if end > len(qRequests) {
+			end = len(qRequests)
+		}
+
+		// Build a multi-row insert for the chunk
+		var queryBuilder strings.Builder
+		queryBuilder.WriteString("INSERT INTO trivia (question_id, " + QUESTION_COLUMNS + ") VALUES ")
+		args := make([]interface{}, 0, (end-start)*QUESTION_PARAMS)
+		for idx, qRequest := range qRequests[start:end] {
+			if idx > 0 {
+				queryBuilder.WriteString(", ")
+			}
+
+			placeholders := make([]string, QUESTION_PARAMS)
+			for paramIdx := range placeholders {
+				placeholders[paramIdx] = fmt.Sprintf("$%d", len(args)+paramIdx+1)
+			}
+			queryBuilder.WriteString("(" + strings.Join(placeholders, ", ") + ")")
+
+			args = append(args, qRequest.QuestionID, qRequest.Question, qRequest.Category, qRequest.Answer, qRequest.AlternateAnswers, qRequest.Options, qRequest.CorrectOptions, qRequest.ShuffleOptions(), qRequest.ExpiresAt, messages.INITIAL_VERSION)
+		}
+		queryBuilder.WriteString(" ON CONFLICT (question_id) DO NOTHING RETURNING question_id;")
+
+		rows, queryErr := tx.QueryContext(ctx, queryBuilder.String(), args...)
+		if queryErr != nil {
+			log.Print(POSTGRESQL_DB_NAME_MSG+POSTGRESQL_INSERT_BATCH_ERROR, queryErr.Error())
+			return nil, dbError(queryErr)
+		}
+
+		// Only the inserted rows are returned
+		inserted := make(map[string]bool)
+		for rows.Next() {
+			var questionID string
+			scanErr := rows.Scan(&questionID)
+			if scanErr != nil {
+				rows.Close()
+				log.Print(POSTGRESQL_DB_NAME_MSG+POSTGRESQL_RESULTS_ERROR, scanErr.Error())
+				return nil, dbError(scanErr)
+			}
+			inserted[questionID] = true
+		}
+		rows.Close()
+
+		rowsErr := rows.Err()
+		if rowsErr != nil {
+			log.Print(POSTGRESQL_DB_NAME_MSG+POSTGRESQL_RESULTS_ERROR, rowsErr.Error())
+			return nil, dbError(rowsErr)
+		}
+
+		for idx := start; idx < end; idx++ {
+			if !inserted[qRequests[idx].QuestionID] {
+				itemErrs[idx] = messages.ErrRecordExists
+			}
+		}
+	}
+
+	commitErr := tx.Commit()
+	if commitErr != nil {
+		log.Print(POSTGRESQL_DB_NAME_MSG+POSTGRESQL_TRANSACTION_ERROR, commitErr.Error())
+		return nil, dbError(commitErr)
+	}
+
+	return itemErrs, nil
+}
+
 // Get a single record from table
-func (dbm *dbModel) Get(questionID string) (messages.QuestionTable, error) {
+func (dbm *dbModel) Get(ctx context.Context, questionID string) (messages.QuestionTable, error) {
 	db, openErr := dbm.Open(dbm.cfgData.ActiveDriver)
 	if openErr != nil {
 		log.Print(POSTGRESQL_DB_NAME_MSG+POSTGRESQL_OPEN_ERROR, openErr.Error())
 		return messages.QuestionTable{}, openErr
 	}
-	defer db.Close()
-
-	var qTable messages.QuestionTable
 
 	log.Print("Getting a single record from the database")
-	queryStr := "SELECT question, category, answer FROM trivia WHERE question_id = $1;"
-	scanErr := db.QueryRow(queryStr, questionID).Scan(&qTable.Question, &qTable.Category, &qTable.Answer)
-	if scanErr != nil && scanErr != sql.ErrNoRows {
+	queryStr := "SELECT " + QUESTION_COLUMNS + " FROM trivia WHERE question_id = $1 AND " + NOT_EXPIRED + ";"
+	qTable, scanErr := scanQuestionTable(db.QueryRowContext(ctx, queryStr, questionID))
+	if scanErr == sql.ErrNoRows {
+		return messages.QuestionTable{}, messages.ErrNotFound
+	} else if scanErr != nil {
 		log.Print(POSTGRESQL_DB_NAME_MSG+POSTGRESQL_GET_ERROR, scanErr.Error())
-		return messages.QuestionTable{}, scanErr
+		return messages.QuestionTable{}, dbError(scanErr)
 	}
 
 	return qTable, nil
 }
 
-// Update a single record in table
-func (dbm *dbModel) Update(qRequest messages.QuestionRequest) (int64, error) {
+// Consume a single record from table, the record is deleted and returned by the same statement
+func (dbm *dbModel) Consume(ctx context.Context, questionID string) (messages.QuestionTable, error) {
 	db, openErr := dbm.Open(dbm.cfgData.ActiveDriver)
 	if openErr != nil {
 		log.Print(POSTGRESQL_DB_NAME_MSG+POSTGRESQL_OPEN_ERROR, openErr.Error())
-		return messages.RESULTS_DEFAULT, openErr
+		return messages.QuestionTable{}, openErr
+	}
+
+	log.Print("Consuming a single record from the database")
+	queryStr := "DELETE FROM trivia WHERE question_id = $1 AND " + NOT_EXPIRED + " AND " + NOT_LEASED + " RETURNING " + QUESTION_COLUMNS + ";"
+	qTable, scanErr := scanQuestionTable(db.QueryRowContext(ctx, queryStr, questionID))
+	if scanErr == sql.ErrNoRows {
+		return messages.QuestionTable{}, messages.ErrNotFound
+	} else if scanErr != nil {
+		log.Print(POSTGRESQL_DB_NAME_MSG+POSTGRESQL_CONSUME_ERROR, scanErr.Error())
+		return messages.QuestionTable{}, dbError(scanErr)
+	}
+
+	return qTable, nil
+}
+
+// Lease a single record from table, the record stays in the table but cannot be consumed or
+// drawn until the lease expires
+func (dbm *dbModel) Lease(ctx context.Context, questionID string, leaseToken string, leaseExpiresAt time.Time) (messages.QuestionTable, error) {
+	db, openErr := dbm.Open(dbm.cfgData.ActiveDriver)
+	if openErr != nil {
+		log.Print(POSTGRESQL_DB_NAME_MSG+POSTGRESQL_OPEN_ERROR, openErr.Error())
+		return messages.QuestionTable{}, openErr
+	}
+
+	log.Print("Leasing a single record from the database")
+	queryStr := "UPDATE trivia SET lease_token = $2, lease_expires_at = $3 WHERE question_id = $1 AND " + NOT_EXPIRED + " AND " + NOT_LEASED + " RETURNING " + QUESTION_COLUMNS + ";"
+	qTable, scanErr := scanQuestionTable(db.QueryRowContext(ctx, queryStr, questionID, leaseToken, leaseExpiresAt))
+	if scanErr == sql.ErrNoRows {
+		return messages.QuestionTable{}, messages.ErrNotFound
+	} else if scanErr != nil {
+		log.Print(POSTGRESQL_DB_NAME_MSG+POSTGRESQL_LEASE_ERROR, scanErr.Error())
+		return messages.QuestionTable{}, dbError(scanErr)
+	}
+
+	return qTable, nil
+}
+
+// Confirm the lease of a single record, the record is returned and deleted when the lease
+// token matches an unexpired lease
+func (dbm *dbModel) Confirm(ctx context.Context, questionID string, leaseToken string) (messages.QuestionTable, error) {
+	db, openErr := dbm.Open(dbm.cfgData.ActiveDriver)
+	if openErr != nil {
+		log.Print(POSTGRESQL_DB_NAME_MSG+POSTGRESQL_OPEN_ERROR, openErr.Error())
+		return messages.QuestionTable{}, openErr
+	}
+
+	log.Print("Confirming the lease of a single record from the database")
+	queryStr := "DELETE FROM trivia WHERE question_id = $1 AND lease_token = $2 AND lease_expires_at > now() AND " + NOT_EXPIRED + " RETURNING " + QUESTION_COLUMNS + ";"
+	qTable, scanErr := scanQuestionTable(db.QueryRowContext(ctx, queryStr, questionID, leaseToken))
+	if scanErr == sql.ErrNoRows {
+		return messages.QuestionTable{}, messages.ErrNotFound
+	} else if scanErr != nil {
+		log.Print(POSTGRESQL_DB_NAME_MSG+POSTGRESQL_CONFIRM_ERROR, scanErr.Error())
+		return messages.QuestionTable{}, dbError(scanErr)
+	}
+
+	return qTable, nil
+}
+
+// List records in question ID order, the primary key and the category index serve the query
+func (dbm *dbModel) List(ctx context.Context, lRequest messages.ListRequest) ([]messages.QuestionRecord, error) {
+	db, openErr := dbm.Open(dbm.cfgData.ActiveDriver)
+	if openErr != nil {
+		log.Print(POSTGRESQL_DB_NAME_MSG+POSTGRESQL_OPEN_ERROR, openErr.Error())
+		return nil, openErr
+	}
+
+	log.Print("Listing records from the database, after ID: ", lRequest.After)
+	queryStr := "SELECT question_id, " + QUESTION_COLUMNS + " FROM trivia WHERE question_id > $1 AND " + NOT_EXPIRED + " ORDER BY question_id LIMIT $2;"
+	args := []interface{}{lRequest.After, lRequest.Limit}
+	if len(lRequest.Category) > 0 {
+		queryStr = "SELECT question_id, " + QUESTION_COLUMNS + " FROM trivia WHERE category = $3 AND question_id > $1 AND " + NOT_EXPIRED + " ORDER BY question_id LIMIT $2;"
+		args = append(args, lRequest.Category)
+	}
+
+	rows, queryErr := db.QueryContext(ctx, queryStr, args...)
+	if queryErr != nil {
+		log.Print(POSTGRESQL_DB_NAME_MSG+POSTGRESQL_LIST_ERROR, queryErr.Error())
+		return nil, dbError(queryErr)
+	}
+	defer rows.Close()
+
+	qRecords := make([]messages.QuestionRecord, 0)
+	for rows.Next() {
+		qRecord, scanErr := scanQuestionRecord(rows)
+		if scanErr != nil {
+			log.Print(POSTGRESQL_DB_NAME_MSG+POSTGRESQL_RESULTS_ERROR, scanErr.Error())
+			return nil, dbError(scanErr)
+		}
+
+		qRecords = append(qRecords, qRecord)
+	}
+
+	rowsErr := rows.Err()
+	if rowsErr != nil {
+		log.Print(POSTGRESQL_DB_NAME_MSG+POSTGRESQL_RESULTS_ERROR, rowsErr.Error())
+		return nil, dbError(rowsErr)
+	}
+
+	return qRecords, nil
+}
+
+// Draw a random record and consume it. The record is picked, locked and deleted by one statement,
+// SKIP LOCKED lets concurrent draws pick other records instead of waiting for the same one.
+func (dbm *dbModel) Draw(ctx context.Context, dRequest messages.DrawRequest) (messages.QuestionRecord, error) {
+	db, openErr := dbm.Open(dbm.cfgData.ActiveDriver)
+	if openErr != nil {
+		log.Print(POSTGRESQL_DB_NAME_MSG+POSTGRESQL_OPEN_ERROR, openErr.Error())
+		return messages.QuestionRecord{}, openErr
+	}
+
+	// A NULL array would exclude every record
+	exclude := dRequest.Exclude
+	if exclude == nil {
+		exclude = make([]string, 0)
+	}
+
+	log.Print("Drawing a record from the database, category: ", dRequest.Category)
+	filterStr := "NOT (question_id = ANY($1)) AND " + NOT_EXPIRED + " AND " + NOT_LEASED
+	args := []interface{}{pq.Array(exclude)}
+	if len(dRequest.Category) > 0 {
+		filterStr = "category = $2 AND " + filterStr
+		args = append(args, dRequest.Category)
+	}
+
+	queryStr := "DELETE FROM trivia WHERE question_id = (SELECT question_id FROM trivia WHERE " + filterStr + " ORDER BY random() LIMIT 1 FOR UPDATE SKIP LOCKED) RETURNING question_id, " + QUESTION_COLUMNS + ";"
+	qRecord, scanErr := scanQuestionRecord(db.QueryRowContext(ctx, queryStr, args...))
+	if scanErr == sql.ErrNoRows {
+		return messages.QuestionRecord{}, messages.ErrNotFound
+	} else if scanErr != nil {
+		log.Print(POSTGRESQL_DB_NAME_MSG+POSTGRESQL_DRAW_ERROR, scanErr.Error())
+		return messages.QuestionRecord{}, dbError(scanErr)
+	}
+
+	return qRecord, nil
+}
+
+// Tell why a write affected no rows, the record is missing or, when the write was conditional, at
+// another version
+func (dbm *dbModel) noRowsError(ctx context.Context, questionID string, version int64) error {
+	if version == 0 {
+		return messages.ErrNotFound
+	}
+
+	_, getErr := dbm.Get(ctx, questionID)
+	if getErr != nil {
+		return getErr
+	}
+
+	return messages.ErrVersionMismatch
+}
+
+// Update a single record in table, a version other than 0 must match the stored version.
+// The version written is returned with the rows affected.
+func (dbm *dbModel) Update(ctx context.Context, qRequest messages.QuestionRequest) (int64, int64, error) {
+	db, openErr := dbm.Open(dbm.cfgData.ActiveDriver)
+	if openErr != nil {
+		log.Print(POSTGRESQL_DB_NAME_MSG+POSTGRESQL_OPEN_ERROR, openErr.Error())
+		return messages.RESULTS_DEFAULT, messages.RESULTS_DEFAULT, openErr
 	}
-	defer db.Close()
 
 	log.Println("Updating a single record in the database")
-	queryStr := "UPDATE trivia SET question = $2, category = $3, answer = $4 WHERE question_id = $1"
-	sqlDB, execErr := db.Exec(queryStr, qRequest.QuestionID, qRequest.Question, qRequest.Category, qRequest.Answer)
+	queryStr := "UPDATE trivia SET question = $2, category = $3, answer = $4, alternate_answers = $5, options = $6, correct_options = $7, shuffle = $8, expires_at = $9, version = version + 1 WHERE question_id = $1 AND " + NOT_EXPIRED + " AND ($10::bigint = 0 OR version = $10) RETURNING version;"
+	var version int64
+	scanErr := db.QueryRowContext(ctx, queryStr, qRequest.QuestionID, qRequest.Question, qRequest.Category, qRequest.Answer, qRequest.AlternateAnswers, qRequest.Options, qRequest.CorrectOptions, qRequest.ShuffleOptions(), qRequest.ExpiresAt, qRequest.Version).Scan(&version)
+	if scanErr == sql.ErrNoRows {
+		noRowsErr := dbm.noRowsError(ctx, qRequest.QuestionID, qRequest.Version)
+		log.Print(POSTGRESQL_DB_NAME_MSG+POSTGRESQL_ROWS_NOT_WRITTEN_ERROR, noRowsErr.Error())
+		return messages.RESULTS_DEFAULT, messages.RESULTS_DEFAULT, noRowsErr
+	} else if scanErr != nil {
+		log.Print(POSTGRESQL_DB_NAME_MSG+POSTGRESQL_UPDATE_ERROR, scanErr.Error())
+		return messages.RESULTS_DEFAULT, messages.RESULTS_DEFAULT, dbError(scanErr)
+	}
+
+	return RECORD_AFFECTED, version, nil
+}
+
+// Delete a single record from table, a version other than 0 must match the stored version
+func (dbm *dbModel) Delete(ctx context.Context, questionID string, version int64) (int64, error) {
+	db, openErr := dbm.Open(dbm.cfgData.ActiveDriver)
+	if openErr != nil {
+		log.Print(POSTGRESQL_DB_NAME_MSG+POSTGRESQL_OPEN_ERROR, openErr.Error())
+		return messages.RESULTS_DEFAULT, openErr
+	}
+
+	log.Println("deleting a single record from the database")
+	queryStr := "DELETE FROM trivia WHERE question_id = $1 AND " + NOT_EXPIRED + " AND ($2::bigint = 0 OR version = $2)"
+	sqlDB, execErr := db.ExecContext(ctx, queryStr, questionID, version)
 	if execErr != nil {
-		log.Print(POSTGRESQL_DB_NAME_MSG+POSTGRESQL_UPDATE_ERROR, execErr.Error())
-		return messages.RESULTS_DEFAULT, execErr
+		log.Print(POSTGRESQL_DB_NAME_MSG+POSTGRESQL_DELETE_ERROR, execErr.Error())
+		return messages.RESULTS_DEFAULT, dbError(execErr)
 	}
 
 	rowsAffected, rowsAffectedErr := sqlDB.RowsAffected()
@@ -136,24 +643,29 @@ func (dbm *dbModel) Update(qRequest messages.QuestionRequest) (int64, error) {
 		return messages.RESULTS_DEFAULT, nil
 	}
 
+	if rowsAffected == messages.RESULTS_DEFAULT {
+		noRowsErr := dbm.noRowsError(ctx, questionID, version)
+		log.Print(POSTGRESQL_DB_NAME_MSG+POSTGRESQL_ROWS_NOT_WRITTEN_ERROR, noRowsErr.Error())
+		return messages.RESULTS_DEFAULT, noRowsErr
+	}
+
 	return rowsAffected, nil
 }
 
-// Delete a single record from table
-func (dbm *dbModel) Delete(questionID string) (int64, error) {
+// Reap removes the records whose expiry time has passed
+func (dbm *dbModel) Reap(ctx context.Context) (int64, error) {
 	db, openErr := dbm.Open(dbm.cfgData.ActiveDriver)
 	if openErr != nil {
 		log.Print(POSTGRESQL_DB_NAME_MSG+POSTGRESQL_OPEN_ERROR, openErr.Error())
 		return messages.RESULTS_DEFAULT, openErr
 	}
-	defer db.Close()
 
-	log.Println("deleting a single record from the database")
-	queryStr := "DELETE FROM trivia WHERE question_id = $1"
-	sqlDB, execErr := db.Exec(queryStr, questionID)
+	log.Println("removing expired records from the database")
+	queryStr := "DELETE FROM trivia WHERE expires_at <= now()"
+	sqlDB, execErr := db.ExecContext(ctx, queryStr)
 	if execErr != nil {
-		log.Print(POSTGRESQL_DB_NAME_MSG+POSTGRESQL_DELETE_ERROR, execErr.Error())
-		return messages.RESULTS_DEFAULT, execErr
+		log.Print(POSTGRESQL_DB_NAME_MSG+POSTGRESQL_REAP_ERROR, execErr.Error())
+		return messages.RESULTS_DEFAULT, dbError(execErr)
 	}
 
 	rowsAffected, rowsAffectedErr := sqlDB.RowsAffected()
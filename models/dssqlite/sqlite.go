@@ -0,0 +1,650 @@
+package dssqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/sflewis2970/datastore-service/config"
+	"github.com/sflewis2970/datastore-service/models/messages"
+)
+
+const (
+	SQLITE_DB_NAME_MSG     string = "SQLITE: "
+	SQLITE_SQL_DRIVER_NAME string = "sqlite3"
+	SQLITE_DEFAULT_PATH    string = "./trivia.db"
+	SQLITE_BUSY_TIMEOUT_MS int    = 5000
+)
+
+// Number of records affected by a successful single record operation
+const RECORD_AFFECTED int64 = 1
+
+const (
+	SQLITE_GET_CONFIG_ERROR       string = "Getting config error...: "
+	SQLITE_GET_CONFIG_DATA_ERROR  string = "Getting config data error...: "
+	SQLITE_OPEN_ERROR             string = "Error opening database..."
+	SQLITE_CLOSE_ERROR            string = "Error closing database...: "
+	SQLITE_CREATE_TABLE_ERROR     string = "Error creating table..."
+	SQLITE_INSERT_ERROR           string = "Error inserting record..."
+	SQLITE_UPSERT_ERROR           string = "Error storing record...: "
+	SQLITE_RECORD_EXISTS_ERROR    string = "Record already exists...: "
+	SQLITE_INSERT_BATCH_ERROR     string = "Error inserting records...: "
+	SQLITE_TRANSACTION_ERROR      string = "Transaction error...: "
+	SQLITE_GET_ERROR              string = "Error getting record..."
+	SQLITE_LEASE_ERROR            string = "Error leasing record...: "
+	SQLITE_CONFIRM_ERROR          string = "Error confirming lease...: "
+	SQLITE_CONSUME_ERROR          string = "Error consuming record..."
+	SQLITE_REAP_ERROR             string = "Error removing expired records...: "
+	SQLITE_DRAW_ERROR             string = "Error drawing record...: "
+	SQLITE_LIST_ERROR             string = "Error listing records...: "
+	SQLITE_UPDATE_ERROR           string = "Error updating record..."
+	SQLITE_ROWS_NOT_WRITTEN_ERROR string = "No record written...: "
+	SQLITE_DELETE_ERROR           string = "Error deleting record..."
+	SQLITE_RESULTS_ERROR          string = "Error getting results...: "
+	SQLITE_ROWS_AFFECTED_ERROR    string = "Error getting rows affected...: "
+	SQLITE_PING_ERROR             string = "Error pinging database..."
+)
+
+// The trivia table shares the schema used by the PostgreSQL and MySQL drivers. Since the
+// database file is owned by the service, the table is created when it does not exist.
+const createTableQuery string = `CREATE TABLE IF NOT EXISTS trivia (
+	question_id       TEXT NOT NULL PRIMARY KEY,
+	question          TEXT NOT NULL,
+	category          TEXT NOT NULL DEFAULT '',
+	answer            TEXT NOT NULL,
+	alternate_answers TEXT NOT NULL DEFAULT '[]',
+	options           TEXT NOT NULL DEFAULT '[]',
+	correct_options   TEXT NOT NULL DEFAULT '[]',
+	shuffle           INTEGER NOT NULL DEFAULT 0,
+	expires_at        TIMESTAMP,
+	lease_token       TEXT,
+	lease_expires_at  TIMESTAMP,
+	version           INTEGER NOT NULL DEFAULT 1
+);`
+
+// Indexes serving the category filter of List and the reaper
+const createIndexQuery string = `CREATE INDEX IF NOT EXISTS trivia_category_idx ON trivia (category, question_id);
+CREATE INDEX IF NOT EXISTS trivia_expires_at_idx ON trivia (expires_at);`
+
+// Columns added to the trivia table after it was first released. Database files created
+// by an earlier version get the missing columns when they are opened.
+var addedColumns = []struct {
+	name       string
+	definition string
+}{
+	{name: "alternate_answers", definition: "TEXT NOT NULL DEFAULT '[]'"},
+	{name: "options", definition: "TEXT NOT NULL DEFAULT '[]'"},
+	{name: "correct_options", definition: "TEXT NOT NULL DEFAULT '[]'"},
+	{name: "shuffle", definition: "INTEGER NOT NULL DEFAULT 0"},
+	{name: "expires_at", definition: "TIMESTAMP"},
+	{name: "lease_token", definition: "TEXT"},
+	{name: "lease_expires_at", definition: "TIMESTAMP"},
+	{name: "version", definition: "INTEGER NOT NULL DEFAULT 1"},
+}
+
+// Filter skipping the expired records the reaper has not removed yet. Times are stored as UTC
+// text, the current time is bound to nowParam from now() so both sides compare in the same format.
+func notExpired(nowParam string) string {
+	return "(expires_at IS NULL OR expires_at > " + nowParam + ")"
+}
+
+// Filter skipping the checked out records, a record returns to the pool when its lease expires
+func notLeased(nowParam string) string {
+	return "(lease_expires_at IS NULL OR lease_expires_at <= " + nowParam + ")"
+}
+
+// Current time bound to the parameter of notExpired and notLeased
+func now() time.Time {
+	return time.Now().UTC()
+}
+
+// Record columns read by scanQuestionTable
+const QUESTION_COLUMNS string = "question, category, answer, alternate_answers, options, correct_options, shuffle, expires_at, version"
+
+// Columns overwritten by Upsert when the question ID is already stored, the version of the stored
+// record is incremented
+const UPSERT_COLUMNS string = "question = excluded.question, category = excluded.category, answer = excluded.answer, alternate_answers = excluded.alternate_answers, options = excluded.options, correct_options = excluded.correct_options, shuffle = excluded.shuffle, expires_at = excluded.expires_at, version = trivia.version + 1"
+
+// Implemented by *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// Scan the QUESTION_COLUMNS of a row into a record
+func scanQuestionTable(row rowScanner) (messages.QuestionTable, error) {
+	var qTable messages.QuestionTable
+	scanErr := row.Scan(&qTable.Question, &qTable.Category, &qTable.Answer, &qTable.AlternateAnswers, &qTable.Options, &qTable.CorrectOptions, &qTable.Shuffle, &qTable.ExpiresAt, &qTable.Version)
+
+	return qTable, scanErr
+}
+
+// Scan the question ID followed by the QUESTION_COLUMNS of a row into a record
+func scanQuestionRecord(row rowScanner) (messages.QuestionRecord, error) {
+	var qRecord messages.QuestionRecord
+	scanErr := row.Scan(&qRecord.QuestionID, &qRecord.Question, &qRecord.Category, &qRecord.Answer, &qRecord.AlternateAnswers, &qRecord.Options, &qRecord.CorrectOptions, &qRecord.Shuffle, &qRecord.ExpiresAt, &qRecord.Version)
+
+	return qRecord, scanErr
+}
+
+// Report whether the error is a duplicate primary key error
+func isDuplicateKey(execErr error) bool {
+	var sqliteErr sqlite3.Error
+	return errors.As(execErr, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintPrimaryKey
+}
+
+// Give a kind to an error returned by SQLite. A busy or locked database file is a timeout,
+// the database file failing to open is unavailable.
+func dbError(dbErr error) error {
+	var sqliteErr sqlite3.Error
+	if errors.As(dbErr, &sqliteErr) {
+		switch sqliteErr.Code {
+		case sqlite3.ErrBusy, sqlite3.ErrLocked:
+			return messages.TimeoutError(dbErr)
+		case sqlite3.ErrCantOpen:
+			return messages.UnavailableError(dbErr)
+		}
+	}
+
+	return messages.ClassifyError(dbErr)
+}
+
+type dbModel struct {
+	cfgData *config.ConfigData
+
+	// Database handle shared by every operation, opened along with the driver
+	dbMutex sync.Mutex
+	db      *sql.DB
+}
+
+// Create the trivia table and add the columns missing from older database files
+func ensureSchema(ctx context.Context, db *sql.DB) error {
+	_, execErr := db.ExecContext(ctx, createTableQuery)
+	if execErr != nil {
+		return execErr
+	}
+
+	rows, queryErr := db.QueryContext(ctx, "SELECT name FROM pragma_table_info('trivia');")
+	if queryErr != nil {
+		return queryErr
+	}
+
+	columns := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		scanErr := rows.Scan(&name)
+		if scanErr != nil {
+			rows.Close()
+			return scanErr
+		}
+		columns[name] = true
+	}
+	rows.Close()
+
+	for _, column := range addedColumns {
+		if columns[column.name] {
+			continue
+		}
+
+		log.Print("Adding column to trivia table: ", column.name)
+		_, execErr = db.ExecContext(ctx, "ALTER TABLE trivia ADD COLUMN "+column.name+" "+column.definition+";")
+		if execErr != nil {
+			return execErr
+		}
+	}
+
+	_, execErr = db.ExecContext(ctx, createIndexQuery)
+
+	return execErr
+}
+
+// Open database, the database file is opened and its schema checked once, the handle is then
+// shared until Close
+func (dbm *dbModel) Open(driverName string) (*sql.DB, error) {
+	dbm.dbMutex.Lock()
+	defer dbm.dbMutex.Unlock()
+
+	if dbm.db != nil {
+		return dbm.db, nil
+	}
+
+	log.Println("Opening SQLite database")
+
+	// Open database file
+	dbPath := dbm.cfgData.SQLite.Path
+	if len(dbPath) == 0 {
+		dbPath = SQLITE_DEFAULT_PATH
+	}
+
+	dataSourceName := fmt.Sprintf("file:%s?_busy_timeout=%d", dbPath, SQLITE_BUSY_TIMEOUT_MS)
+	db, openErr := sql.Open(driverName, dataSourceName)
+	if openErr != nil {
+		log.Print(SQLITE_DB_NAME_MSG+SQLITE_OPEN_ERROR, openErr.Error())
+		return nil, dbError(openErr)
+	}
+
+	// Make sure the trivia table exists
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(dbm.cfgData.OperationTimeoutMS)*time.Millisecond)
+	defer cancel()
+
+	schemaErr := ensureSchema(ctx, db)
+	if schemaErr != nil {
+		log.Print(SQLITE_DB_NAME_MSG+SQLITE_CREATE_TABLE_ERROR, schemaErr.Error())
+		db.Close()
+		return nil, dbError(schemaErr)
+	}
+
+	dbm.db = db
+
+	return db, nil
+}
+
+// Close the database, the next operation opens it again
+func (dbm *dbModel) Close() error {
+	dbm.dbMutex.Lock()
+	defer dbm.dbMutex.Unlock()
+
+	if dbm.db == nil {
+		return nil
+	}
+
+	log.Println("Closing SQLite database")
+
+	closeErr := dbm.db.Close()
+	dbm.db = nil
+	if closeErr != nil {
+		log.Print(SQLITE_DB_NAME_MSG+SQLITE_CLOSE_ERROR, closeErr.Error())
+		return closeErr
+	}
+
+	return nil
+}
+
+// Ping database by verifying the database file can be reached
+func (dbm *dbModel) Ping(ctx context.Context) error {
+	db, openErr := dbm.Open(SQLITE_SQL_DRIVER_NAME)
+	if openErr != nil {
+		log.Print(SQLITE_DB_NAME_MSG+SQLITE_OPEN_ERROR, openErr.Error())
+		return openErr
+	}
+
+	pingErr := db.PingContext(ctx)
+	if pingErr != nil {
+		log.Print(SQLITE_DB_NAME_MSG+SQLITE_PING_ERROR, pingErr.Error())
+		return messages.UnavailableError(pingErr)
+	}
+
+	return nil
+}
+
+// Insert a single record into table
+func (dbm *dbModel) Insert(ctx context.Context, qRequest messages.QuestionRequest) (int64, error) {
+	db, openErr := dbm.Open(SQLITE_SQL_DRIVER_NAME)
+	if openErr != nil {
+		log.Print(SQLITE_DB_NAME_MSG+SQLITE_OPEN_ERROR, openErr.Error())
+		return messages.RESULTS_DEFAULT, openErr
+	}
+
+	log.Print("Adding a new record to the database")
+	queryStr := "INSERT INTO trivia (question_id, " + QUESTION_COLUMNS + ") VALUES (?1, ?2, ?3, ?4, ?5, ?6, ?7, ?8, ?9, ?10);"
+	sqlDB, execErr := db.ExecContext(ctx, queryStr, qRequest.QuestionID, qRequest.Question, qRequest.Category, qRequest.Answer, qRequest.AlternateAnswers, qRequest.Options, qRequest.CorrectOptions, qRequest.ShuffleOptions(), qRequest.ExpiresAt, messages.INITIAL_VERSION)
+	if isDuplicateKey(execErr) {
+		log.Print(SQLITE_DB_NAME_MSG+SQLITE_RECORD_EXISTS_ERROR, qRequest.QuestionID)
+		return messages.RESULTS_DEFAULT, messages.ErrRecordExists
+	} else if execErr != nil {
+		log.Print(SQLITE_DB_NAME_MSG + SQLITE_INSERT_ERROR)
+		return messages.RESULTS_DEFAULT, dbError(execErr)
+	}
+
+	rowsAffected, rowsAffectedErr := sqlDB.RowsAffected()
+	if rowsAffectedErr != nil {
+		log.Print(SQLITE_DB_NAME_MSG+SQLITE_ROWS_AFFECTED_ERROR, rowsAffectedErr.Error())
+		return messages.RESULTS_DEFAULT, dbError(rowsAffectedErr)
+	}
+
+	return rowsAffected, nil
+}
+
+// Insert a single record into table, overwriting the stored record with the same question ID
+func (dbm *dbModel) Upsert(ctx context.Context, qRequest messages.QuestionRequest) (int64, error) {
+	db, openErr := dbm.Open(SQLITE_SQL_DRIVER_NAME)
+	if openErr != nil {
+		log.Print(SQLITE_DB_NAME_MSG+SQLITE_OPEN_ERROR, openErr.Error())
+		return messages.RESULTS_DEFAULT, openErr
+	}
+
+	log.Print("Storing a record in the database")
+	queryStr := "INSERT INTO trivia (question_id, " + QUESTION_COLUMNS + ") VALUES (?1, ?2, ?3, ?4, ?5, ?6, ?7, ?8, ?9, ?10) ON CONFLICT (question_id) DO UPDATE SET " + UPSERT_COLUMNS
+	sqlDB, execErr := db.ExecContext(ctx, queryStr, qRequest.QuestionID, qRequest.Question, qRequest.Category, qRequest.Answer, qRequest.AlternateAnswers, qRequest.Options, qRequest.CorrectOptions, qRequest.ShuffleOptions(), qRequest.ExpiresAt, messages.INITIAL_VERSION)
+	if execErr != nil {
+		log.Print(SQLITE_DB_NAME_MSG+SQLITE_UPSERT_ERROR, execErr.Error())
+		return messages.RESULTS_DEFAULT, dbError(execErr)
+	}
+
+	rowsAffected, rowsAffectedErr := sqlDB.RowsAffected()
+	if rowsAffectedErr != nil {
+		log.Print(SQLITE_DB_NAME_MSG+SQLITE_ROWS_AFFECTED_ERROR, rowsAffectedErr.Error())
+		return messages.RESULTS_DEFAULT, dbError(rowsAffectedErr)
+	}
+
+	return rowsAffected, nil
+}
+
+// Insert several records into table. The records are written by a prepared statement in a
+// single transaction, a failed row does not abort the transaction so every record gets its own
+// result. Records whose question ID is already stored are reported with messages.ErrRecordExists.
+func (dbm *dbModel) InsertBatch(ctx context.Context, qRequests []messages.QuestionRequest) ([]error, error) {
+	db, openErr := dbm.Open(SQLITE_SQL_DRIVER_NAME)
+	if openErr != nil {
+		log.Print(SQLITE_DB_NAME_MSG+SQLITE_OPEN_ERROR, openErr.Error())
+		return nil, openErr
+	}
+
+	log.Print("Adding new records to the database, count: ", len(qRequests))
+	tx, txErr := db.BeginTx(ctx, nil)
+	if txErr != nil {
+		log.Print(SQLITE_DB_NAME_MSG+SQLITE_TRANSACTION_ERROR, txErr.Error())
+		return nil, dbError(txErr)
+	}
+	defer tx.Rollback()
+
+	queryStr := "INSERT INTO trivia (question_id, " + QUESTION_COLUMNS + ") VALUES (?1, ?2, ?3, ?4, ?5, ?6, ?7, ?8, ?9, ?10);"
+	stmt, prepareErr := tx.PrepareContext(ctx, queryStr)
+	if prepareErr != nil {
+		log.Print(SQLITE_DB_NAME_MSG+SQLITE_INSERT_BATCH_ERROR, prepareErr.Error())
+		return nil, dbError(prepareErr)
+	}
+	defer stmt.Close()
+
+	itemErrs := make([]error, len(qRequests))
+	for idx, qRequest := range qRequests {
+		_, execErr := stmt.ExecContext(ctx, qRequest.QuestionID, qRequest.Question, qRequest.Category, qRequest.Answer, qRequest.AlternateAnswers, qRequest.Options, qRequest.CorrectOptions, qRequest.ShuffleOptions(), qRequest.ExpiresAt, messages.INITIAL_VERSION)
+		if isDuplicateKey(execErr) {
+			itemErrs[idx] = messages.ErrRecordExists
+		} else if execErr != nil {
+			log.Print(SQLITE_DB_NAME_MSG+SQLITE_INSERT_BATCH_ERROR, execErr.Error())
+			itemErrs[idx] = dbError(execErr)
+		}
+	}
+
+	commitErr := tx.Commit()
+	if commitErr != nil {
+		log.Print(SQLITE_DB_NAME_MSG+SQLITE_TRANSACTION_ERROR, commitErr.Error())
+		return nil, dbError(commitErr)
+	}
+
+	return itemErrs, nil
+}
+
+// Get a single record from table
+func (dbm *dbModel) Get(ctx context.Context, questionID string) (messages.QuestionTable, error) {
+	db, openErr := dbm.Open(SQLITE_SQL_DRIVER_NAME)
+	if openErr != nil {
+		log.Print(SQLITE_DB_NAME_MSG+SQLITE_OPEN_ERROR, openErr.Error())
+		return messages.QuestionTable{}, openErr
+	}
+
+	log.Print("Getting a single record from the database")
+	queryStr := "SELECT " + QUESTION_COLUMNS + " FROM trivia WHERE question_id = ?1 AND " + notExpired("?2") + ";"
+	qTable, scanErr := scanQuestionTable(db.QueryRowContext(ctx, queryStr, questionID, now()))
+	if scanErr == sql.ErrNoRows {
+		return messages.QuestionTable{}, messages.ErrNotFound
+	} else if scanErr != nil {
+		log.Print(SQLITE_DB_NAME_MSG+SQLITE_GET_ERROR, scanErr.Error())
+		return messages.QuestionTable{}, dbError(scanErr)
+	}
+
+	return qTable, nil
+}
+
+// Consume a single record from table, the record is deleted and returned by the same statement
+func (dbm *dbModel) Consume(ctx context.Context, questionID string) (messages.QuestionTable, error) {
+	db, openErr := dbm.Open(SQLITE_SQL_DRIVER_NAME)
+	if openErr != nil {
+		log.Print(SQLITE_DB_NAME_MSG+SQLITE_OPEN_ERROR, openErr.Error())
+		return messages.QuestionTable{}, openErr
+	}
+
+	log.Print("Consuming a single record from the database")
+	queryStr := "DELETE FROM trivia WHERE question_id = ?1 AND " + notExpired("?2") + " AND " + notLeased("?2") + " RETURNING " + QUESTION_COLUMNS + ";"
+	qTable, scanErr := scanQuestionTable(db.QueryRowContext(ctx, queryStr, questionID, now()))
+	if scanErr == sql.ErrNoRows {
+		return messages.QuestionTable{}, messages.ErrNotFound
+	} else if scanErr != nil {
+		log.Print(SQLITE_DB_NAME_MSG+SQLITE_CONSUME_ERROR, scanErr.Error())
+		return messages.QuestionTable{}, dbError(scanErr)
+	}
+
+	return qTable, nil
+}
+
+// Lease a single record from table, the record stays in the table but cannot be consumed or
+// drawn until the lease expires
+func (dbm *dbModel) Lease(ctx context.Context, questionID string, leaseToken string, leaseExpiresAt time.Time) (messages.QuestionTable, error) {
+	db, openErr := dbm.Open(SQLITE_SQL_DRIVER_NAME)
+	if openErr != nil {
+		log.Print(SQLITE_DB_NAME_MSG+SQLITE_OPEN_ERROR, openErr.Error())
+		return messages.QuestionTable{}, openErr
+	}
+
+	log.Print("Leasing a single record from the database")
+	queryStr := "UPDATE trivia SET lease_token = ?2, lease_expires_at = ?3 WHERE question_id = ?1 AND " + notExpired("?4") + " AND " + notLeased("?4") + " RETURNING " + QUESTION_COLUMNS + ";"
+	qTable, scanErr := scanQuestionTable(db.QueryRowContext(ctx, queryStr, questionID, leaseToken, leaseExpiresAt.UTC(), now()))
+	if scanErr == sql.ErrNoRows {
+		return messages.QuestionTable{}, messages.ErrNotFound
+	} else if scanErr != nil {
+		log.Print(SQLITE_DB_NAME_MSG+SQLITE_LEASE_ERROR, scanErr.Error())
+		return messages.QuestionTable{}, dbError(scanErr)
+	}
+
+	return qTable, nil
+}
+
+// Confirm the lease of a single record, the record is returned and deleted when the lease
+// token matches an unexpired lease
+func (dbm *dbModel) Confirm(ctx context.Context, questionID string, leaseToken string) (messages.QuestionTable, error) {
+	db, openErr := dbm.Open(SQLITE_SQL_DRIVER_NAME)
+	if openErr != nil {
+		log.Print(SQLITE_DB_NAME_MSG+SQLITE_OPEN_ERROR, openErr.Error())
+		return messages.QuestionTable{}, openErr
+	}
+
+	log.Print("Confirming the lease of a single record from the database")
+	queryStr := "DELETE FROM trivia WHERE question_id = ?1 AND lease_token = ?2 AND lease_expires_at > ?3 AND " + notExpired("?3") + " RETURNING " + QUESTION_COLUMNS + ";"
+	qTable, scanErr := scanQuestionTable(db.QueryRowContext(ctx, queryStr, questionID, leaseToken, now()))
+	if scanErr == sql.ErrNoRows {
+		return messages.QuestionTable{}, messages.ErrNotFound
+	} else if scanErr != nil {
+		log.Print(SQLITE_DB_NAME_MSG+SQLITE_CONFIRM_ERROR, scanErr.Error())
+		return messages.QuestionTable{}, dbError(scanErr)
+	}
+
+	return qTable, nil
+}
+
+// List records in question ID order, the primary key and the category index serve the query
+func (dbm *dbModel) List(ctx context.Context, lRequest messages.ListRequest) ([]messages.QuestionRecord, error) {
+	db, openErr := dbm.Open(SQLITE_SQL_DRIVER_NAME)
+	if openErr != nil {
+		log.Print(SQLITE_DB_NAME_MSG+SQLITE_OPEN_ERROR, openErr.Error())
+		return nil, openErr
+	}
+
+	log.Print("Listing records from the database, after ID: ", lRequest.After)
+	queryStr := "SELECT question_id, " + QUESTION_COLUMNS + " FROM trivia WHERE question_id > ?1 AND " + notExpired("?3") + " ORDER BY question_id LIMIT ?2;"
+	args := []interface{}{lRequest.After, lRequest.Limit, now()}
+	if len(lRequest.Category) > 0 {
+		queryStr = "SELECT question_id, " + QUESTION_COLUMNS + " FROM trivia WHERE category = ?4 AND question_id > ?1 AND " + notExpired("?3") + " ORDER BY question_id LIMIT ?2;"
+		args = append(args, lRequest.Category)
+	}
+
+	rows, queryErr := db.QueryContext(ctx, queryStr, args...)
+	if queryErr != nil {
+		log.Print(SQLITE_DB_NAME_MSG+SQLITE_LIST_ERROR, queryErr.Error())
+		return nil, dbError(queryErr)
+	}
+	defer rows.Close()
+
+	qRecords := make([]messages.QuestionRecord, 0)
+	for rows.Next() {
+		qRecord, scanErr := scanQuestionRecord(rows)
+		if scanErr != nil {
+			log.Print(SQLITE_DB_NAME_MSG+SQLITE_RESULTS_ERROR, scanErr.Error())
+			return nil, dbError(scanErr)
+		}
+
+		qRecords = append(qRecords, qRecord)
+	}
+
+	rowsErr := rows.Err()
+	if rowsErr != nil {
+		log.Print(SQLITE_DB_NAME_MSG+SQLITE_RESULTS_ERROR, rowsErr.Error())
+		return nil, dbError(rowsErr)
+	}
+
+	return qRecords, nil
+}
+
+// Draw a random record and consume it, the record is picked and deleted by one statement. The
+// excluded question IDs are passed as a JSON array.
+func (dbm *dbModel) Draw(ctx context.Context, dRequest messages.DrawRequest) (messages.QuestionRecord, error) {
+	db, openErr := dbm.Open(SQLITE_SQL_DRIVER_NAME)
+	if openErr != nil {
+		log.Print(SQLITE_DB_NAME_MSG+SQLITE_OPEN_ERROR, openErr.Error())
+		return messages.QuestionRecord{}, openErr
+	}
+
+	log.Print("Drawing a record from the database, category: ", dRequest.Category)
+	filterStr := "question_id NOT IN (SELECT value FROM json_each(?1)) AND " + notExpired("?2") + " AND " + notLeased("?2")
+	args := []interface{}{messages.StringList(dRequest.Exclude), now()}
+	if len(dRequest.Category) > 0 {
+		filterStr = "category = ?3 AND " + filterStr
+		args = append(args, dRequest.Category)
+	}
+
+	queryStr := "DELETE FROM trivia WHERE question_id = (SELECT question_id FROM trivia WHERE " + filterStr + " ORDER BY random() LIMIT 1) RETURNING question_id, " + QUESTION_COLUMNS + ";"
+	qRecord, scanErr := scanQuestionRecord(db.QueryRowContext(ctx, queryStr, args...))
+	if scanErr == sql.ErrNoRows {
+		return messages.QuestionRecord{}, messages.ErrNotFound
+	} else if scanErr != nil {
+		log.Print(SQLITE_DB_NAME_MSG+SQLITE_DRAW_ERROR, scanErr.Error())
+		return messages.QuestionRecord{}, dbError(scanErr)
+	}
+
+	return qRecord, nil
+}
+
+// Tell why a write affected no rows, the record is missing or, when the write was conditional, at
+// another version
+func (dbm *dbModel) noRowsError(ctx context.Context, questionID string, version int64) error {
+	if version == 0 {
+		return messages.ErrNotFound
+	}
+
+	_, getErr := dbm.Get(ctx, questionID)
+	if getErr != nil {
+		return getErr
+	}
+
+	return messages.ErrVersionMismatch
+}
+
+// Update a single record in table, a version other than 0 must match the stored version.
+// The version written is returned with the rows affected.
+func (dbm *dbModel) Update(ctx context.Context, qRequest messages.QuestionRequest) (int64, int64, error) {
+	db, openErr := dbm.Open(SQLITE_SQL_DRIVER_NAME)
+	if openErr != nil {
+		log.Print(SQLITE_DB_NAME_MSG+SQLITE_OPEN_ERROR, openErr.Error())
+		return messages.RESULTS_DEFAULT, messages.RESULTS_DEFAULT, openErr
+	}
+
+	log.Println("Updating a single record in the database")
+	queryStr := "UPDATE trivia SET question = ?2, category = ?3, answer = ?4, alternate_answers = ?5, options = ?6, correct_options = ?7, shuffle = ?8, expires_at = ?9, version = version + 1 WHERE question_id = ?1 AND " + notExpired("?10") + " AND (?11 = 0 OR version = ?11) RETURNING version;"
+	var version int64
+	scanErr := db.QueryRowContext(ctx, queryStr, qRequest.QuestionID, qRequest.Question, qRequest.Category, qRequest.Answer, qRequest.AlternateAnswers, qRequest.Options, qRequest.CorrectOptions, qRequest.ShuffleOptions(), qRequest.ExpiresAt, now(), qRequest.Version).Scan(&version)
+	if scanErr == sql.ErrNoRows {
+		noRowsErr := dbm.noRowsError(ctx, qRequest.QuestionID, qRequest.Version)
+		log.Print(SQLITE_DB_NAME_MSG+SQLITE_ROWS_NOT_WRITTEN_ERROR, noRowsErr.Error())
+		return messages.RESULTS_DEFAULT, messages.RESULTS_DEFAULT, noRowsErr
+	} else if scanErr != nil {
+		log.Print(SQLITE_DB_NAME_MSG+SQLITE_UPDATE_ERROR, scanErr.Error())
+		return messages.RESULTS_DEFAULT, messages.RESULTS_DEFAULT, dbError(scanErr)
+	}
+
+	return RECORD_AFFECTED, version, nil
+}
+
+// Delete a single record from table, a version other than 0 must match the stored version
+func (dbm *dbModel) Delete(ctx context.Context, questionID string, version int64) (int64, error) {
+	db, openErr := dbm.Open(SQLITE_SQL_DRIVER_NAME)
+	if openErr != nil {
+		log.Print(SQLITE_DB_NAME_MSG+SQLITE_OPEN_ERROR, openErr.Error())
+		return messages.RESULTS_DEFAULT, openErr
+	}
+
+	log.Println("deleting a single record from the database")
+	queryStr := "DELETE FROM trivia WHERE question_id = ?1 AND " + notExpired("?2") + " AND (?3 = 0 OR version = ?3)"
+	sqlDB, execErr := db.ExecContext(ctx, queryStr, questionID, now(), version)
+	if execErr != nil {
+		log.Print(SQLITE_DB_NAME_MSG+SQLITE_DELETE_ERROR, execErr.Error())
+		return messages.RESULTS_DEFAULT, dbError(execErr)
+	}
+
+	rowsAffected, rowsAffectedErr := sqlDB.RowsAffected()
+	if rowsAffectedErr != nil {
+		log.Print(SQLITE_DB_NAME_MSG+SQLITE_ROWS_AFFECTED_ERROR, rowsAffectedErr.Error())
+		return messages.RESULTS_DEFAULT, nil
+	}
+
+	if rowsAffected == messages.RESULTS_DEFAULT {
+		noRowsErr := dbm.noRowsError(ctx, questionID, version)
+		log.Print(SQLITE_DB_NAME_MSG+SQLITE_ROWS_NOT_WRITTEN_ERROR, noRowsErr.Error())
+		return messages.RESULTS_DEFAULT, noRowsErr
+	}
+
+	return rowsAffected, nil
+}
+
+// Reap removes the records whose expiry time has passed
+func (dbm *dbModel) Reap(ctx context.Context) (int64, error) {
+	db, openErr := dbm.Open(SQLITE_SQL_DRIVER_NAME)
+	if openErr != nil {
+		log.Print(SQLITE_DB_NAME_MSG+SQLITE_OPEN_ERROR, openErr.Error())
+		return messages.RESULTS_DEFAULT, openErr
+	}
+
+	log.Println("removing expired records from the database")
+	queryStr := "DELETE FROM trivia WHERE expires_at <= ?1"
+	sqlDB, execErr := db.ExecContext(ctx, queryStr, now())
+	if execErr != nil {
+		log.Print(SQLITE_DB_NAME_MSG+SQLITE_REAP_ERROR, execErr.Error())
+		return messages.RESULTS_DEFAULT, dbError(execErr)
+	}
+
+	rowsAffected, rowsAffectedErr := sqlDB.RowsAffected()
+	if rowsAffectedErr != nil {
+		log.Print(SQLITE_DB_NAME_MSG+SQLITE_ROWS_AFFECTED_ERROR, rowsAffectedErr.Error())
+		return messages.RESULTS_DEFAULT, nil
+	}
+
+	return rowsAffected, nil
+}
+
+func GetSQLiteModel(cfgData *config.ConfigData) *dbModel {
+	log.Print("Creating SQLite database model")
+
+	// Initialize SQLite database model
+	sqliteModel := new(dbModel)
+
+	// Assign config data
+	sqliteModel.cfgData = cfgData
+
+	// Open the database file along with the driver, an operation opens it again when it failed
+	sqliteModel.Open(SQLITE_SQL_DRIVER_NAME)
+
+	return sqliteModel
+}
@@ -1,13 +1,19 @@
 package gocache
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"log"
+	"math/rand"
+	"sort"
+	"sync"
 	"time"
 
 	"github.com/patrickmn/go-cache"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/sflewis2970/datastore-service/config"
+	"github.com/sflewis2970/datastore-service/metrics"
 	"github.com/sflewis2970/datastore-service/models/messages"
 )
 
@@ -16,18 +22,25 @@ const (
 	GOCACHE_CREATE_CACHE_MSG string = "Creating in-memory map to store data..."
 )
 
+// Number of records affected by a successful single record operation
+const RECORD_AFFECTED int64 = 1
+
 const (
-	GOCACHE_GET_CONFIG_ERROR      string = "Getting config error...: "
-	GOCACHE_GET_CONFIG_DATA_ERROR string = "Getting config data error...: "
-	GOCACHE_OPEN_ERROR            string = "Open method not implemented..."
-	GOCACHE_INSERT_ERROR          string = "Insert error..."
-	GOCACHE_GET_ERROR             string = "Get error..."
-	GOCACHE_UPDATE_ERROR          string = "Update error..."
-	GOCACHE_DELETE_ERROR          string = "Delete error..."
-	GOCACHE_RESULTS_ERROR         string = "Results error...: "
-	GOCACHE_ROWS_AFFECTED_ERROR   string = "Rows affected error...: "
-	GOCACHE_PING_ERROR            string = "In-memory cache has not been created..."
-	GOCACHE_CONVERSION_ERROR      string = "Conversion error...: "
+	GOCACHE_GET_CONFIG_ERROR       string = "Getting config error...: "
+	GOCACHE_GET_CONFIG_DATA_ERROR  string = "Getting config data error...: "
+	GOCACHE_OPEN_ERROR             string = "Open method not implemented..."
+	GOCACHE_INSERT_ERROR           string = "Insert error..."
+	GOCACHE_RECORD_EXISTS_ERROR    string = "Record already exists...: "
+	GOCACHE_GET_ERROR              string = "Get error..."
+	GOCACHE_ITEM_NOT_FOUND_ERROR   string = "Item not found...: "
+	GOCACHE_UPDATE_ERROR           string = "Update error..."
+	GOCACHE_DELETE_ERROR           string = "Delete error..."
+	GOCACHE_RESULTS_ERROR          string = "Results error...: "
+	GOCACHE_ROWS_AFFECTED_ERROR    string = "Rows affected error...: "
+	GOCACHE_PING_ERROR             string = "In-memory cache has not been created..."
+	GOCACHE_CONVERSION_ERROR       string = "Conversion error...: "
+	GOCACHE_LEASE_NOT_HELD_ERROR   string = "Lease not held...: "
+	GOCACHE_VERSION_MISMATCH_ERROR string = "Version mismatch, stored version...: "
 )
 
 var goCacheModel *dbModel
@@ -35,39 +48,123 @@ var goCacheModel *dbModel
 type dbModel struct {
 	cfgData  *config.ConfigData
 	memCache *cache.Cache
+
+	// Lease tokens of the checked out records, keyed by question ID. A lease item expires with
+	// the lease, which returns the record to the pool.
+	leaseCache *cache.Cache
+
+	// go-cache has no get-and-delete operation, consumeMutex makes the pair of a consume or a
+	// delete atomic along with the lease and version checks, so concurrent deletes delete the
+	// record once. It also guards random, which is not safe for concurrent use.
+	consumeMutex sync.Mutex
+	random       *rand.Rand
 }
 
 func (dbm *dbModel) Open(sqlDriverName string) (*sql.DB, error) {
 	return nil, errors.New(GOCACHE_DB_NAME_MSG + GOCACHE_OPEN_ERROR)
 }
 
+// Close the database, the records are kept in memory so there is nothing to release
+func (dbm *dbModel) Close() error {
+	return nil
+}
+
 // Ping database server, since this is local to the server make sure the object for storing data is created
-func (dbm *dbModel) Ping() error {
+func (dbm *dbModel) Ping(ctx context.Context) error {
 	if dbm.memCache == nil {
-		return errors.New(GOCACHE_DB_NAME_MSG + GOCACHE_PING_ERROR)
+		return messages.UnavailableError(errors.New(GOCACHE_DB_NAME_MSG + GOCACHE_PING_ERROR))
 	}
 
 	return nil
 }
 
+// Item expiration of a record, records without an expiry use the configured default expiration
+func expiration(qt messages.QuestionTable) time.Duration {
+	if qt.ExpiresAt == nil {
+		return cache.DefaultExpiration
+	}
+
+	return until(*qt.ExpiresAt)
+}
+
+// Item expiration of an item expiring at expiresAt. go-cache never expires items with a negative
+// duration, an item that expired in the meantime expires right away.
+func until(expiresAt time.Time) time.Duration {
+	untilExpiry := time.Until(expiresAt)
+	if untilExpiry <= 0 {
+		untilExpiry = time.Nanosecond
+	}
+
+	return untilExpiry
+}
+
+// Report the item expiration of records stored with the default expiration
+func withExpiration(qt messages.QuestionTable, expiresAt time.Time) messages.QuestionTable {
+	if qt.ExpiresAt == nil && !expiresAt.IsZero() {
+		qt.ExpiresAt = &expiresAt
+	}
+
+	return qt
+}
+
 // Insert a single record into table
-func (dbm *dbModel) Insert(qRequest messages.QuestionRequest) (int64, error) {
-	var qt messages.QuestionTable
-	qt.Question = qRequest.Question
-	qt.Category = qRequest.Category
-	qt.Answer = qRequest.Answer
+func (dbm *dbModel) Insert(ctx context.Context, qRequest messages.QuestionRequest) (int64, error) {
+	qt := messages.NewQuestionTable(qRequest)
 
 	log.Print("Adding a new record to map, ID: ", qRequest.QuestionID)
-	dbm.memCache.Set(qRequest.QuestionID, qt, cache.DefaultExpiration)
 
-	return messages.RESULTS_DEFAULT, nil
+	// Add only stores items that do not exist yet
+	addErr := dbm.memCache.Add(qRequest.QuestionID, qt, expiration(qt))
+	if addErr != nil {
+		log.Print(GOCACHE_DB_NAME_MSG+GOCACHE_RECORD_EXISTS_ERROR, qRequest.QuestionID)
+		return messages.RESULTS_DEFAULT, messages.ErrRecordExists
+	}
+
+	return RECORD_AFFECTED, nil
+}
+
+// Upsert stores the record, overwriting the stored record with the same question ID
+func (dbm *dbModel) Upsert(ctx context.Context, qRequest messages.QuestionRequest) (int64, error) {
+	dbm.consumeMutex.Lock()
+	defer dbm.consumeMutex.Unlock()
+
+	log.Print("Storing record in the map, ID: ", qRequest.QuestionID)
+
+	storedQt, getErr := dbm.Get(ctx, qRequest.QuestionID)
+	if getErr != nil && !errors.Is(getErr, messages.ErrNotFound) {
+		log.Print(GOCACHE_DB_NAME_MSG+GOCACHE_GET_ERROR, getErr)
+		return messages.RESULTS_DEFAULT, getErr
+	}
+
+	qt := messages.NewQuestionTable(qRequest)
+	qt.Version = storedQt.Version + 1
+
+	dbm.memCache.Set(qRequest.QuestionID, qt, expiration(qt))
+
+	return RECORD_AFFECTED, nil
+}
+
+// Insert several records into table, go-cache stores every record
+func (dbm *dbModel) InsertBatch(ctx context.Context, qRequests []messages.QuestionRequest) ([]error, error) {
+	log.Print("Adding new records to map, count: ", len(qRequests))
+
+	itemErrs := make([]error, len(qRequests))
+	for idx, qRequest := range qRequests {
+		qt := messages.NewQuestionTable(qRequest)
+		addErr := dbm.memCache.Add(qRequest.QuestionID, qt, expiration(qt))
+		if addErr != nil {
+			itemErrs[idx] = messages.ErrRecordExists
+		}
+	}
+
+	return itemErrs, nil
 }
 
 // Get a single record from table
-func (dbm *dbModel) Get(questionID string) (messages.QuestionTable, error) {
+func (dbm *dbModel) Get(ctx context.Context, questionID string) (messages.QuestionTable, error) {
 	log.Print("Getting record from the map, with ID: ", questionID)
 
-	item, itemFound := dbm.memCache.Get(questionID)
+	item, expiresAt, itemFound := dbm.memCache.GetWithExpiration(questionID)
 
 	var qt messages.QuestionTable
 	if itemFound {
@@ -76,33 +173,243 @@ func (dbm *dbModel) Get(questionID string) (messages.QuestionTable, error) {
 		if !ok {
 			log.Print(GOCACHE_DB_NAME_MSG+GOCACHE_CONVERSION_ERROR, item)
 		}
+		qt = withExpiration(qt, expiresAt)
 	} else {
 		log.Print(messages.NO_RESULTS_RETURNED_MSG)
+		return qt, messages.ErrNotFound
+	}
+
+	return qt, nil
+}
+
+// Consume a single record from table, the record is returned and deleted as one operation
+func (dbm *dbModel) Consume(ctx context.Context, questionID string) (messages.QuestionTable, error) {
+	dbm.consumeMutex.Lock()
+	defer dbm.consumeMutex.Unlock()
+
+	log.Print("Consuming record from the map, with ID: ", questionID)
+
+	// A checked out record is only consumed by confirming the lease
+	_, leased := dbm.leaseCache.Get(questionID)
+	if leased {
+		log.Print("Record is checked out, ID: ", questionID)
+		return messages.QuestionTable{}, messages.ErrNotFound
+	}
+
+	qt, getErr := dbm.Get(ctx, questionID)
+	if getErr != nil {
+		log.Print(GOCACHE_DB_NAME_MSG+GOCACHE_GET_ERROR, getErr)
+		return messages.QuestionTable{}, getErr
+	}
+
+	dbm.memCache.Delete(questionID)
+
+	return qt, nil
+}
+
+// Lease a single record from table, the record stays in the map but cannot be consumed or
+// drawn until the lease expires
+func (dbm *dbModel) Lease(ctx context.Context, questionID string, leaseToken string, leaseExpiresAt time.Time) (messages.QuestionTable, error) {
+	dbm.consumeMutex.Lock()
+	defer dbm.consumeMutex.Unlock()
+
+	log.Print("Leasing record from the map, with ID: ", questionID)
+
+	qt, getErr := dbm.Get(ctx, questionID)
+	if getErr != nil {
+		log.Print(GOCACHE_DB_NAME_MSG+GOCACHE_GET_ERROR, getErr)
+		return messages.QuestionTable{}, getErr
+	}
+
+	// Add fails while the record is leased
+	addErr := dbm.leaseCache.Add(questionID, leaseToken, until(leaseExpiresAt))
+	if addErr != nil {
+		log.Print("Record is checked out, ID: ", questionID)
+		return messages.QuestionTable{}, messages.ErrNotFound
 	}
 
 	return qt, nil
 }
 
-// Update a single record in table
-func (dbm *dbModel) Update(qRequest messages.QuestionRequest) (int64, error) {
+// Confirm the lease of a single record, the record is returned and deleted when the lease
+// token matches an unexpired lease
+func (dbm *dbModel) Confirm(ctx context.Context, questionID string, leaseToken string) (messages.QuestionTable, error) {
+	dbm.consumeMutex.Lock()
+	defer dbm.consumeMutex.Unlock()
+
+	log.Print("Confirming lease of record, with ID: ", questionID)
+
+	item, leased := dbm.leaseCache.Get(questionID)
+	if !leased || item != leaseToken {
+		log.Print(GOCACHE_DB_NAME_MSG+GOCACHE_LEASE_NOT_HELD_ERROR, questionID)
+		return messages.QuestionTable{}, messages.ErrNotFound
+	}
+
+	qt, getErr := dbm.Get(ctx, questionID)
+	if getErr != nil {
+		log.Print(GOCACHE_DB_NAME_MSG+GOCACHE_GET_ERROR, getErr)
+		return messages.QuestionTable{}, getErr
+	}
+
+	dbm.memCache.Delete(questionID)
+	dbm.leaseCache.Delete(questionID)
+
+	return qt, nil
+}
+
+// List records in question ID order, go-cache has no ordered iteration so every item is visited
+func (dbm *dbModel) List(ctx context.Context, lRequest messages.ListRequest) ([]messages.QuestionRecord, error) {
+	log.Print("Listing records from the map, after ID: ", lRequest.After)
+
+	qRecords := make([]messages.QuestionRecord, 0)
+	for questionID, item := range dbm.memCache.Items() {
+		if questionID <= lRequest.After {
+			continue
+		}
+
+		qt, ok := item.Object.(messages.QuestionTable)
+		if !ok {
+			log.Print(GOCACHE_DB_NAME_MSG+GOCACHE_CONVERSION_ERROR, questionID)
+			continue
+		}
+
+		if len(lRequest.Category) > 0 && qt.Category != lRequest.Category {
+			continue
+		}
+
+		if item.Expiration > 0 {
+			qt = withExpiration(qt, time.Unix(0, item.Expiration))
+		}
+
+		qRecords = append(qRecords, messages.QuestionRecord{QuestionID: questionID, QuestionTable: qt})
+	}
+
+	sort.Slice(qRecords, func(i, j int) bool {
+		return qRecords[i].QuestionID < qRecords[j].QuestionID
+	})
+
+	if len(qRecords) > lRequest.Limit {
+		qRecords = qRecords[:lRequest.Limit]
+	}
+
+	return qRecords, nil
+}
+
+// Draw a random record and remove it from the map, every item is visited to find the candidates
+func (dbm *dbModel) Draw(ctx context.Context, dRequest messages.DrawRequest) (messages.QuestionRecord, error) {
+	dbm.consumeMutex.Lock()
+	defer dbm.consumeMutex.Unlock()
+
+	log.Print("Drawing a record from the map, category: ", dRequest.Category)
+
+	excluded := make(map[string]bool)
+	for _, questionID := range dRequest.Exclude {
+		excluded[questionID] = true
+	}
+
+	candidates := make([]messages.QuestionRecord, 0)
+	for questionID, item := range dbm.memCache.Items() {
+		if excluded[questionID] {
+			continue
+		}
+
+		_, leased := dbm.leaseCache.Get(questionID)
+		if leased {
+			continue
+		}
+
+		qt, ok := item.Object.(messages.QuestionTable)
+		if !ok {
+			log.Print(GOCACHE_DB_NAME_MSG+GOCACHE_CONVERSION_ERROR, questionID)
+			continue
+		}
+
+		if len(dRequest.Category) > 0 && qt.Category != dRequest.Category {
+			continue
+		}
+
+		if item.Expiration > 0 {
+			qt = withExpiration(qt, time.Unix(0, item.Expiration))
+		}
+
+		candidates = append(candidates, messages.QuestionRecord{QuestionID: questionID, QuestionTable: qt})
+	}
+
+	if len(candidates) == 0 {
+		return messages.QuestionRecord{}, messages.ErrNotFound
+	}
+
+	qRecord := candidates[dbm.random.Intn(len(candidates))]
+	dbm.memCache.Delete(qRecord.QuestionID)
+
+	return qRecord, nil
+}
+
+// Update a single record in table, the version is checked and incremented under consumeMutex
+func (dbm *dbModel) Update(ctx context.Context, qRequest messages.QuestionRequest) (int64, int64, error) {
+	dbm.consumeMutex.Lock()
+	defer dbm.consumeMutex.Unlock()
+
 	log.Println("Updating record in the map")
 
-	var qt messages.QuestionTable
-	qt.Question = qRequest.Question
-	qt.Category = qRequest.Category
-	qt.Answer = qRequest.Answer
+	storedQt, getErr := dbm.Get(ctx, qRequest.QuestionID)
+	if getErr != nil {
+		log.Print(GOCACHE_DB_NAME_MSG+GOCACHE_GET_ERROR, getErr)
+		return messages.RESULTS_DEFAULT, messages.RESULTS_DEFAULT, getErr
+	}
+
+	if qRequest.Version > 0 && storedQt.Version != qRequest.Version {
+		log.Print(GOCACHE_DB_NAME_MSG+GOCACHE_VERSION_MISMATCH_ERROR, storedQt.Version)
+		return messages.RESULTS_DEFAULT, messages.RESULTS_DEFAULT, messages.ErrVersionMismatch
+	}
 
-	dbm.memCache.Set(qRequest.QuestionID, qt, cache.DefaultExpiration)
+	qt := messages.NewQuestionTable(qRequest)
+	qt.Version = storedQt.Version + 1
 
-	return messages.RESULTS_DEFAULT, nil
+	// Replace only updates existing items
+	replaceErr := dbm.memCache.Replace(qRequest.QuestionID, qt, expiration(qt))
+	if replaceErr != nil {
+		log.Print(GOCACHE_DB_NAME_MSG+GOCACHE_ITEM_NOT_FOUND_ERROR, qRequest.QuestionID)
+		return messages.RESULTS_DEFAULT, messages.RESULTS_DEFAULT, messages.ErrNotFound
+	}
+
+	return RECORD_AFFECTED, qt.Version, nil
 }
 
-// Delete a single record from table
-func (dbm *dbModel) Delete(questionID string) (int64, error) {
+// Delete a single record from table, a version other than 0 must match the stored version
+func (dbm *dbModel) Delete(ctx context.Context, questionID string, version int64) (int64, error) {
+	dbm.consumeMutex.Lock()
+	defer dbm.consumeMutex.Unlock()
+
 	log.Print("Deleting record with ID: ", questionID)
 
-	// Delete the record from map
+	item, itemFound := dbm.memCache.Get(questionID)
+	if !itemFound {
+		log.Print(GOCACHE_DB_NAME_MSG+GOCACHE_ITEM_NOT_FOUND_ERROR, questionID)
+		return messages.RESULTS_DEFAULT, messages.ErrNotFound
+	}
+
+	qt, ok := item.(messages.QuestionTable)
+	if !ok {
+		log.Print(GOCACHE_DB_NAME_MSG+GOCACHE_CONVERSION_ERROR, item)
+	}
+
+	if version > 0 && qt.Version != version {
+		log.Print(GOCACHE_DB_NAME_MSG+GOCACHE_VERSION_MISMATCH_ERROR, qt.Version)
+		return messages.RESULTS_DEFAULT, messages.ErrVersionMismatch
+	}
+
+	// Delete the record from map, along with its lease
 	dbm.memCache.Delete(questionID)
+	dbm.leaseCache.Delete(questionID)
+
+	return RECORD_AFFECTED, nil
+}
+
+// Reap expired records, go-cache also removes them every cleanup interval
+func (dbm *dbModel) Reap(ctx context.Context) (int64, error) {
+	log.Print("Removing expired records from the map")
+	dbm.memCache.DeleteExpired()
 
 	return messages.RESULTS_DEFAULT, nil
 }
@@ -122,8 +429,23 @@ func GetGoCacheModel(cfgData *config.ConfigData) *dbModel {
 		return nil
 	}
 
+	goCacheModel.random = rand.New(rand.NewSource(time.Now().UnixNano()))
+
 	log.Print(GOCACHE_DB_NAME_MSG + GOCACHE_CREATE_CACHE_MSG)
 	goCacheModel.memCache = cache.New(time.Duration(goCacheModel.cfgData.GoCache.DefaultExpiration)*time.Minute, time.Duration(goCacheModel.cfgData.GoCache.CleanupInterval)*time.Minute)
 
+	// Every lease is added with its own expiration
+	goCacheModel.leaseCache = cache.New(cache.NoExpiration, time.Duration(goCacheModel.cfgData.GoCache.CleanupInterval)*time.Minute)
+
+	// Number of records held by the cache
+	memCache := goCacheModel.memCache
+	metrics.Register(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: metrics.NAMESPACE,
+		Name:      "gocache_items",
+		Help:      "Records held by the go-cache in-memory cache, expired records included until they are cleaned up.",
+	}, func() float64 {
+		return float64(memCache.ItemCount())
+	}))
+
 	return goCacheModel
 }
@@ -6,10 +6,14 @@ import (
 	"encoding/json"
 	"errors"
 	"log"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/go-redis/redis/v8"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/sflewis2970/datastore-service/config"
+	"github.com/sflewis2970/datastore-service/metrics"
 	"github.com/sflewis2970/datastore-service/models/messages"
 )
 
@@ -18,6 +22,166 @@ const (
 	REDIS_CREATE_CACHE_MSG string = "Creating in-memory map to store data..."
 )
 
+// Number of records affected by a successful single record operation
+const RECORD_AFFECTED int64 = 1
+
+// Number of commands sent in a single pipeline by InsertBatch
+const REDIS_BATCH_CHUNK_SIZE int = 1000
+
+// Sorted sets indexing the question IDs for List and Draw. Every member has the same score, so
+// the members are ordered by question ID. Members are not removed when a record changes category,
+// Draw removes stale members when it samples them.
+const (
+	REDIS_QUESTIONS_SET_KEY       string = "trivia:questions"
+	REDIS_CATEGORY_SET_KEY_PREFIX string = "trivia:category:"
+)
+
+// Number of times a WATCH/MULTI transaction is retried after another client wrote the record
+const REDIS_WATCH_ATTEMPTS int = 3
+
+// Lease keys of the checked out records, the key holds the lease token and expires with the
+// lease, which returns the record to the pool
+const REDIS_LEASE_KEY_PREFIX string = "trivia:lease:"
+
+// Number of random members sampled by Draw in addition to the excluded question IDs, and the
+// number of samples taken before giving up
+const (
+	REDIS_DRAW_SAMPLE_SIZE int = 16
+	REDIS_DRAW_ATTEMPTS    int = 3
+)
+
+// Consume the record when it is not checked out and still belongs to the requested category,
+// ARGV[1] is empty when any category is accepted. A checked out record returns 0 so that Draw
+// keeps its index set member.
+var drawScript = redis.NewScript(`
+local value = redis.call('GET', KEYS[1])
+if not value then
+	return false
+end
+
+if redis.call('EXISTS', KEYS[2]) == 1 then
+	return 0
+end
+
+if ARGV[1] ~= '' and cjson.decode(value)['category'] ~= ARGV[1] then
+	return false
+end
+
+redis.call('DEL', KEYS[1])
+return value
+`)
+
+// Store the record and index it unless a record with the same question ID exists. ARGV[2] is
+// the expiration in milliseconds, 0 when the record never expires.
+var insertScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 1 then
+	return 0
+end
+
+if ARGV[2] == '0' then
+	redis.call('SET', KEYS[1], ARGV[1])
+else
+	redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2])
+end
+
+redis.call('ZADD', KEYS[2], 0, KEYS[1])
+redis.call('ZADD', KEYS[3], 0, KEYS[1])
+return 1
+`)
+
+// Consume the record unless it is checked out
+var consumeScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[2]) == 1 then
+	return false
+end
+
+return redis.call('GETDEL', KEYS[1])
+`)
+
+// Check out the record, SET NX fails while the record is leased. ARGV[1] is the lease token
+// and ARGV[2] the lease duration in milliseconds.
+var leaseScript = redis.NewScript(`
+local value = redis.call('GET', KEYS[1])
+if not value then
+	return false
+end
+
+if not redis.call('SET', KEYS[2], ARGV[1], 'NX', 'PX', ARGV[2]) then
+	return false
+end
+
+return value
+`)
+
+// Consume the record when ARGV[1] is the token of its unexpired lease
+var confirmScript = redis.NewScript(`
+if redis.call('GET', KEYS[2]) ~= ARGV[1] then
+	return false
+end
+
+redis.call('DEL', KEYS[2])
+return redis.call('GETDEL', KEYS[1])
+`)
+
+func leaseKey(questionID string) string {
+	return REDIS_LEASE_KEY_PREFIX + questionID
+}
+
+// Key expiration of a record, records without an expiry never expire
+func expiration(qt messages.QuestionTable) time.Duration {
+	if qt.ExpiresAt == nil {
+		return time.Duration(0)
+	}
+
+	// A zero expiration would keep the record forever, a record that expired in the meantime
+	// expires right away
+	untilExpiry := time.Until(*qt.ExpiresAt)
+	if untilExpiry < time.Millisecond {
+		untilExpiry = time.Millisecond
+	}
+
+	return untilExpiry
+}
+
+func categorySetKey(category string) string {
+	return REDIS_CATEGORY_SET_KEY_PREFIX + category
+}
+
+// Keys used by insertScript to store and index a record
+func insertKeys(questionID string, category string) []string {
+	return []string{questionID, REDIS_QUESTIONS_SET_KEY, categorySetKey(category)}
+}
+
+// Errors replied by a server that is loading its dataset or lost its master, and the error
+// of the client running out of pooled connections
+const (
+	REDIS_LOADING_ERROR_PREFIX    string = "LOADING"
+	REDIS_MASTERDOWN_ERROR_PREFIX string = "MASTERDOWN"
+	REDIS_POOL_TIMEOUT_ERROR      string = "redis: connection pool timeout"
+)
+
+// Give a kind to an error returned by the redis client, the client errors are only told apart by
+// their text
+func dbError(redisErr error) error {
+	if errors.Is(redisErr, redis.ErrClosed) ||
+		strings.HasPrefix(redisErr.Error(), REDIS_LOADING_ERROR_PREFIX) ||
+		strings.HasPrefix(redisErr.Error(), REDIS_MASTERDOWN_ERROR_PREFIX) {
+		return messages.UnavailableError(redisErr)
+	}
+
+	if redisErr.Error() == REDIS_POOL_TIMEOUT_ERROR {
+		return messages.TimeoutError(redisErr)
+	}
+
+	return messages.ClassifyError(redisErr)
+}
+
+// Add the commands indexing a record to the pipeline
+func indexRecord(ctx context.Context, pipe redis.Pipeliner, questionID string, category string) {
+	pipe.ZAdd(ctx, REDIS_QUESTIONS_SET_KEY, &redis.Z{Member: questionID})
+	pipe.ZAdd(ctx, categorySetKey(category), &redis.Z{Member: questionID})
+}
+
 const (
 	REDIS_GET_CONFIG_ERROR      string = "Getting config error...: "
 	REDIS_GET_CONFIG_DATA_ERROR string = "Getting config data error...: "
@@ -25,18 +189,58 @@ const (
 	REDIS_MARSHAL_ERROR         string = "Marshaling error...: "
 	REDIS_UNMARSHAL_ERROR       string = "Unmarshaling error...: "
 	REDIS_INSERT_ERROR          string = "Insert error...: "
+	REDIS_RECORD_EXISTS_ERROR   string = "Record already exists...: "
 	REDIS_ITEM_NOT_FOUND_ERROR  string = "Item not found...: "
 	REDIS_GET_ERROR             string = "Get error...: "
-	REDIS_UPDATE_ERROR          string = "Update error..."
+	REDIS_CONSUME_ERROR         string = "Consume error...: "
+	REDIS_LIST_ERROR            string = "List error...: "
+	REDIS_DRAW_ERROR            string = "Draw error...: "
+	REDIS_LEASE_ERROR           string = "Lease error...: "
+	REDIS_LEASE_NOT_HELD_ERROR  string = "Lease not held...: "
+	REDIS_INDEX_ERROR           string = "Index error...: "
+	REDIS_UPDATE_ERROR          string = "Update error...: "
 	REDIS_DELETE_ERROR          string = "Delete error...: "
 	REDIS_RESULTS_ERROR         string = "Results error...: "
 	REDIS_ROWS_AFFECTED_ERROR   string = "Rows affected error...: "
 	REDIS_PING_ERROR            string = "Error pinging in-memory cache server...: "
+	REDIS_CLOSE_ERROR           string = "Error closing in-memory cache client...: "
 	REDIS_CONVERSION_ERROR      string = "Conversion error...: "
+	REDIS_WRITE_CONFLICT_ERROR  string = "Record kept being written by other clients..."
 )
 
 var redisModel *dbModel
 
+// Connection pool stats of the redis client exposed in the metrics, the counters only increase
+var poolMetrics = []struct {
+	name    string
+	help    string
+	counter bool
+	value   func(poolStats *redis.PoolStats) uint32
+}{
+	{"redis_pool_hits_total", "Times a free connection was found in the redis pool.", true, func(poolStats *redis.PoolStats) uint32 { return poolStats.Hits }},
+	{"redis_pool_misses_total", "Times a free connection was not found in the redis pool.", true, func(poolStats *redis.PoolStats) uint32 { return poolStats.Misses }},
+	{"redis_pool_timeouts_total", "Times a wait for a redis pool connection timed out.", true, func(poolStats *redis.PoolStats) uint32 { return poolStats.Timeouts }},
+	{"redis_pool_total_conns", "Connections in the redis pool.", false, func(poolStats *redis.PoolStats) uint32 { return poolStats.TotalConns }},
+	{"redis_pool_idle_conns", "Idle connections in the redis pool.", false, func(poolStats *redis.PoolStats) uint32 { return poolStats.IdleConns }},
+	{"redis_pool_stale_conns", "Stale connections removed from the redis pool.", false, func(poolStats *redis.PoolStats) uint32 { return poolStats.StaleConns }},
+}
+
+// Register the pool stats of the redis client
+func registerPoolMetrics(memCache *redis.Client) {
+	for _, poolMetric := range poolMetrics {
+		value := poolMetric.value
+		metricFunc := func() float64 {
+			return float64(value(memCache.PoolStats()))
+		}
+
+		if poolMetric.counter {
+			metrics.Register(prometheus.NewCounterFunc(prometheus.CounterOpts{Namespace: metrics.NAMESPACE, Name: poolMetric.name, Help: poolMetric.help}, metricFunc))
+		} else {
+			metrics.Register(prometheus.NewGaugeFunc(prometheus.GaugeOpts{Namespace: metrics.NAMESPACE, Name: poolMetric.name, Help: poolMetric.help}, metricFunc))
+		}
+	}
+}
+
 type dbModel struct {
 	cfgData  *config.ConfigData
 	memCache *redis.Client
@@ -46,96 +250,504 @@ func (dbm *dbModel) Open(sqlDriverName string) (*sql.DB, error) {
 	return nil, errors.New(REDIS_DB_NAME_MSG + REDIS_OPEN_ERROR)
 }
 
-// Ping database server, since this is local to the server make sure the object for storing data is created
-func (dbm *dbModel) Ping() error {
-	ctx := context.Background()
+// Close the connections of the redis client
+func (dbm *dbModel) Close() error {
+	log.Print("Closing redis client")
+
+	closeErr := dbm.memCache.Close()
+	if closeErr != nil {
+		log.Print(REDIS_DB_NAME_MSG+REDIS_CLOSE_ERROR, closeErr)
+		return closeErr
+	}
+
+	return nil
+}
 
+// Ping database server, since this is local to the server make sure the object for storing data is created
+func (dbm *dbModel) Ping(ctx context.Context) error {
 	statusCmd := dbm.memCache.Ping(ctx)
 	pingErr := statusCmd.Err()
 	if pingErr != nil {
 		log.Print(REDIS_DB_NAME_MSG+REDIS_PING_ERROR, pingErr)
-		return pingErr
+		return messages.UnavailableError(pingErr)
 	}
 
 	return nil
 }
 
 // Insert a single record into table
-func (dbm *dbModel) Insert(qRequest messages.QuestionRequest) (int64, error) {
-	ctx := context.Background()
-
-	var qt messages.QuestionTable
-	qt.Question = qRequest.Question
-	qt.Category = qRequest.Category
-	qt.Answer = qRequest.Answer
+func (dbm *dbModel) Insert(ctx context.Context, qRequest messages.QuestionRequest) (int64, error) {
+	qt := messages.NewQuestionTable(qRequest)
 
 	byteStream, marshalErr := json.Marshal(qt)
 	if marshalErr != nil {
 		log.Print(REDIS_DB_NAME_MSG+REDIS_MARSHAL_ERROR, marshalErr)
-		return messages.RESULTS_DEFAULT, marshalErr
+		return messages.RESULTS_DEFAULT, dbError(marshalErr)
 	}
 
 	log.Print("Adding a new record to map, ID: ", qRequest.QuestionID)
-	setErr := dbm.memCache.Set(ctx, qRequest.QuestionID, byteStream, time.Duration(0)).Err()
-	if setErr != nil {
-		log.Print(REDIS_DB_NAME_MSG+REDIS_INSERT_ERROR, setErr)
-		return messages.RESULTS_DEFAULT, setErr
+	keys := insertKeys(qRequest.QuestionID, qRequest.Category)
+	inserted, insertErr := insertScript.Run(ctx, dbm.memCache, keys, byteStream, expiration(qt).Milliseconds()).Int64()
+	if insertErr != nil {
+		log.Print(REDIS_DB_NAME_MSG+REDIS_INSERT_ERROR, insertErr)
+		return messages.RESULTS_DEFAULT, dbError(insertErr)
 	}
 
-	return messages.RESULTS_DEFAULT, nil
+	if inserted == 0 {
+		log.Print(REDIS_DB_NAME_MSG+REDIS_RECORD_EXISTS_ERROR, qRequest.QuestionID)
+		return messages.RESULTS_DEFAULT, messages.ErrRecordExists
+	}
+
+	return RECORD_AFFECTED, nil
+}
+
+// Upsert stores the record, overwriting the stored record with the same question ID
+func (dbm *dbModel) Upsert(ctx context.Context, qRequest messages.QuestionRequest) (int64, error) {
+	log.Print("Storing record in the map, ID: ", qRequest.QuestionID)
+
+	upsertErr := dbm.watch(ctx, qRequest.QuestionID, func(tx *redis.Tx) error {
+		storedQt, getErr := getWatched(ctx, tx, qRequest.QuestionID)
+		if getErr != nil && !errors.Is(getErr, messages.ErrNotFound) {
+			return getErr
+		}
+
+		qt := messages.NewQuestionTable(qRequest)
+		qt.Version = storedQt.Version + 1
+
+		byteStream, marshalErr := json.Marshal(qt)
+		if marshalErr != nil {
+			return marshalErr
+		}
+
+		_, execErr := tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Set(ctx, qRequest.QuestionID, byteStream, expiration(qt))
+			indexRecord(ctx, pipe, qRequest.QuestionID, qRequest.Category)
+			return nil
+		})
+
+		return execErr
+	})
+	if upsertErr != nil {
+		log.Print(REDIS_DB_NAME_MSG+REDIS_INSERT_ERROR, upsertErr)
+		return messages.RESULTS_DEFAULT, dbError(upsertErr)
+	}
+
+	return RECORD_AFFECTED, nil
+}
+
+// Insert several records into table, the SET commands are sent in pipelines
+func (dbm *dbModel) InsertBatch(ctx context.Context, qRequests []messages.QuestionRequest) ([]error, error) {
+	log.Print("Adding new records to map, count: ", len(qRequests))
+
+	// Scripts are run by their SHA in the pipeline, the script must be loaded first
+	loadErr := insertScript.Load(ctx, dbm.memCache).Err()
+	if loadErr != nil {
+		log.Print(REDIS_DB_NAME_MSG+REDIS_INSERT_ERROR, loadErr)
+		return nil, dbError(loadErr)
+	}
+
+	itemErrs := make([]error, len(qRequests))
+	for start := 0; start < len(qRequests); start += REDIS_BATCH_CHUNK_SIZE {
+		end := start + REDIS_BATCH_CHUNK_SIZE
+		if end > len(qRequests) {
+			end = len(qRequests)
+		}
+
+		pipe := dbm.memCache.Pipeline()
+		insertCmds := make(map[int]*redis.Cmd)
+		for idx := start; idx < end; idx++ {
+			qt := messages.NewQuestionTable(qRequests[idx])
+			byteStream, marshalErr := json.Marshal(qt)
+			if marshalErr != nil {
+				log.Print(REDIS_DB_NAME_MSG+REDIS_MARSHAL_ERROR, marshalErr)
+				itemErrs[idx] = marshalErr
+				continue
+			}
+
+			keys := insertKeys(qRequests[idx].QuestionID, qRequests[idx].Category)
+			insertCmds[idx] = insertScript.EvalSha(ctx, pipe, keys, byteStream, expiration(qt).Milliseconds())
+		}
+
+		// The error returned by Exec is the first failed command, each command keeps its own error
+		_, execErr := pipe.Exec(ctx)
+		if execErr != nil {
+			log.Print(REDIS_DB_NAME_MSG+REDIS_INSERT_ERROR, execErr)
+		}
+
+		for idx, insertCmd := range insertCmds {
+			inserted, insertErr := insertCmd.Int64()
+			if insertErr != nil {
+				itemErrs[idx] = dbError(insertErr)
+			} else if inserted == 0 {
+				itemErrs[idx] = messages.ErrRecordExists
+			}
+		}
+	}
+
+	return itemErrs, nil
 }
 
 // Get a single record from table
-func (dbm *dbModel) Get(questionID string) (messages.QuestionTable, error) {
+func (dbm *dbModel) Get(ctx context.Context, questionID string) (messages.QuestionTable, error) {
 	log.Print("Getting record from the map, with ID: ", questionID)
 
 	var qt messages.QuestionTable
-	ctx := context.Background()
 	getResult, getErr := dbm.memCache.Get(ctx, questionID).Result()
 	if getErr == redis.Nil {
 		log.Print(REDIS_DB_NAME_MSG + REDIS_ITEM_NOT_FOUND_ERROR)
-		return messages.QuestionTable{}, nil
+		return messages.QuestionTable{}, messages.ErrNotFound
 	} else if getErr != nil {
 		log.Print(REDIS_DB_NAME_MSG+REDIS_GET_ERROR, getErr)
-		return messages.QuestionTable{}, getErr
+		return messages.QuestionTable{}, dbError(getErr)
 	} else {
 		unmarshalErr := json.Unmarshal([]byte(getResult), &qt)
 		if unmarshalErr != nil {
 			log.Print(REDIS_DB_NAME_MSG+REDIS_UNMARSHAL_ERROR, unmarshalErr)
-			return messages.QuestionTable{}, unmarshalErr
+			return messages.QuestionTable{}, dbError(unmarshalErr)
 		}
 	}
 
 	return qt, nil
 }
 
-// Update a single record in table
-func (dbm *dbModel) Update(qRequest messages.QuestionRequest) (int64, error) {
-	log.Println("Updating record in the map")
+// Consume a single record from table, consumeScript returns and deletes the record as one operation
+func (dbm *dbModel) Consume(ctx context.Context, questionID string) (messages.QuestionTable, error) {
+	log.Print("Consuming record from the map, with ID: ", questionID)
+
+	var qt messages.QuestionTable
+	getResult, getErr := consumeScript.Run(ctx, dbm.memCache, []string{questionID, leaseKey(questionID)}).Text()
+	if getErr == redis.Nil {
+		log.Print(REDIS_DB_NAME_MSG + REDIS_ITEM_NOT_FOUND_ERROR)
+		return messages.QuestionTable{}, messages.ErrNotFound
+	} else if getErr != nil {
+		log.Print(REDIS_DB_NAME_MSG+REDIS_CONSUME_ERROR, getErr)
+		return messages.QuestionTable{}, dbError(getErr)
+	}
+
+	unmarshalErr := json.Unmarshal([]byte(getResult), &qt)
+	if unmarshalErr != nil {
+		log.Print(REDIS_DB_NAME_MSG+REDIS_UNMARSHAL_ERROR, unmarshalErr)
+		return messages.QuestionTable{}, dbError(unmarshalErr)
+	}
+
+	dbm.unindexRecord(ctx, questionID, qt.Category)
+
+	return qt, nil
+}
+
+// Lease a single record from table, the record stays in the map but cannot be consumed or
+// drawn until the lease key expires
+func (dbm *dbModel) Lease(ctx context.Context, questionID string, leaseToken string, leaseExpiresAt time.Time) (messages.QuestionTable, error) {
+	log.Print("Leasing record from the map, with ID: ", questionID)
 
-	ctx := context.Background()
+	leaseDuration := time.Until(leaseExpiresAt).Milliseconds()
+	if leaseDuration < 1 {
+		leaseDuration = 1
+	}
 
 	var qt messages.QuestionTable
-	qt.Question = qRequest.Question
-	qt.Category = qRequest.Category
-	qt.Answer = qRequest.Answer
+	getResult, leaseErr := leaseScript.Run(ctx, dbm.memCache, []string{questionID, leaseKey(questionID)}, leaseToken, strconv.FormatInt(leaseDuration, 10)).Text()
+	if leaseErr == redis.Nil {
+		log.Print("Record not found or checked out, ID: ", questionID)
+		return messages.QuestionTable{}, messages.ErrNotFound
+	} else if leaseErr != nil {
+		log.Print(REDIS_DB_NAME_MSG+REDIS_LEASE_ERROR, leaseErr)
+		return messages.QuestionTable{}, dbError(leaseErr)
+	}
 
-	dbm.memCache.Set(ctx, qRequest.QuestionID, qt, 0)
+	unmarshalErr := json.Unmarshal([]byte(getResult), &qt)
+	if unmarshalErr != nil {
+		log.Print(REDIS_DB_NAME_MSG+REDIS_UNMARSHAL_ERROR, unmarshalErr)
+		return messages.QuestionTable{}, dbError(unmarshalErr)
+	}
 
-	return messages.RESULTS_DEFAULT, nil
+	return qt, nil
+}
+
+// Confirm the lease of a single record, the record is returned and deleted when the lease
+// token matches an unexpired lease
+func (dbm *dbModel) Confirm(ctx context.Context, questionID string, leaseToken string) (messages.QuestionTable, error) {
+	log.Print("Confirming lease of record, with ID: ", questionID)
+
+	var qt messages.QuestionTable
+	getResult, confirmErr := confirmScript.Run(ctx, dbm.memCache, []string{questionID, leaseKey(questionID)}, leaseToken).Text()
+	if confirmErr == redis.Nil {
+		log.Print(REDIS_DB_NAME_MSG+REDIS_LEASE_NOT_HELD_ERROR, questionID)
+		return messages.QuestionTable{}, messages.ErrNotFound
+	} else if confirmErr != nil {
+		log.Print(REDIS_DB_NAME_MSG+REDIS_LEASE_ERROR, confirmErr)
+		return messages.QuestionTable{}, dbError(confirmErr)
+	}
+
+	unmarshalErr := json.Unmarshal([]byte(getResult), &qt)
+	if unmarshalErr != nil {
+		log.Print(REDIS_DB_NAME_MSG+REDIS_UNMARSHAL_ERROR, unmarshalErr)
+		return messages.QuestionTable{}, dbError(unmarshalErr)
+	}
+
+	dbm.unindexRecord(ctx, questionID, qt.Category)
+
+	return qt, nil
+}
+
+// Remove a consumed record from the index sets, a failure leaves a stale member which Draw removes
+func (dbm *dbModel) unindexRecord(ctx context.Context, questionID string, category string) {
+	_, sremErr := dbm.memCache.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZRem(ctx, REDIS_QUESTIONS_SET_KEY, questionID)
+		pipe.ZRem(ctx, categorySetKey(category), questionID)
+		return nil
+	})
+	if sremErr != nil {
+		log.Print(REDIS_DB_NAME_MSG+REDIS_INDEX_ERROR, sremErr)
+	}
+}
+
+// List records in question ID order. A page is read from the index sorted set of the category
+// with ZRANGEBYLEX from the cursor, members whose record is gone or moved to another category are
+// skipped and the next members are read in their place.
+func (dbm *dbModel) List(ctx context.Context, lRequest messages.ListRequest) ([]messages.QuestionRecord, error) {
+	log.Print("Listing records from the map, after ID: ", lRequest.After)
+
+	setKey := REDIS_QUESTIONS_SET_KEY
+	if len(lRequest.Category) > 0 {
+		setKey = categorySetKey(lRequest.Category)
+	}
+
+	minID := "-"
+	if len(lRequest.After) > 0 {
+		minID = "(" + lRequest.After
+	}
+
+	qRecords := make([]messages.QuestionRecord, 0)
+	for len(qRecords) < lRequest.Limit {
+		rangeBy := &redis.ZRangeBy{Min: minID, Max: "+", Count: int64(lRequest.Limit - len(qRecords))}
+		questionIDs, rangeErr := dbm.memCache.ZRangeByLex(ctx, setKey, rangeBy).Result()
+		if rangeErr != nil {
+			log.Print(REDIS_DB_NAME_MSG+REDIS_LIST_ERROR, rangeErr)
+			return nil, dbError(rangeErr)
+		}
+
+		if len(questionIDs) == 0 {
+			break
+		}
+
+		minID = "(" + questionIDs[len(questionIDs)-1]
+
+		values, mgetErr := dbm.memCache.MGet(ctx, questionIDs...).Result()
+		if mgetErr != nil {
+			log.Print(REDIS_DB_NAME_MSG+REDIS_LIST_ERROR, mgetErr)
+			return nil, dbError(mgetErr)
+		}
+
+		for idx, value := range values {
+			// The record expired or was removed
+			strValue, ok := value.(string)
+			if !ok {
+				continue
+			}
+
+			var qt messages.QuestionTable
+			unmarshalErr := json.Unmarshal([]byte(strValue), &qt)
+			if unmarshalErr != nil {
+				log.Print(REDIS_DB_NAME_MSG+REDIS_UNMARSHAL_ERROR, unmarshalErr)
+				continue
+			}
+
+			if len(lRequest.Category) > 0 && qt.Category != lRequest.Category {
+				continue
+			}
+
+			qRecords = append(qRecords, messages.QuestionRecord{QuestionID: questionIDs[idx], QuestionTable: qt})
+		}
+	}
+
+	return qRecords, nil
+}
+
+// Draw a random record and consume it. Members are sampled from the index set of the category,
+// the first sampled record that is not excluded, not checked out and still belongs to the
+// category is consumed by drawScript, so a record is only drawn once.
+func (dbm *dbModel) Draw(ctx context.Context, dRequest messages.DrawRequest) (messages.QuestionRecord, error) {
+	log.Print("Drawing a record from the map, category: ", dRequest.Category)
+
+	setKey := REDIS_QUESTIONS_SET_KEY
+	if len(dRequest.Category) > 0 {
+		setKey = categorySetKey(dRequest.Category)
+	}
+
+	excluded := make(map[string]bool)
+	for _, questionID := range dRequest.Exclude {
+		excluded[questionID] = true
+	}
+
+	sampleSize := len(dRequest.Exclude) + REDIS_DRAW_SAMPLE_SIZE
+	for attempt := 0; attempt < REDIS_DRAW_ATTEMPTS; attempt++ {
+		questionIDs, sampleErr := dbm.memCache.ZRandMember(ctx, setKey, sampleSize, false).Result()
+		if sampleErr != nil {
+			log.Print(REDIS_DB_NAME_MSG+REDIS_DRAW_ERROR, sampleErr)
+			return messages.QuestionRecord{}, dbError(sampleErr)
+		}
+
+		for _, questionID := range questionIDs {
+			if excluded[questionID] {
+				continue
+			}
+
+			result, drawErr := drawScript.Run(ctx, dbm.memCache, []string{questionID, leaseKey(questionID)}, dRequest.Category).Result()
+			if drawErr == redis.Nil {
+				// The record is gone or belongs to another category
+				dbm.memCache.ZRem(ctx, setKey, questionID)
+				continue
+			} else if drawErr != nil {
+				log.Print(REDIS_DB_NAME_MSG+REDIS_DRAW_ERROR, drawErr)
+				return messages.QuestionRecord{}, dbError(drawErr)
+			}
+
+			// The record is checked out
+			value, ok := result.(string)
+			if !ok {
+				continue
+			}
+
+			var qt messages.QuestionTable
+			unmarshalErr := json.Unmarshal([]byte(value), &qt)
+			if unmarshalErr != nil {
+				log.Print(REDIS_DB_NAME_MSG+REDIS_UNMARSHAL_ERROR, unmarshalErr)
+				return messages.QuestionRecord{}, dbError(unmarshalErr)
+			}
+
+			dbm.unindexRecord(ctx, questionID, qt.Category)
+
+			return messages.QuestionRecord{QuestionID: questionID, QuestionTable: qt}, nil
+		}
+
+		// The whole set was sampled
+		if len(questionIDs) < sampleSize {
+			break
+		}
+	}
+
+	return messages.QuestionRecord{}, messages.ErrNotFound
 }
 
-// Delete a single record from table
-func (dbm *dbModel) Delete(questionID string) (int64, error) {
+// Run txFunc in a transaction watching the record key. The transaction is retried when the
+// record is written by another client before the transaction is committed, a record still
+// written by others after the last attempt is a conflict.
+func (dbm *dbModel) watch(ctx context.Context, questionID string, txFunc func(tx *redis.Tx) error) error {
+	var watchErr error
+	for attempt := 0; attempt < REDIS_WATCH_ATTEMPTS; attempt++ {
+		watchErr = dbm.memCache.Watch(ctx, txFunc, questionID)
+		if watchErr != redis.TxFailedErr {
+			return watchErr
+		}
+	}
+
+	return messages.NewError(messages.ErrConflict, messages.CONFLICT_CODE, REDIS_WRITE_CONFLICT_ERROR, watchErr)
+}
+
+// Read the record watched by the transaction, a missing record returns ErrNotFound
+func getWatched(ctx context.Context, tx *redis.Tx, questionID string) (messages.QuestionTable, error) {
+	var qt messages.QuestionTable
+	getResult, getErr := tx.Get(ctx, questionID).Result()
+	if getErr == redis.Nil {
+		return messages.QuestionTable{}, messages.ErrNotFound
+	} else if getErr != nil {
+		return messages.QuestionTable{}, getErr
+	}
+
+	unmarshalErr := json.Unmarshal([]byte(getResult), &qt)
+	if unmarshalErr != nil {
+		return messages.QuestionTable{}, unmarshalErr
+	}
+
+	return qt, nil
+}
+
+// Update a single record in table. The record is read and written in a WATCH/MULTI transaction,
+// so the version is checked and incremented without another client writing in between.
+func (dbm *dbModel) Update(ctx context.Context, qRequest messages.QuestionRequest) (int64, int64, error) {
+	log.Println("Updating record in the map")
+
+	rowsAffected := messages.RESULTS_DEFAULT
+	version := messages.RESULTS_DEFAULT
+	updateErr := dbm.watch(ctx, qRequest.QuestionID, func(tx *redis.Tx) error {
+		storedQt, getErr := getWatched(ctx, tx, qRequest.QuestionID)
+		if getErr != nil {
+			return getErr
+		}
+
+		if qRequest.Version > 0 && storedQt.Version != qRequest.Version {
+			return messages.ErrVersionMismatch
+		}
+
+		qt := messages.NewQuestionTable(qRequest)
+		qt.Version = storedQt.Version + 1
+
+		byteStream, marshalErr := json.Marshal(qt)
+		if marshalErr != nil {
+			return marshalErr
+		}
+
+		// The category may have changed, the member of the previous category set becomes stale
+		_, execErr := tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Set(ctx, qRequest.QuestionID, byteStream, expiration(qt))
+			indexRecord(ctx, pipe, qRequest.QuestionID, qRequest.Category)
+			return nil
+		})
+		if execErr == nil {
+			rowsAffected = RECORD_AFFECTED
+			version = qt.Version
+		}
+
+		return execErr
+	})
+	if updateErr != nil {
+		log.Print(REDIS_DB_NAME_MSG+REDIS_UPDATE_ERROR, updateErr)
+		return messages.RESULTS_DEFAULT, messages.RESULTS_DEFAULT, dbError(updateErr)
+	}
+
+	return rowsAffected, version, nil
+}
+
+// Delete a single record from table, a version other than 0 must match the stored version. The
+// record, its lease and its index set members are removed in a WATCH/MULTI transaction.
+func (dbm *dbModel) Delete(ctx context.Context, questionID string, version int64) (int64, error) {
 	log.Print("Deleting record with ID: ", questionID)
 
-	// Delete the record from map
-	ctx := context.Background()
-	delErr := dbm.memCache.Del(ctx, questionID).Err()
+	rowsAffected := messages.RESULTS_DEFAULT
+	delErr := dbm.watch(ctx, questionID, func(tx *redis.Tx) error {
+		qt, getErr := getWatched(ctx, tx, questionID)
+		if getErr != nil {
+			return getErr
+		}
+
+		if version > 0 && qt.Version != version {
+			return messages.ErrVersionMismatch
+		}
+
+		// A lease left behind would check out a new record stored with the same question ID
+		_, execErr := tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Del(ctx, questionID, leaseKey(questionID))
+			pipe.ZRem(ctx, REDIS_QUESTIONS_SET_KEY, questionID)
+			pipe.ZRem(ctx, categorySetKey(qt.Category), questionID)
+			return nil
+		})
+		if execErr == nil {
+			rowsAffected = RECORD_AFFECTED
+		}
+
+		return execErr
+	})
 	if delErr != nil {
 		log.Print(REDIS_DB_NAME_MSG+REDIS_DELETE_ERROR, delErr)
+		return messages.RESULTS_DEFAULT, dbError(delErr)
 	}
 
+	return rowsAffected, nil
+}
+
+// Reap expired records, Redis removes expired keys by itself
+func (dbm *dbModel) Reap(ctx context.Context) (int64, error) {
 	return messages.RESULTS_DEFAULT, nil
 }
 
@@ -169,6 +781,7 @@ func GetRedisModel(cfgData *config.ConfigData) *dbModel {
 
 	// Create go-redis in-memory cache
 	redisModel.memCache = redis.NewClient(redisOptions)
+	registerPoolMetrics(redisModel.memCache)
 
 	return redisModel
 }
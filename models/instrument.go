@@ -0,0 +1,159 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"github.com/sflewis2970/datastore-service/metrics"
+	"github.com/sflewis2970/datastore-service/models/messages"
+	"github.com/sflewis2970/datastore-service/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Datastore driver recording the latency and the errors of every operation of the driver it wraps,
+// and tracing each operation with a span
+type instrumentedDBModel struct {
+	messages.IDBModel
+	driverName string
+}
+
+// Wrap the driver with the operation metrics and spans
+func instrument(driverName string, dbModel messages.IDBModel) messages.IDBModel {
+	return instrumentedDBModel{IDBModel: dbModel, driverName: driverName}
+}
+
+// Start the span of an operation, named after the driver and the operation
+func (dbm instrumentedDBModel) start(ctx context.Context, operation string, attrs ...attribute.KeyValue) (context.Context, trace.Span, time.Time) {
+	attrs = append(attrs, tracing.DriverKey.String(dbm.driverName))
+	ctx, span := tracing.Start(ctx, dbm.driverName+"."+operation, attrs...)
+
+	return ctx, span, time.Now()
+}
+
+// Record an operation started at start and end its span, a failed operation is counted by the code
+// of its error
+func (dbm instrumentedDBModel) observe(span trace.Span, operation string, start time.Time, opErr error) {
+	code := ""
+	if opErr != nil {
+		code = messages.ErrorCode(opErr)
+		span.SetAttributes(tracing.ErrorCodeKey.String(code))
+	}
+
+	metrics.ObserveOperation(dbm.driverName, operation, start, code)
+	tracing.End(span, opErr)
+}
+
+func (dbm instrumentedDBModel) Ping(ctx context.Context) error {
+	ctx, span, start := dbm.start(ctx, "ping")
+	pingErr := dbm.IDBModel.Ping(ctx)
+	dbm.observe(span, "ping", start, pingErr)
+
+	return pingErr
+}
+
+func (dbm instrumentedDBModel) Insert(ctx context.Context, qRequest messages.QuestionRequest) (int64, error) {
+	ctx, span, start := dbm.start(ctx, "insert", tracing.QuestionIDKey.String(qRequest.QuestionID))
+	rowsAffected, insertErr := dbm.IDBModel.Insert(ctx, qRequest)
+	span.SetAttributes(tracing.RowsAffectedKey.Int64(rowsAffected))
+	dbm.observe(span, "insert", start, insertErr)
+
+	return rowsAffected, insertErr
+}
+
+func (dbm instrumentedDBModel) Upsert(ctx context.Context, qRequest messages.QuestionRequest) (int64, error) {
+	ctx, span, start := dbm.start(ctx, "upsert", tracing.QuestionIDKey.String(qRequest.QuestionID))
+	rowsAffected, upsertErr := dbm.IDBModel.Upsert(ctx, qRequest)
+	span.SetAttributes(tracing.RowsAffectedKey.Int64(rowsAffected))
+	dbm.observe(span, "upsert", start, upsertErr)
+
+	return rowsAffected, upsertErr
+}
+
+// The errors of the single records of a batch are not counted, only the failure of the batch
+func (dbm instrumentedDBModel) InsertBatch(ctx context.Context, qRequests []messages.QuestionRequest) ([]error, error) {
+	ctx, span, start := dbm.start(ctx, "insert_batch", tracing.RecordsKey.Int(len(qRequests)))
+	itemErrs, insertErr := dbm.IDBModel.InsertBatch(ctx, qRequests)
+	dbm.observe(span, "insert_batch", start, insertErr)
+
+	return itemErrs, insertErr
+}
+
+func (dbm instrumentedDBModel) Get(ctx context.Context, questionID string) (messages.QuestionTable, error) {
+	ctx, span, start := dbm.start(ctx, "get", tracing.QuestionIDKey.String(questionID))
+	qt, getErr := dbm.IDBModel.Get(ctx, questionID)
+	dbm.observe(span, "get", start, getErr)
+
+	return qt, getErr
+}
+
+func (dbm instrumentedDBModel) Consume(ctx context.Context, questionID string) (messages.QuestionTable, error) {
+	ctx, span, start := dbm.start(ctx, "consume", tracing.QuestionIDKey.String(questionID))
+	qt, consumeErr := dbm.IDBModel.Consume(ctx, questionID)
+	dbm.observe(span, "consume", start, consumeErr)
+
+	return qt, consumeErr
+}
+
+func (dbm instrumentedDBModel) Lease(ctx context.Context, questionID string, leaseToken string, leaseExpiresAt time.Time) (messages.QuestionTable, error) {
+	ctx, span, start := dbm.start(ctx, "lease", tracing.QuestionIDKey.String(questionID))
+	qt, leaseErr := dbm.IDBModel.Lease(ctx, questionID, leaseToken, leaseExpiresAt)
+	dbm.observe(span, "lease", start, leaseErr)
+
+	return qt, leaseErr
+}
+
+func (dbm instrumentedDBModel) Confirm(ctx context.Context, questionID string, leaseToken string) (messages.QuestionTable, error) {
+	ctx, span, start := dbm.start(ctx, "confirm", tracing.QuestionIDKey.String(questionID))
+	qt, confirmErr := dbm.IDBModel.Confirm(ctx, questionID, leaseToken)
+	dbm.observe(span, "confirm", start, confirmErr)
+
+	return qt, confirmErr
+}
+
+func (dbm instrumentedDBModel) List(ctx context.Context, lRequest messages.ListRequest) ([]messages.QuestionRecord, error) {
+	ctx, span, start := dbm.start(ctx, "list")
+	qRecords, listErr := dbm.IDBModel.List(ctx, lRequest)
+	span.SetAttributes(tracing.RecordsKey.Int(len(qRecords)))
+	dbm.observe(span, "list", start, listErr)
+
+	return qRecords, listErr
+}
+
+func (dbm instrumentedDBModel) Draw(ctx context.Context, dRequest messages.DrawRequest) (messages.QuestionRecord, error) {
+	ctx, span, start := dbm.start(ctx, "draw")
+	qRecord, drawErr := dbm.IDBModel.Draw(ctx, dRequest)
+	if drawErr == nil {
+		span.SetAttributes(tracing.QuestionIDKey.String(qRecord.QuestionID))
+	}
+	dbm.observe(span, "draw", start, drawErr)
+
+	return qRecord, drawErr
+}
+
+func (dbm instrumentedDBModel) Update(ctx context.Context, qRequest messages.QuestionRequest) (int64, int64, error) {
+	ctx, span, start := dbm.start(ctx, "update", tracing.QuestionIDKey.String(qRequest.QuestionID))
+	rowsAffected, version, updateErr := dbm.IDBModel.Update(ctx, qRequest)
+	span.SetAttributes(tracing.RowsAffectedKey.Int64(rowsAffected))
+	dbm.observe(span, "update", start, updateErr)
+
+	return rowsAffected, version, updateErr
+}
+
+func (dbm instrumentedDBModel) Delete(ctx context.Context, questionID string, version int64) (int64, error) {
+	ctx, span, start := dbm.start(ctx, "delete", tracing.QuestionIDKey.String(questionID))
+	rowsAffected, delErr := dbm.IDBModel.Delete(ctx, questionID, version)
+	span.SetAttributes(tracing.RowsAffectedKey.Int64(rowsAffected))
+	dbm.observe(span, "delete", start, delErr)
+
+	return rowsAffected, delErr
+}
+
+func (dbm instrumentedDBModel) Reap(ctx context.Context) (int64, error) {
+	ctx, span, start := dbm.start(ctx, "reap")
+	rowsAffected, reapErr := dbm.IDBModel.Reap(ctx)
+	span.SetAttributes(tracing.RowsAffectedKey.Int64(rowsAffected))
+	dbm.observe(span, "reap", start, reapErr)
+
+	return rowsAffected, reapErr
+}
@@ -0,0 +1,164 @@
+package matcher
+
+import (
+	"log"
+	"strings"
+	"unicode"
+
+	"github.com/sflewis2970/datastore-service/config"
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Matcher modes
+const (
+	EXACT_MODE      string = "exact"
+	NORMALIZED_MODE string = "normalized"
+)
+
+// Punctuation kept by Normalize along with the symbols, so answers like "C#" and "C" are told apart
+const KEPT_PUNCTUATION string = "#&@%*/"
+
+// Answers shorter than the default minimum length must match exactly after normalization,
+// otherwise a single typo would be enough to accept "3" for "2".
+const DEFAULT_MIN_FUZZY_LENGTH int = 4
+
+// IMatcher decides whether a submitted answer matches one of the accepted answers
+type IMatcher interface {
+	Match(submitted string, accepted []string) bool
+}
+
+// ExactMatcher only accepts answers that are identical to an accepted answer
+type ExactMatcher struct{}
+
+func (em ExactMatcher) Match(submitted string, accepted []string) bool {
+	for _, answer := range accepted {
+		if submitted == answer {
+			return true
+		}
+	}
+
+	return false
+}
+
+// NormalizedMatcher compares answers after normalizing case, whitespace, punctuation and
+// diacritics. Answers with at least MinFuzzyLength runes also match when they are within
+// MaxDistance edits of an accepted answer. Accepted answers made only of punctuation are
+// compared as given, once trimmed.
+type NormalizedMatcher struct {
+	MaxDistance    int
+	MinFuzzyLength int
+}
+
+func (nm NormalizedMatcher) Match(submitted string, accepted []string) bool {
+	normSubmitted := Normalize(submitted)
+	for _, answer := range accepted {
+		normAnswer := Normalize(answer)
+		if len(normAnswer) == 0 {
+			trimmedAnswer := strings.TrimSpace(answer)
+			if len(trimmedAnswer) > 0 && strings.TrimSpace(submitted) == trimmedAnswer {
+				return true
+			}
+
+			continue
+		}
+
+		if normSubmitted == normAnswer {
+			return true
+		}
+
+		if nm.MaxDistance > 0 && len([]rune(normAnswer)) >= nm.MinFuzzyLength {
+			if Distance(normSubmitted, normAnswer) <= nm.MaxDistance {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// Normalize lower cases the value, folds diacritics ("é" becomes "e"), drops punctuation
+// and collapses whitespace. Symbols ("+") and the kept punctuation ("#") are meaningful in
+// answers like "C++" or "C#" and stay.
+func Normalize(value string) string {
+	foldDiacritics := transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+	folded, _, transformErr := transform.String(foldDiacritics, value)
+	if transformErr != nil {
+		log.Print("Error folding diacritics: ", transformErr)
+		folded = value
+	}
+
+	var builder strings.Builder
+	for _, r := range strings.ToLower(folded) {
+		switch {
+		case unicode.IsSymbol(r) || strings.ContainsRune(KEPT_PUNCTUATION, r):
+			builder.WriteRune(r)
+		case unicode.IsPunct(r):
+			continue
+		case unicode.IsSpace(r):
+			builder.WriteRune(' ')
+		default:
+			builder.WriteRune(r)
+		}
+	}
+
+	return strings.Join(strings.Fields(builder.String()), " ")
+}
+
+// Distance returns the Levenshtein edit distance between two values
+func Distance(a string, b string) int {
+	aRunes := []rune(a)
+	bRunes := []rune(b)
+
+	previous := make([]int, len(bRunes)+1)
+	current := make([]int, len(bRunes)+1)
+	for idx := range previous {
+		previous[idx] = idx
+	}
+
+	for i := 1; i <= len(aRunes); i++ {
+		current[0] = i
+		for j := 1; j <= len(bRunes); j++ {
+			cost := 1
+			if aRunes[i-1] == bRunes[j-1] {
+				cost = 0
+			}
+
+			current[j] = minOf(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+
+		previous, current = current, previous
+	}
+
+	return previous[len(bRunes)]
+}
+
+func minOf(values ...int) int {
+	minValue := values[0]
+	for _, value := range values[1:] {
+		if value < minValue {
+			minValue = value
+		}
+	}
+
+	return minValue
+}
+
+// New creates the matcher selected by the config data
+func New(cfgData *config.ConfigData) IMatcher {
+	switch cfgData.Matcher.Mode {
+	case EXACT_MODE:
+		log.Print("Using exact answer matcher")
+		return ExactMatcher{}
+	default:
+		log.Print("Using normalized answer matcher, max distance: ", cfgData.Matcher.MaxDistance)
+
+		minFuzzyLength := cfgData.Matcher.MinFuzzyLength
+		if minFuzzyLength == 0 {
+			minFuzzyLength = DEFAULT_MIN_FUZZY_LENGTH
+		}
+
+		return NormalizedMatcher{MaxDistance: cfgData.Matcher.MaxDistance, MinFuzzyLength: minFuzzyLength}
+	}
+}
@@ -0,0 +1,99 @@
+package matcher
+
+import (
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	// Test cases
+	testCases := []struct {
+		testName string
+		value    string
+		expected string
+	}{
+		{testName: "Empty value", value: "", expected: ""},
+		{testName: "Case and whitespace", value: "  The   Iliad ", expected: "the iliad"},
+		{testName: "Punctuation", value: "Hello, World!", expected: "hello world"},
+		{testName: "Diacritics", value: "Pándora.", expected: "pandora"},
+		{testName: "Non-latin script", value: "東京", expected: "東京"},
+		{testName: "Symbols", value: "C++", expected: "c++"},
+		{testName: "Kept punctuation", value: "C#", expected: "c#"},
+		{testName: "Kept punctuation between words", value: "AT&T", expected: "at&t"},
+		{testName: "Punctuation only", value: " ?! ", expected: ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			if normalized := Normalize(tc.value); normalized != tc.expected {
+				t.Errorf("Normalize(%q): got %q, expected: %q", tc.value, normalized, tc.expected)
+			}
+		})
+	}
+}
+
+func TestDistance(t *testing.T) {
+	// Test cases
+	testCases := []struct {
+		testName string
+		a        string
+		b        string
+		expected int
+	}{
+		{testName: "Empty values", a: "", b: "", expected: 0},
+		{testName: "One empty value", a: "", b: "abc", expected: 3},
+		{testName: "Identical values", a: "pandora", b: "pandora", expected: 0},
+		{testName: "Deletion", a: "pandora", b: "pandra", expected: 1},
+		{testName: "Substitutions and insertion", a: "kitten", b: "sitting", expected: 3},
+		{testName: "Runes rather than bytes", a: "東京", b: "京都", expected: 2},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			if distance := Distance(tc.a, tc.b); distance != tc.expected {
+				t.Errorf("Distance(%q, %q): got %d, expected: %d", tc.a, tc.b, distance, tc.expected)
+			}
+
+			if distance := Distance(tc.b, tc.a); distance != tc.expected {
+				t.Errorf("Distance(%q, %q): got %d, expected: %d", tc.b, tc.a, distance, tc.expected)
+			}
+		})
+	}
+}
+
+func TestNormalizedMatcher(t *testing.T) {
+	// Test cases
+	testCases := []struct {
+		testName    string
+		maxDistance int
+		submitted   string
+		accepted    []string
+		expected    bool
+	}{
+		{testName: "Same answer", maxDistance: 1, submitted: "Pandora", accepted: []string{"Pandora"}, expected: true},
+		{testName: "Normalized answer", maxDistance: 1, submitted: " pándora. ", accepted: []string{"Pandora"}, expected: true},
+		{testName: "Alternate answer", maxDistance: 1, submitted: "anesidora", accepted: []string{"Pandora", "Anesidora"}, expected: true},
+		{testName: "Within the max distance", maxDistance: 1, submitted: "Pandra", accepted: []string{"Pandora"}, expected: true},
+		{testName: "Over the max distance", maxDistance: 1, submitted: "Pndra", accepted: []string{"Pandora"}, expected: false},
+		{testName: "Fuzzy matching turned off", maxDistance: 0, submitted: "Pandra", accepted: []string{"Pandora"}, expected: false},
+		{testName: "Below the min fuzzy length", maxDistance: 1, submitted: "3", accepted: []string{"2"}, expected: false},
+		{testName: "Empty answer", maxDistance: 1, submitted: "", accepted: []string{"Pandora"}, expected: false},
+		{testName: "Empty accepted answer", maxDistance: 1, submitted: " ", accepted: []string{""}, expected: false},
+		{testName: "Unicode answer", maxDistance: 1, submitted: "Tōkyō", accepted: []string{"Tokyo"}, expected: true},
+		{testName: "Non-latin answer", maxDistance: 1, submitted: "東京", accepted: []string{"東京"}, expected: true},
+		{testName: "Symbols told apart", maxDistance: 1, submitted: "C", accepted: []string{"C++"}, expected: false},
+		{testName: "Kept punctuation told apart", maxDistance: 1, submitted: "C", accepted: []string{"C#"}, expected: false},
+		{testName: "Answer with symbols", maxDistance: 1, submitted: "c#", accepted: []string{"C#"}, expected: true},
+		{testName: "Punctuation only answer", maxDistance: 1, submitted: " ? ", accepted: []string{"?"}, expected: true},
+		{testName: "Wrong punctuation only answer", maxDistance: 1, submitted: "!", accepted: []string{"?"}, expected: false},
+		{testName: "Empty answer to a punctuation only answer", maxDistance: 1, submitted: "", accepted: []string{"?"}, expected: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			nm := NormalizedMatcher{MaxDistance: tc.maxDistance, MinFuzzyLength: DEFAULT_MIN_FUZZY_LENGTH}
+			if matched := nm.Match(tc.submitted, tc.accepted); matched != tc.expected {
+				t.Errorf("Match(%q, %q): got %t, expected: %t", tc.submitted, tc.accepted, matched, tc.expected)
+			}
+		})
+	}
+}
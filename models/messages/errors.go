@@ -0,0 +1,160 @@
+package messages
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"net"
+	"syscall"
+)
+
+// Error kinds. Every error returned by the drivers and the model matches one of them with
+// errors.Is, an error matching none of them is an internal error.
+var (
+	ErrNotFound           = errors.New(NO_RESULTS_RETURNED_MSG)
+	ErrConflict           = errors.New("Conflict...")
+	ErrPreconditionFailed = errors.New("Precondition failed...")
+	ErrInvalid            = errors.New("Invalid request...")
+	ErrUnavailable        = errors.New("Datastore unavailable...")
+	ErrTimeout            = errors.New("Datastore timed out...")
+	ErrCanceled           = errors.New("Request canceled...")
+)
+
+// Machine-readable codes of the error kinds and of the errors needing their own code
+const (
+	NOT_FOUND_CODE        string = "not_found"
+	CONFLICT_CODE         string = "conflict"
+	RECORD_EXISTS_CODE    string = "record_exists"
+	LEASE_NOT_HELD_CODE   string = "lease_not_held"
+	PRECONDITION_CODE     string = "precondition_failed"
+	VERSION_MISMATCH_CODE string = "version_mismatch"
+	INVALID_CODE          string = "invalid"
+	UNAVAILABLE_CODE      string = "unavailable"
+	TIMEOUT_CODE          string = "timeout"
+	CANCELED_CODE         string = "canceled"
+	INTERNAL_CODE         string = "internal"
+)
+
+// Error is an error of one of the error kinds. Message is reported to the client along with
+// Code, the cause holds the details when there are any. Fields holds the field errors of a
+// request that failed validation.
+type Error struct {
+	Kind    error
+	Code    string
+	Message string
+	Cause   error
+	Fields  []FieldError
+}
+
+func (dsErr *Error) Error() string {
+	if dsErr.Cause == nil {
+		return dsErr.Message
+	}
+
+	return dsErr.Message + " " + dsErr.Cause.Error()
+}
+
+// Is reports whether the error is of the target kind, the cause is matched through Unwrap
+func (dsErr *Error) Is(target error) bool {
+	return target == dsErr.Kind
+}
+
+func (dsErr *Error) Unwrap() error {
+	return dsErr.Cause
+}
+
+// NewError returns an error of the kind, the cause may be nil
+func NewError(kind error, code string, message string, cause error) *Error {
+	return &Error{Kind: kind, Code: code, Message: message, Cause: cause}
+}
+
+// UnavailableError returns an ErrUnavailable error caused by err
+func UnavailableError(err error) error {
+	return NewError(ErrUnavailable, UNAVAILABLE_CODE, ErrUnavailable.Error(), err)
+}
+
+// TimeoutError returns an ErrTimeout error caused by err
+func TimeoutError(err error) error {
+	return NewError(ErrTimeout, TIMEOUT_CODE, ErrTimeout.Error(), err)
+}
+
+// CanceledError returns an ErrCanceled error caused by err
+func CanceledError(err error) error {
+	return NewError(ErrCanceled, CANCELED_CODE, ErrCanceled.Error(), err)
+}
+
+// Returned by the drivers when a record with the same question ID is already stored
+var ErrRecordExists = NewError(ErrConflict, RECORD_EXISTS_CODE, RECORD_EXISTS_MSG, nil)
+
+// Returned when an answer confirms a lease that is not held, the record is still stored
+var ErrLeaseNotHeld = NewError(ErrConflict, LEASE_NOT_HELD_CODE, LEASE_NOT_HELD_MSG, nil)
+
+// Returned by the drivers when a conditional write finds the record at another version
+var ErrVersionMismatch = NewError(ErrPreconditionFailed, VERSION_MISMATCH_CODE, VERSION_MISMATCH_MSG, nil)
+
+// Error kinds along with their code, in the order they are matched
+var errorKinds = []struct {
+	kind error
+	code string
+}{
+	{ErrNotFound, NOT_FOUND_CODE},
+	{ErrConflict, CONFLICT_CODE},
+	{ErrPreconditionFailed, PRECONDITION_CODE},
+	{ErrInvalid, INVALID_CODE},
+	{ErrUnavailable, UNAVAILABLE_CODE},
+	{ErrTimeout, TIMEOUT_CODE},
+	{ErrCanceled, CANCELED_CODE},
+}
+
+// ErrorKind returns the kind of the error, nil for an internal error
+func ErrorKind(err error) error {
+	for _, errorKind := range errorKinds {
+		if errors.Is(err, errorKind.kind) {
+			return errorKind.kind
+		}
+	}
+
+	return nil
+}
+
+// ErrorCode returns the machine-readable code of the error
+func ErrorCode(err error) string {
+	var dsErr *Error
+	if errors.As(err, &dsErr) && len(dsErr.Code) > 0 {
+		return dsErr.Code
+	}
+
+	for _, errorKind := range errorKinds {
+		if errors.Is(err, errorKind.kind) {
+			return errorKind.code
+		}
+	}
+
+	return INTERNAL_CODE
+}
+
+// ClassifyError gives a kind to an error returned by a datastore client. Errors that already have
+// a kind are returned as is, timeouts are ErrTimeout, cancellations are ErrCanceled and failures to
+// reach the datastore are ErrUnavailable. Any other error is returned unchanged, it is an internal
+// error.
+func ClassifyError(err error) error {
+	if err == nil || ErrorKind(err) != nil {
+		return err
+	}
+
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return TimeoutError(err)
+	}
+
+	if errors.Is(err, context.Canceled) {
+		return CanceledError(err)
+	}
+
+	var opErr *net.OpError
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, syscall.ECONNREFUSED) || errors.As(err, &opErr) {
+		return UnavailableError(err)
+	}
+
+	return err
+}
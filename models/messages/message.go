@@ -1,11 +1,21 @@
 package messages
 
 import (
+	"context"
 	"database/sql"
 	"math"
+	"time"
 )
 
 const NO_RESULTS_RETURNED_MSG string = "No results returned..."
+const RECORD_EXISTS_MSG string = "Record already exists..."
+const INVALID_QUESTION_MSG string = "Invalid question..."
+const INVALID_LIST_REQUEST_MSG string = "Invalid list request..."
+const LEASE_NOT_HELD_MSG string = "Lease not held or expired..."
+const VERSION_MISMATCH_MSG string = "Record version does not match..."
+
+// Version of a newly stored record, every update increments it
+const INITIAL_VERSION int64 = 1
 
 // Datastore contants
 const (
@@ -31,6 +41,24 @@ const (
 
 type StatusCode int
 
+// Error Response Message, the body of every failed request. Code is a machine-readable code
+// of the error, Message the message of the error and Error its details when there are any.
+// Fields holds the errors of the request fields that failed validation.
+type ErrorResponse struct {
+	Timestamp  string       `json:"timestamp"`
+	QuestionID string       `json:"questionid,omitempty"`
+	Code       string       `json:"code"`
+	Message    string       `json:"message"`
+	Error      string       `json:"error,omitempty"`
+	Fields     []FieldError `json:"fields,omitempty"`
+}
+
+// FieldError is the validation error of a single request field
+type FieldError struct {
+	Field string `json:"field"`
+	Error string `json:"error"`
+}
+
 // Status Response Message
 type StatusResponse struct {
 	Timestamp string     `json:"timestamp"`
@@ -40,12 +68,48 @@ type StatusResponse struct {
 	Error     string     `json:"error,omitempty"`
 }
 
+// Probe Response Message, the body of the liveness and readiness probes. Checks holds the result
+// of each dependency of the readiness, a failed check that has not reached the failure threshold
+// yet is still ok and reports its failures.
+const (
+	PROBE_OK     string = "ok"
+	PROBE_FAILED string = "failed"
+)
+
+type ProbeCheck struct {
+	Name      string `json:"name"`
+	Status    string `json:"status"`
+	CheckedAt string `json:"checkedat,omitempty"`
+	Failures  int    `json:"failures,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+type ProbeResponse struct {
+	Timestamp string       `json:"timestamp"`
+	Status    string       `json:"status"`
+	Checks    []ProbeCheck `json:"checks,omitempty"`
+}
+
 // Question Request-Response Messages
 type QuestionRequest struct {
-	QuestionID string `json:"questionid"`
-	Question   string `json:"question"`
-	Category   string `json:"category"`
-	Answer     string `json:"answer"`
+	QuestionID       string     `json:"questionid"`
+	Question         string     `json:"question"`
+	Category         string     `json:"category"`
+	Answer           string     `json:"answer"`
+	AlternateAnswers StringList `json:"alternateanswers,omitempty"`
+	Options          StringList `json:"options,omitempty"`
+	CorrectOptions   IntList    `json:"correctoptions,omitempty"`
+	Shuffle          *bool      `json:"shuffle,omitempty"`
+
+	// The question expires TTL seconds after it is stored or at ExpiresAt, only one of them can be set
+	TTL       int64      `json:"ttl,omitempty"`
+	ExpiresAt *time.Time `json:"expiresat,omitempty"`
+
+	// Version the stored record must have for an update to be applied, 0 skips the check
+	Version int64 `json:"version,omitempty"`
+
+	// Insert overwrites a stored question with the same question ID instead of failing
+	Upsert bool `json:"upsert,omitempty"`
 }
 
 type QuestionResponse struct {
@@ -56,37 +120,174 @@ type QuestionResponse struct {
 	Timestamp       string `json:"timestamp"`
 	Action          string `json:"action"`
 	RecordsAffected string `json:"recordsaffected"`
+	Version         int64  `json:"version,omitempty"`
 	Message         string `json:"message,omitempty"`
 	Warning         string `json:"warning,omitempty"`
 	Error           string `json:"error,omitempty"`
 }
 
 type QuestionTable struct {
-	Question string `json:"question"`
-	Category string `json:"category"`
-	Answer   string `json:"answer"`
+	Question         string     `json:"question"`
+	Category         string     `json:"category"`
+	Answer           string     `json:"answer"`
+	AlternateAnswers StringList `json:"alternateanswers,omitempty"`
+	Options          StringList `json:"options,omitempty"`
+	CorrectOptions   IntList    `json:"correctoptions,omitempty"`
+	Shuffle          bool       `json:"shuffle,omitempty"`
+	ExpiresAt        *time.Time `json:"expiresat,omitempty"`
+	Version          int64      `json:"version,omitempty"`
+}
+
+// ShuffleOptions reports whether the options are shuffled, a request that does not set shuffle
+// does not shuffle them
+func (qRequest QuestionRequest) ShuffleOptions() bool {
+	return qRequest.Shuffle != nil && *qRequest.Shuffle
+}
+
+// Build the stored record from a question request, the record starts at the initial version
+func NewQuestionTable(qRequest QuestionRequest) QuestionTable {
+	var qt QuestionTable
+	qt.Question = qRequest.Question
+	qt.Category = qRequest.Category
+	qt.Answer = qRequest.Answer
+	qt.AlternateAnswers = qRequest.AlternateAnswers
+	qt.Options = qRequest.Options
+	qt.CorrectOptions = qRequest.CorrectOptions
+	qt.Shuffle = qRequest.ShuffleOptions()
+	qt.ExpiresAt = qRequest.ExpiresAt
+	qt.Version = INITIAL_VERSION
+
+	return qt
+}
+
+// AcceptedAnswers returns the answer followed by the text of the correct options and the alternate answers
+func (qt QuestionTable) AcceptedAnswers() []string {
+	accepted := []string{qt.Answer}
+	for _, optionIdx := range qt.CorrectOptions {
+		if optionIdx >= 0 && optionIdx < len(qt.Options) {
+			accepted = append(accepted, qt.Options[optionIdx])
+		}
+	}
+
+	return append(accepted, qt.AlternateAnswers...)
+}
+
+// Batch Insert Response Messages, the request is a JSON array of QuestionRequest
+const (
+	BATCH_ITEM_CREATED string = "created"
+	BATCH_ITEM_EXISTS  string = "exists"
+	BATCH_ITEM_INVALID string = "invalid"
+	BATCH_ITEM_FAILED  string = "failed"
+)
+
+type BatchItemResult struct {
+	Index      int          `json:"index"`
+	QuestionID string       `json:"questionid"`
+	Status     string       `json:"status"`
+	Error      string       `json:"error,omitempty"`
+	Fields     []FieldError `json:"fields,omitempty"`
+}
+
+type BatchInsertResponse struct {
+	Timestamp string            `json:"timestamp"`
+	Inserted  int               `json:"inserted"`
+	Failed    int               `json:"failed"`
+	Results   []BatchItemResult `json:"results"`
+	Message   string            `json:"message,omitempty"`
+	Warning   string            `json:"warning,omitempty"`
+	Error     string            `json:"error,omitempty"`
+}
+
+// List Request-Response Messages. Questions are listed in question ID order, After is the
+// question ID the listing continues from.
+type ListRequest struct {
+	Category string `json:"category,omitempty"`
+	After    string `json:"after,omitempty"`
+	Limit    int    `json:"limit,omitempty"`
+}
+
+// QuestionRecord is a stored record along with its question ID
+type QuestionRecord struct {
+	QuestionID string `json:"questionid"`
+	QuestionTable
+}
+
+type ListResponse struct {
+	Timestamp  string           `json:"timestamp"`
+	Questions  []QuestionRecord `json:"questions"`
+	NextCursor string           `json:"nextcursor,omitempty"`
+	Message    string           `json:"message,omitempty"`
+	Warning    string           `json:"warning,omitempty"`
+	Error      string           `json:"error,omitempty"`
 }
 
 // Answer Request-Response Messages
 type AnswerRequest struct {
 	QuestionID string `json:"questionid"`
+	Peek       bool   `json:"peek,omitempty"`
+
+	// Check out the question instead of consuming it, the question is hidden from the other
+	// players until the lease is confirmed by the answer or expires
+	Lease bool `json:"lease,omitempty"`
 }
 
 type AnswerResponse struct {
-	Question  string `json:"question"`
-	Category  string `json:"category"`
-	Answer    string `json:"answer"`
-	Timestamp string `json:"timestamp"`
-	Message   string `json:"message,omitempty"`
-	Warning   string `json:"warning,omitempty"`
-	Error     string `json:"error,omitempty"`
+	QuestionID     string     `json:"questionid,omitempty"`
+	Question       string     `json:"question"`
+	Category       string     `json:"category"`
+	Answer         string     `json:"answer"`
+	Options        []string   `json:"options,omitempty"`
+	CorrectOptions []int      `json:"correctoptions,omitempty"`
+	ExpiresAt      *time.Time `json:"expiresat,omitempty"`
+	TTL            int64      `json:"ttl,omitempty"`
+	LeaseToken     string     `json:"leasetoken,omitempty"`
+	LeaseExpiresAt *time.Time `json:"leaseexpiresat,omitempty"`
+	Version        int64      `json:"version,omitempty"`
+	Timestamp      string     `json:"timestamp"`
+	Message        string     `json:"message,omitempty"`
+	Warning        string     `json:"warning,omitempty"`
+	Error          string     `json:"error,omitempty"`
+}
+
+// Draw Request Message, the drawn question is returned in an AnswerResponse
+type DrawRequest struct {
+	Category string   `json:"category,omitempty"`
+	Exclude  []string `json:"exclude,omitempty"`
+}
+
+// Check Answer Request-Response Messages
+type CheckAnswerRequest struct {
+	QuestionID string `json:"questionid"`
+	Answer     string `json:"answer"`
+	LeaseToken string `json:"leasetoken,omitempty"`
+}
+
+type CheckAnswerResponse struct {
+	QuestionID string `json:"questionid"`
+	Question   string `json:"question"`
+	Category   string `json:"category"`
+	Answer     string `json:"answer"`
+	Correct    bool   `json:"correct"`
+	Timestamp  string `json:"timestamp"`
+	Message    string `json:"message,omitempty"`
+	Warning    string `json:"warning,omitempty"`
+	Error      string `json:"error,omitempty"`
 }
 
 type IDBModel interface {
 	Open(driverName string) (*sql.DB, error)
-	Ping() error
-	Insert(question QuestionRequest) (int64, error)
-	Get(questionID string) (QuestionTable, error)
-	Update(question QuestionRequest) (int64, error)
-	Delete(questionID string) (int64, error)
+	Close() error
+	Ping(ctx context.Context) error
+	Insert(ctx context.Context, question QuestionRequest) (int64, error)
+	Upsert(ctx context.Context, question QuestionRequest) (int64, error)
+	InsertBatch(ctx context.Context, questions []QuestionRequest) ([]error, error)
+	Get(ctx context.Context, questionID string) (QuestionTable, error)
+	Consume(ctx context.Context, questionID string) (QuestionTable, error)
+	Lease(ctx context.Context, questionID string, leaseToken string, leaseExpiresAt time.Time) (QuestionTable, error)
+	Confirm(ctx context.Context, questionID string, leaseToken string) (QuestionTable, error)
+	List(ctx context.Context, lRequest ListRequest) ([]QuestionRecord, error)
+	Draw(ctx context.Context, dRequest DrawRequest) (QuestionRecord, error)
+	Update(ctx context.Context, question QuestionRequest) (int64, int64, error)
+	Delete(ctx context.Context, questionID string, version int64) (int64, error)
+	Reap(ctx context.Context) (int64, error)
 }
@@ -0,0 +1,66 @@
+package messages
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+)
+
+const LIST_SCAN_ERROR string = "Unsupported type for list column..."
+
+// StringList is stored by the SQL drivers as a JSON array in a text column
+type StringList []string
+
+// Value implements driver.Valuer, an empty list is stored as an empty JSON array
+func (sl StringList) Value() (driver.Value, error) {
+	if sl == nil {
+		return "[]", nil
+	}
+
+	byteStream, marshalErr := json.Marshal([]string(sl))
+	if marshalErr != nil {
+		return nil, marshalErr
+	}
+
+	return string(byteStream), nil
+}
+
+// Scan implements sql.Scanner
+func (sl *StringList) Scan(src interface{}) error {
+	return scanJSONList(src, (*[]string)(sl))
+}
+
+// IntList is stored by the SQL drivers as a JSON array in a text column
+type IntList []int
+
+// Value implements driver.Valuer, an empty list is stored as an empty JSON array
+func (il IntList) Value() (driver.Value, error) {
+	if il == nil {
+		return "[]", nil
+	}
+
+	byteStream, marshalErr := json.Marshal([]int(il))
+	if marshalErr != nil {
+		return nil, marshalErr
+	}
+
+	return string(byteStream), nil
+}
+
+// Scan implements sql.Scanner
+func (il *IntList) Scan(src interface{}) error {
+	return scanJSONList(src, (*[]int)(il))
+}
+
+func scanJSONList(src interface{}, dest interface{}) error {
+	switch value := src.(type) {
+	case nil:
+		return nil
+	case string:
+		return json.Unmarshal([]byte(value), dest)
+	case []byte:
+		return json.Unmarshal(value, dest)
+	default:
+		return errors.New(LIST_SCAN_ERROR)
+	}
+}
@@ -1,25 +1,107 @@
 package models
 
 import (
-	"database/sql"
+	"context"
+	"encoding/base64"
 	"errors"
+	"fmt"
 	"log"
+	"math/rand"
+	"strconv"
+	"sync"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/sflewis2970/datastore-service/common"
 	"github.com/sflewis2970/datastore-service/config"
+	"github.com/sflewis2970/datastore-service/models/dsmysql"
 	"github.com/sflewis2970/datastore-service/models/dspostgresql"
+	"github.com/sflewis2970/datastore-service/models/dssqlite"
 	"github.com/sflewis2970/datastore-service/models/gocache"
 	"github.com/sflewis2970/datastore-service/models/goredis"
+	"github.com/sflewis2970/datastore-service/models/matcher"
 	"github.com/sflewis2970/datastore-service/models/messages"
+	"github.com/sflewis2970/datastore-service/tracing"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// Multiple-choice validation errors
+const (
+	TOO_FEW_OPTIONS_ERROR           string = "a multiple-choice question needs at least two options"
+	EMPTY_OPTION_ERROR              string = "options must not be empty"
+	MISSING_OPTIONS_ERROR           string = "correctoptions requires options"
+	MISSING_CORRECT_OPTION_ERROR    string = "the answer must be one of the options"
+	CORRECT_OPTION_RANGE_ERROR      string = "correctoptions index out of range: "
+	DUPLICATE_CORRECT_OPTION_ERROR  string = "correctoptions index repeated: "
+	ANSWER_NOT_CORRECT_OPTION_ERROR string = "the answer is not one of the correct options"
+)
+
+// Batch insert validation errors
+const (
+	MISSING_QUESTION_ID_ERROR  string = "questionid is required"
+	REPEATED_QUESTION_ID_ERROR string = "questionid repeated in the batch: "
+)
+
+// List limits
+const (
+	DEFAULT_LIST_LIMIT int = 50
+	MAX_LIST_LIMIT     int = 500
+)
+
+// List validation errors
+const (
+	LIST_LIMIT_ERROR  string = "limit must be between 1 and 500"
+	LIST_CURSOR_ERROR string = "invalid cursor"
+)
+
+// Expiry validation errors
+const (
+	NEGATIVE_TTL_ERROR   string = "ttl must not be negative"
+	TTL_AND_EXPIRY_ERROR string = "ttl and expiresat cannot both be set"
+	PAST_EXPIRY_ERROR    string = "expiresat must be in the future"
+)
+
+// Number of times a patch without a version is merged again after another write changed the record
+const PATCH_ATTEMPTS int = 3
+
+// Minimum number of options of a multiple-choice question
+const MIN_OPTIONS int = 2
+
 type Model struct {
-	cfgData *config.ConfigData
-	dbModel messages.IDBModel
+	cfgData       *config.ConfigData
+	dbModelMutex  sync.Mutex
+	dbModel       messages.IDBModel
+	answerMatcher matcher.IMatcher
+}
+
+// SetMatcher replaces the matcher used to check submitted answers
+func (m *Model) SetMatcher(answerMatcher matcher.IMatcher) {
+	m.answerMatcher = answerMatcher
 }
 
-func (m *Model) Status() (messages.StatusResponse, error) {
+// SetDBModel replaces the datastore driver used by the model
+func (m *Model) SetDBModel(dbModel messages.IDBModel) {
+	m.dbModelMutex.Lock()
+	defer m.dbModelMutex.Unlock()
+
+	m.dbModel = dbModel
+}
+
+// Return the datastore driver, it is created by the first request and shared by the requests
+// running concurrently
+func (m *Model) activeDBModel() messages.IDBModel {
+	m.dbModelMutex.Lock()
+	defer m.dbModelMutex.Unlock()
+
+	m.dbModel = m.NewDBModel(m.cfgData.ActiveDriver)
+
+	return m.dbModel
+}
+
+func (m *Model) Status(ctx context.Context) (messages.StatusResponse, error) {
+	ctx, span := tracing.Start(ctx, "Model.Status")
+	defer span.End()
+
 	// Load config data
 	log.Print("Getting active datastore driver from config data...")
 
@@ -27,14 +109,17 @@ func (m *Model) Status() (messages.StatusResponse, error) {
 	var sResponse messages.StatusResponse
 
 	// DB Model
-	m.dbModel = m.NewDBModel(m.cfgData.ActiveDriver)
+	dbModel := m.activeDBModel()
+	ctx, cancel := m.operationContext(ctx, m.cfgData.OperationTimeoutMS)
+	defer cancel()
 
 	sResponse.Timestamp = common.GetFormattedTime(time.Now(), "Mon Jan 2 15:04:05 2006")
-	if m.dbModel != nil {
+	if dbModel != nil {
 		// Update Server response fields
-		pingErr := m.dbModel.Ping()
+		pingErr := dbModel.Ping(ctx)
 
 		if pingErr != nil {
+			tracing.SetError(span, pingErr)
 			sResponse.Error = pingErr.Error()
 			sResponse.Status = messages.StatusCode(messages.DS_UNAVAILABLE)
 			return sResponse, pingErr
@@ -45,14 +130,211 @@ func (m *Model) Status() (messages.StatusResponse, error) {
 	} else {
 		sResponse.Error = "dbModel not created"
 		sResponse.Status = messages.StatusCode(messages.DS_UNAVAILABLE)
+		return sResponse, messages.UnavailableError(errors.New(sResponse.Error))
 	}
 
 	return sResponse, nil
 }
 
-func (m *Model) Insert(qRequest messages.QuestionRequest) (messages.QuestionResponse, error) {
-	m.dbModel = m.NewDBModel(m.cfgData.ActiveDriver)
-	rowsAffected, insertErr := m.dbModel.Insert(qRequest)
+// Validate the options of a multiple-choice question. The correct options can be given as
+// indices, as the answer text or both. The missing one is filled in from the other.
+func validateOptions(qRequest *messages.QuestionRequest) error {
+	if len(qRequest.Options) == 0 {
+		if len(qRequest.CorrectOptions) > 0 {
+			return errors.New(MISSING_OPTIONS_ERROR)
+		}
+
+		return nil
+	}
+
+	if len(qRequest.Options) < MIN_OPTIONS {
+		return errors.New(TOO_FEW_OPTIONS_ERROR)
+	}
+
+	for _, option := range qRequest.Options {
+		if len(option) == 0 {
+			return errors.New(EMPTY_OPTION_ERROR)
+		}
+	}
+
+	// Derive the correct option from the answer
+	if len(qRequest.CorrectOptions) == 0 {
+		for optionIdx, option := range qRequest.Options {
+			if len(qRequest.Answer) > 0 && option == qRequest.Answer {
+				qRequest.CorrectOptions = messages.IntList{optionIdx}
+				break
+			}
+		}
+
+		if len(qRequest.CorrectOptions) == 0 {
+			return errors.New(MISSING_CORRECT_OPTION_ERROR)
+		}
+	}
+
+	usedIdx := make(map[int]bool)
+	answerFound := len(qRequest.Answer) == 0
+	for _, optionIdx := range qRequest.CorrectOptions {
+		if optionIdx < 0 || optionIdx >= len(qRequest.Options) {
+			return errors.New(CORRECT_OPTION_RANGE_ERROR + strconv.Itoa(optionIdx))
+		}
+
+		if usedIdx[optionIdx] {
+			return errors.New(DUPLICATE_CORRECT_OPTION_ERROR + strconv.Itoa(optionIdx))
+		}
+		usedIdx[optionIdx] = true
+
+		if qRequest.Options[optionIdx] == qRequest.Answer {
+			answerFound = true
+		}
+	}
+
+	if !answerFound {
+		return errors.New(ANSWER_NOT_CORRECT_OPTION_ERROR)
+	}
+
+	// Derive the answer from the first correct option
+	if len(qRequest.Answer) == 0 {
+		qRequest.Answer = qRequest.Options[qRequest.CorrectOptions[0]]
+	}
+
+	return nil
+}
+
+// Validate the expiry of a question. A TTL is turned into the matching expiry time so the
+// drivers only deal with ExpiresAt, which is stored in UTC.
+func validateExpiry(qRequest *messages.QuestionRequest) error {
+	if qRequest.TTL < 0 {
+		return errors.New(NEGATIVE_TTL_ERROR)
+	}
+
+	if qRequest.TTL > 0 {
+		if qRequest.ExpiresAt != nil {
+			return errors.New(TTL_AND_EXPIRY_ERROR)
+		}
+
+		expiresAt := time.Now().Add(time.Duration(qRequest.TTL) * time.Second).UTC().Truncate(time.Millisecond)
+		qRequest.ExpiresAt = &expiresAt
+		qRequest.TTL = 0
+
+		return nil
+	}
+
+	if qRequest.ExpiresAt != nil {
+		if !qRequest.ExpiresAt.After(time.Now()) {
+			return errors.New(PAST_EXPIRY_ERROR)
+		}
+
+		expiresAt := qRequest.ExpiresAt.UTC().Truncate(time.Millisecond)
+		qRequest.ExpiresAt = &expiresAt
+	}
+
+	return nil
+}
+
+// Validate a question before it is stored
+func validateQuestion(qRequest *messages.QuestionRequest) error {
+	optionsErr := validateOptions(qRequest)
+	if optionsErr != nil {
+		return optionsErr
+	}
+
+	return validateExpiry(qRequest)
+}
+
+// Build the response and the ErrInvalid error returned for a question that failed validation
+func invalidQuestionResponse(qRequest messages.QuestionRequest, validateErr error) (messages.QuestionResponse, error) {
+	log.Print("Invalid question: ", validateErr)
+
+	var qResponse messages.QuestionResponse
+	qResponse.Timestamp = common.GetFormattedTime(time.Now(), "Mon Jan 2 15:04:05 2006")
+	qResponse.QuestionID = qRequest.QuestionID
+	qResponse.Message = messages.INVALID_QUESTION_MSG
+	qResponse.Error = validateErr.Error()
+
+	return qResponse, messages.NewError(messages.ErrInvalid, messages.INVALID_CODE, messages.INVALID_QUESTION_MSG, validateErr)
+}
+
+// Bound the datastore calls of an operation by the timeout, the request context may end them earlier
+func (m *Model) operationContext(ctx context.Context, timeoutMS int) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, time.Duration(timeoutMS)*time.Millisecond)
+}
+
+// Log the error of a failed operation, record it on the span of the operation and wrap it with the
+// operation, the kind of the error is kept.
+// Whatever error the datastore returned, an operation that ran out of time is a timeout.
+func operationError(ctx context.Context, operation string, opErr error) error {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) && !errors.Is(opErr, messages.ErrTimeout) {
+		opErr = messages.TimeoutError(opErr)
+	}
+
+	wrappedErr := fmt.Errorf("%s: %w", operation, opErr)
+	log.Print(wrappedErr)
+	tracing.SetError(trace.SpanFromContext(ctx), wrappedErr)
+
+	return wrappedErr
+}
+
+// Message of the response of a failed operation, the message of the error kinds the clients
+// already know from the earlier responses
+func errorMessage(opErr error) string {
+	switch {
+	case errors.Is(opErr, messages.ErrNotFound):
+		return messages.NO_RESULTS_RETURNED_MSG
+	case errors.Is(opErr, messages.ErrRecordExists):
+		return messages.RECORD_EXISTS_MSG
+	case errors.Is(opErr, messages.ErrLeaseNotHeld):
+		return messages.LEASE_NOT_HELD_MSG
+	case errors.Is(opErr, messages.ErrVersionMismatch):
+		return messages.VERSION_MISMATCH_MSG
+	}
+
+	return ""
+}
+
+// Shuffle the options of a multiple-choice question, the correct options follow their option
+func shuffleOptions(options []string, correctOptions []int) ([]string, []int) {
+	order := rand.New(rand.NewSource(time.Now().UnixNano())).Perm(len(options))
+
+	shuffledOptions := make([]string, len(options))
+	newIdx := make([]int, len(options))
+	for idx, optionIdx := range order {
+		shuffledOptions[idx] = options[optionIdx]
+		newIdx[optionIdx] = idx
+	}
+
+	shuffledCorrect := make([]int, 0, len(correctOptions))
+	for _, optionIdx := range correctOptions {
+		if optionIdx >= 0 && optionIdx < len(options) {
+			shuffledCorrect = append(shuffledCorrect, newIdx[optionIdx])
+		}
+	}
+
+	return shuffledOptions, shuffledCorrect
+}
+
+// Insert stores a new record. A record with the same question ID is only overwritten when the
+// request asks for an upsert, otherwise the response holds RECORD_EXISTS_MSG.
+func (m *Model) Insert(ctx context.Context, qRequest messages.QuestionRequest) (messages.QuestionResponse, error) {
+	ctx, span := tracing.Start(ctx, "Model.Insert", tracing.QuestionIDKey.String(qRequest.QuestionID))
+	defer span.End()
+
+	validateErr := validateQuestion(&qRequest)
+	if validateErr != nil {
+		return invalidQuestionResponse(qRequest, validateErr)
+	}
+
+	dbModel := m.activeDBModel()
+	ctx, cancel := m.operationContext(ctx, m.cfgData.OperationTimeoutMS)
+	defer cancel()
+
+	// Insert is create-only, an upsert overwrites the stored question
+	var rowsAffected int64
+	var insertErr error
+	if qRequest.Upsert {
+		rowsAffected, insertErr = dbModel.Upsert(ctx, qRequest)
+	} else {
+		rowsAffected, insertErr = dbModel.Insert(ctx, qRequest)
+	}
 
 	var qResponse messages.QuestionResponse
 
@@ -60,14 +342,14 @@ func (m *Model) Insert(qRequest messages.QuestionRequest) (messages.QuestionResp
 	qResponse.Timestamp = common.GetFormattedTime(time.Now(), "Mon Jan 2 15:04:05 2006")
 
 	if insertErr != nil {
-		// Display a log message
-		errMsg := "Insertion error: " + insertErr.Error()
-		log.Printf(errMsg)
+		insertErr = operationError(ctx, "Insertion error", insertErr)
 
 		// Update response fields
-		qResponse.Error = errMsg
+		qResponse.QuestionID = qRequest.QuestionID
+		qResponse.Message = errorMessage(insertErr)
+		qResponse.Error = insertErr.Error()
 
-		return qResponse, errors.New(errMsg)
+		return qResponse, insertErr
 	} else {
 		if rowsAffected > 0 {
 			log.Print("rows affected: ", rowsAffected)
@@ -81,120 +363,580 @@ func (m *Model) Insert(qRequest messages.QuestionRequest) (messages.QuestionResp
 		// Display a log message
 		log.Print("sending response to client...")
 
-		// Build QuestionResponse message
-		qResponse.Message = "Record added to the datastore"
+		// Build QuestionResponse message, the version of an overwritten record is not known
+		if qRequest.Upsert {
+			qResponse.Message = "Record stored in the datastore"
+		} else {
+			qResponse.Version = messages.INITIAL_VERSION
+			qResponse.Message = "Record added to the datastore"
+		}
 	}
 
 	return qResponse, nil
 }
 
-func (m *Model) Get(aRequest messages.AnswerRequest) (messages.AnswerResponse, error) {
+// InsertBatch validates the records and inserts the valid ones with a single driver call. Every
+// record gets its own result, a record failing does not prevent the others from being inserted.
+// fieldErrs holds the field errors of each record found by the caller, a record with field errors
+// is reported as invalid without being validated again.
+// Batches are create-only, records already stored are reported as existing.
+func (m *Model) InsertBatch(ctx context.Context, qRequests []messages.QuestionRequest, fieldErrs [][]messages.FieldError) (messages.BatchInsertResponse, error) {
+	ctx, span := tracing.Start(ctx, "Model.InsertBatch", tracing.RecordsKey.Int(len(qRequests)))
+	defer span.End()
+
+	var biResponse messages.BatchInsertResponse
+	biResponse.Timestamp = common.GetFormattedTime(time.Now(), "Mon Jan 2 15:04:05 2006")
+	biResponse.Results = make([]messages.BatchItemResult, len(qRequests))
+
+	// Validate records
+	validRequests := make([]messages.QuestionRequest, 0, len(qRequests))
+	validIdx := make([]int, 0, len(qRequests))
+	questionIDs := make(map[string]bool)
+	for idx := range qRequests {
+		biResponse.Results[idx].Index = idx
+		biResponse.Results[idx].QuestionID = qRequests[idx].QuestionID
+
+		if idx < len(fieldErrs) && len(fieldErrs[idx]) > 0 {
+			biResponse.Results[idx].Status = messages.BATCH_ITEM_INVALID
+			biResponse.Results[idx].Error = messages.INVALID_QUESTION_MSG
+			biResponse.Results[idx].Fields = fieldErrs[idx]
+			continue
+		}
+
+		var validateErr error
+		if len(qRequests[idx].QuestionID) == 0 {
+			validateErr = errors.New(MISSING_QUESTION_ID_ERROR)
+		} else if questionIDs[qRequests[idx].QuestionID] {
+			validateErr = errors.New(REPEATED_QUESTION_ID_ERROR + qRequests[idx].QuestionID)
+		} else {
+			validateErr = validateQuestion(&qRequests[idx])
+		}
+
+		if validateErr != nil {
+			biResponse.Results[idx].Status = messages.BATCH_ITEM_INVALID
+			biResponse.Results[idx].Error = validateErr.Error()
+			continue
+		}
+
+		questionIDs[qRequests[idx].QuestionID] = true
+		validRequests = append(validRequests, qRequests[idx])
+		validIdx = append(validIdx, idx)
+	}
+
+	// Insert valid records
+	itemErrs := make([]error, 0)
+	if len(validRequests) > 0 {
+		dbModel := m.activeDBModel()
+		ctx, cancel := m.operationContext(ctx, m.cfgData.BatchTimeoutMS)
+		defer cancel()
+
+		var insertErr error
+		itemErrs, insertErr = dbModel.InsertBatch(ctx, validRequests)
+		if insertErr != nil {
+			insertErr = operationError(ctx, "Batch insertion error", insertErr)
+
+			// Update response fields
+			biResponse.Results = nil
+			biResponse.Error = insertErr.Error()
+
+			return biResponse, insertErr
+		}
+	}
+
+	for idx, itemErr := range itemErrs {
+		result := &biResponse.Results[validIdx[idx]]
+		switch {
+		case itemErr == nil:
+			result.Status = messages.BATCH_ITEM_CREATED
+		case errors.Is(itemErr, messages.ErrRecordExists):
+			result.Status = messages.BATCH_ITEM_EXISTS
+			result.Error = itemErr.Error()
+		default:
+			result.Status = messages.BATCH_ITEM_FAILED
+			result.Error = itemErr.Error()
+		}
+	}
+
+	for _, result := range biResponse.Results {
+		if result.Status == messages.BATCH_ITEM_CREATED {
+			biResponse.Inserted++
+		} else {
+			biResponse.Failed++
+		}
+	}
+
+	log.Printf("Batch insert, records inserted: %d, records failed: %d", biResponse.Inserted, biResponse.Failed)
+	biResponse.Message = strconv.Itoa(biResponse.Inserted) + " records added to the datastore"
+
+	return biResponse, nil
+}
+
+func (m *Model) Get(ctx context.Context, aRequest messages.AnswerRequest) (messages.AnswerResponse, error) {
+	ctx, span := tracing.Start(ctx, "Model.Get", tracing.QuestionIDKey.String(aRequest.QuestionID))
+	defer span.End()
+
 	// use dbModel to execute SQL command
-	m.dbModel = m.NewDBModel(m.cfgData.ActiveDriver)
+	dbModel := m.activeDBModel()
+	ctx, cancel := m.operationContext(ctx, m.cfgData.OperationTimeoutMS)
+	defer cancel()
+
+	// The record is consumed once the client has been handed the question, whether
+	// the answer is correct or not. Consume reads and deletes the record in a single
+	// operation so only one client can receive the question. A peek leaves the record
+	// in place so it can be displayed without being consumed. A lease checks the record out
+	// until the answer confirms it, or the lease expires and the record returns to the pool.
+	var qt messages.QuestionTable
+	var getErr error
+	var leaseToken string
+	var leaseExpiresAt time.Time
+	if aRequest.Peek {
+		log.Print("Peek requested, record is kept in the datastore")
+		qt, getErr = dbModel.Get(ctx, aRequest.QuestionID)
+	} else if aRequest.Lease {
+		leaseToken = uuid.NewString()
+		leaseExpiresAt = time.Now().Add(time.Duration(m.cfgData.LeaseDuration) * time.Second).UTC().Truncate(time.Millisecond)
+		log.Print("Lease requested, record is checked out until: ", leaseExpiresAt)
+		qt, getErr = dbModel.Lease(ctx, aRequest.QuestionID, leaseToken, leaseExpiresAt)
+	} else {
+		qt, getErr = dbModel.Consume(ctx, aRequest.QuestionID)
+	}
 
 	var aResponse messages.AnswerResponse
-	qt, getErr := m.dbModel.Get(aRequest.QuestionID)
 	if getErr != nil {
-		// Display a log message
-		errMsg := "Get error: " + getErr.Error()
-		log.Printf(errMsg)
+		getErr = operationError(ctx, "Get error", getErr)
 
 		// Update response fields
-		aResponse.Error = errMsg
+		aResponse.Timestamp = common.GetFormattedTime(time.Now(), "Mon Jan 2 15:04:05 2006")
+		aResponse.Message = errorMessage(getErr)
+		aResponse.Error = getErr.Error()
 
-		return aResponse, errors.New(errMsg)
+		return aResponse, getErr
 	}
 
-	// Build AnswerResponse message
+	aResponse = newAnswerResponse(qt)
+	if len(leaseToken) > 0 {
+		aResponse.LeaseToken = leaseToken
+		aResponse.LeaseExpiresAt = &leaseExpiresAt
+	}
+
+	return aResponse, nil
+}
+
+// Build the AnswerResponse of a retrieved record, the options are shuffled when requested by the record
+func newAnswerResponse(qt messages.QuestionTable) messages.AnswerResponse {
+	var aResponse messages.AnswerResponse
 	aResponse.Timestamp = common.GetFormattedTime(time.Now(), "Mon Jan 2 15:04:05 2006")
 	aResponse.Question = qt.Question
 	aResponse.Category = qt.Category
 	aResponse.Answer = qt.Answer
+	aResponse.Options = qt.Options
+	aResponse.CorrectOptions = qt.CorrectOptions
+	aResponse.Version = qt.Version
+	if qt.ExpiresAt != nil {
+		// Remaining lifetime rounded up, a question about to expire reports 1 second rather than 0
+		aResponse.ExpiresAt = qt.ExpiresAt
+		aResponse.TTL = int64((time.Until(*qt.ExpiresAt) + time.Second - 1) / time.Second)
+	}
+
+	if qt.Shuffle {
+		aResponse.Options, aResponse.CorrectOptions = shuffleOptions(qt.Options, qt.CorrectOptions)
+	}
+
+	log.Print("Question retrieved processing message...")
 
-	// Since sql.QueryRow wraps no results inside error messages,
-	// when an error is returned a check needs to be made
-	// if ErrNoRows is returned.
-	if getErr != nil && getErr != sql.ErrNoRows {
-		errMsg := "Error getting record: " + getErr.Error()
-		aResponse.Error = errMsg
+	return aResponse
+}
+
+// Draw consumes a random question, optionally from a category and skipping the excluded question IDs
+func (m *Model) Draw(ctx context.Context, dRequest messages.DrawRequest) (messages.AnswerResponse, error) {
+	ctx, span := tracing.Start(ctx, "Model.Draw")
+	defer span.End()
+
+	dbModel := m.activeDBModel()
+	ctx, cancel := m.operationContext(ctx, m.cfgData.OperationTimeoutMS)
+	defer cancel()
 
-		return aResponse, errors.New(errMsg)
-	} else if len(qt.Question) > 0 {
-		log.Print("Question retrieved processing message...")
-		// Build Response Message
+	qRecord, drawErr := dbModel.Draw(ctx, dRequest)
+	if drawErr != nil {
+		drawErr = operationError(ctx, "Draw error", drawErr)
 
-		// delete record from DB once the client answers the question
-		// Whether the answer is correct or not
-		_, delErr := m.dbModel.Delete(aRequest.QuestionID)
-		if delErr != nil {
-			errMsg := "Error deleting record: " + delErr.Error()
-			aResponse.Error = delErr.Error()
+		var aResponse messages.AnswerResponse
+		aResponse.Timestamp = common.GetFormattedTime(time.Now(), "Mon Jan 2 15:04:05 2006")
+		aResponse.Message = errorMessage(drawErr)
+		aResponse.Error = drawErr.Error()
+
+		return aResponse, drawErr
+	}
+
+	aResponse := newAnswerResponse(qRecord.QuestionTable)
+	aResponse.QuestionID = qRecord.QuestionID
+
+	return aResponse, nil
+}
+
+// List returns a page of questions in question ID order. The cursor is an opaque value returned
+// in NextCursor by the previous page, an empty cursor starts from the first question.
+func (m *Model) List(ctx context.Context, category string, cursor string, limit int) (messages.ListResponse, error) {
+	ctx, span := tracing.Start(ctx, "Model.List")
+	defer span.End()
+
+	var lResponse messages.ListResponse
+	lResponse.Timestamp = common.GetFormattedTime(time.Now(), "Mon Jan 2 15:04:05 2006")
+	lResponse.Questions = make([]messages.QuestionRecord, 0)
+
+	if limit == 0 {
+		limit = DEFAULT_LIST_LIMIT
+	}
+
+	if limit < 0 || limit > MAX_LIST_LIMIT {
+		lResponse.Message = messages.INVALID_LIST_REQUEST_MSG
+		lResponse.Error = LIST_LIMIT_ERROR
+		return lResponse, messages.NewError(messages.ErrInvalid, messages.INVALID_CODE, messages.INVALID_LIST_REQUEST_MSG, errors.New(LIST_LIMIT_ERROR))
+	}
+
+	after, decodeErr := base64.RawURLEncoding.DecodeString(cursor)
+	if decodeErr != nil {
+		lResponse.Message = messages.INVALID_LIST_REQUEST_MSG
+		lResponse.Error = LIST_CURSOR_ERROR
+		return lResponse, messages.NewError(messages.ErrInvalid, messages.INVALID_CODE, messages.INVALID_LIST_REQUEST_MSG, errors.New(LIST_CURSOR_ERROR))
+	}
+
+	// One more record than the limit is requested to find out whether there is a next page
+	var lRequest messages.ListRequest
+	lRequest.Category = category
+	lRequest.After = string(after)
+	lRequest.Limit = limit + 1
+
+	dbModel := m.activeDBModel()
+	ctx, cancel := m.operationContext(ctx, m.cfgData.OperationTimeoutMS)
+	defer cancel()
+
+	qRecords, listErr := dbModel.List(ctx, lRequest)
+	if listErr != nil {
+		listErr = operationError(ctx, "List error", listErr)
+
+		// Update response fields
+		lResponse.Error = listErr.Error()
+
+		return lResponse, listErr
+	}
+
+	if len(qRecords) > limit {
+		qRecords = qRecords[:limit]
+		lResponse.NextCursor = base64.RawURLEncoding.EncodeToString([]byte(qRecords[limit-1].QuestionID))
+	}
 
-			return aResponse, errors.New(errMsg)
+	lResponse.Questions = qRecords
+	if len(qRecords) == 0 {
+		lResponse.Message = messages.NO_RESULTS_RETURNED_MSG
+	}
+
+	return lResponse, nil
+}
+
+// CheckAnswer compares the submitted answer with the stored answer. The question is consumed
+// by the check, the correct answer is only returned once the question has been answered.
+func (m *Model) CheckAnswer(ctx context.Context, caRequest messages.CheckAnswerRequest) (messages.CheckAnswerResponse, error) {
+	ctx, span := tracing.Start(ctx, "Model.CheckAnswer", tracing.QuestionIDKey.String(caRequest.QuestionID))
+	defer span.End()
+
+	dbModel := m.activeDBModel()
+	ctx, cancel := m.operationContext(ctx, m.cfgData.OperationTimeoutMS)
+	defer cancel()
+
+	// An answer carrying a lease token confirms the lease, which consumes the checked out record
+	var caResponse messages.CheckAnswerResponse
+	var qt messages.QuestionTable
+	var consumeErr error
+	if len(caRequest.LeaseToken) > 0 {
+		qt, consumeErr = dbModel.Confirm(ctx, caRequest.QuestionID, caRequest.LeaseToken)
+	} else {
+		qt, consumeErr = dbModel.Consume(ctx, caRequest.QuestionID)
+	}
+
+	// Tell a lease that is not held apart from a record that does not exist
+	if errors.Is(consumeErr, messages.ErrNotFound) && len(caRequest.LeaseToken) > 0 {
+		_, getErr := dbModel.Get(ctx, caRequest.QuestionID)
+		if getErr == nil {
+			consumeErr = messages.ErrLeaseNotHeld
+		} else if !errors.Is(getErr, messages.ErrNotFound) {
+			consumeErr = getErr
 		}
+	}
+
+	// Update timestamp
+	caResponse.Timestamp = common.GetFormattedTime(time.Now(), "Mon Jan 2 15:04:05 2006")
+	caResponse.QuestionID = caRequest.QuestionID
+
+	if consumeErr != nil {
+		consumeErr = operationError(ctx, "Check answer error", consumeErr)
+
+		// Update response fields
+		caResponse.Message = errorMessage(consumeErr)
+		caResponse.Error = consumeErr.Error()
+
+		return caResponse, consumeErr
+	}
+
+	// Build CheckAnswerResponse
+	caResponse.Question = qt.Question
+	caResponse.Category = qt.Category
+	caResponse.Answer = qt.Answer
+	caResponse.Correct = m.answerMatcher.Match(caRequest.Answer, qt.AcceptedAnswers())
+
+	if caResponse.Correct {
+		caResponse.Message = m.cfgData.Messages.Congrats
 	} else {
-		aResponse.Message = messages.NO_RESULTS_RETURNED_MSG
+		caResponse.Message = m.cfgData.Messages.TryAgain
 	}
 
-	return aResponse, nil
+	return caResponse, nil
 }
 
-func (m *Model) Update(qRequest messages.QuestionRequest) (messages.QuestionResponse, error) {
-	m.dbModel = m.NewDBModel(m.cfgData.ActiveDriver)
+func (m *Model) Update(ctx context.Context, qRequest messages.QuestionRequest) (messages.QuestionResponse, error) {
+	ctx, span := tracing.Start(ctx, "Model.Update", tracing.QuestionIDKey.String(qRequest.QuestionID))
+	defer span.End()
+
+	validateErr := validateQuestion(&qRequest)
+	if validateErr != nil {
+		return invalidQuestionResponse(qRequest, validateErr)
+	}
+
+	return m.update(ctx, qRequest)
+}
+
+// Update the record with a validated request
+func (m *Model) update(ctx context.Context, qRequest messages.QuestionRequest) (messages.QuestionResponse, error) {
+	dbModel := m.activeDBModel()
+	ctx, cancel := m.operationContext(ctx, m.cfgData.OperationTimeoutMS)
+	defer cancel()
 
 	var qResponse messages.QuestionResponse
-	_, updateErr := m.dbModel.Update(qRequest)
+	rowsAffected, version, updateErr := dbModel.Update(ctx, qRequest)
+
+	// Update timestamp
+	qResponse.Timestamp = common.GetFormattedTime(time.Now(), "Mon Jan 2 15:04:05 2006")
+
+	qResponse.QuestionID = qRequest.QuestionID
+
 	if updateErr != nil {
-		// Display a log message
-		errMsg := "Error updating record: " + updateErr.Error()
-		log.Printf(errMsg)
+		updateErr = operationError(ctx, "Error updating record", updateErr)
 
 		// Update response fields
-		qResponse.Error = errMsg
+		qResponse.RecordsAffected = strconv.FormatInt(messages.RESULTS_DEFAULT, 10)
+		qResponse.Message = errorMessage(updateErr)
+		qResponse.Error = updateErr.Error()
 
-		return qResponse, errors.New(errMsg)
-	} else {
-		// Build QuestionResponse message
-		qResponse.Message = "Updated question record in database"
+		return qResponse, updateErr
 	}
 
+	// Build QuestionResponse
+	qResponse.RecordsAffected = strconv.FormatInt(rowsAffected, 10)
+	qResponse.Question = qRequest.Question
+	qResponse.Category = qRequest.Category
+
+	qResponse.Version = version
+
+	// Build QuestionResponse message
+	qResponse.Message = "Updated question record in database"
+
 	return qResponse, nil
 }
 
-func (m *Model) Delete(questionID string) (messages.QuestionResponse, error) {
-	m.dbModel = m.NewDBModel(m.cfgData.ActiveDriver)
+// Patch updates only the fields that are set in the request, the remaining fields keep their stored values.
+// The update is conditional on the version that was read, so a write landing in between is never
+// overwritten with stale fields. Without a version in the request the merge is then done again.
+func (m *Model) Patch(ctx context.Context, qRequest messages.QuestionRequest) (messages.QuestionResponse, error) {
+	ctx, span := tracing.Start(ctx, "Model.Patch", tracing.QuestionIDKey.String(qRequest.QuestionID))
+	defer span.End()
+
+	attempts := PATCH_ATTEMPTS
+	if qRequest.Version > 0 {
+		attempts = 1
+	}
 
-	_, delErr := m.dbModel.Delete(questionID)
+	ctx, cancel := m.operationContext(ctx, m.cfgData.OperationTimeoutMS)
+	defer cancel()
 
 	var qResponse messages.QuestionResponse
+	var patchErr error
+	for attempt := 0; attempt < attempts; attempt++ {
+		qResponse, patchErr = m.patch(ctx, qRequest)
+		if !errors.Is(patchErr, messages.ErrVersionMismatch) {
+			break
+		}
+	}
+	tracing.SetError(span, patchErr)
+
+	return qResponse, patchErr
+}
+
+// Merge the request into the stored record and update it
+func (m *Model) patch(ctx context.Context, qRequest messages.QuestionRequest) (messages.QuestionResponse, error) {
+	dbModel := m.activeDBModel()
+
+	qt, getErr := dbModel.Get(ctx, qRequest.QuestionID)
+	if getErr != nil {
+		getErr = operationError(ctx, "Error getting record", getErr)
+
+		var qResponse messages.QuestionResponse
+		qResponse.Timestamp = common.GetFormattedTime(time.Now(), "Mon Jan 2 15:04:05 2006")
+		qResponse.QuestionID = qRequest.QuestionID
+		qResponse.RecordsAffected = strconv.FormatInt(messages.RESULTS_DEFAULT, 10)
+		qResponse.Message = errorMessage(getErr)
+		qResponse.Error = getErr.Error()
+
+		return qResponse, getErr
+	}
+
+	// Merge request fields into the stored record
+	if len(qRequest.Question) == 0 {
+		qRequest.Question = qt.Question
+	}
+
+	if len(qRequest.Category) == 0 {
+		qRequest.Category = qt.Category
+	}
+
+	// The answer and the correct options describe the same answer, a request changing one of
+	// them has the other one derived again by the validation
+	if len(qRequest.Answer) == 0 && qRequest.CorrectOptions == nil {
+		qRequest.Answer = qt.Answer
+		qRequest.CorrectOptions = qt.CorrectOptions
+	}
+
+	if qRequest.AlternateAnswers == nil {
+		qRequest.AlternateAnswers = qt.AlternateAnswers
+	}
+
+	if qRequest.Options == nil {
+		qRequest.Options = qt.Options
+	}
+
+	if qRequest.Shuffle == nil {
+		qRequest.Shuffle = &qt.Shuffle
+	}
+
+	if qRequest.Version == 0 {
+		qRequest.Version = qt.Version
+	}
+
+	// Only a patch setting the expiry has it validated, a stored expiry is kept as it is
+	validateErr := validateOptions(&qRequest)
+	if validateErr == nil {
+		if qRequest.TTL != 0 || qRequest.ExpiresAt != nil {
+			validateErr = validateExpiry(&qRequest)
+		} else {
+			qRequest.ExpiresAt = qt.ExpiresAt
+		}
+	}
+
+	if validateErr != nil {
+		return invalidQuestionResponse(qRequest, validateErr)
+	}
+
+	return m.update(ctx, qRequest)
+}
+
+// Delete removes a record, a version other than 0 must match the stored version
+func (m *Model) Delete(ctx context.Context, questionID string, version int64) (messages.QuestionResponse, error) {
+	ctx, span := tracing.Start(ctx, "Model.Delete", tracing.QuestionIDKey.String(questionID))
+	defer span.End()
+
+	dbModel := m.activeDBModel()
+	ctx, cancel := m.operationContext(ctx, m.cfgData.OperationTimeoutMS)
+	defer cancel()
+
+	rowsAffected, delErr := dbModel.Delete(ctx, questionID, version)
+
+	var qResponse messages.QuestionResponse
+
+	// Update timestamp
+	qResponse.Timestamp = common.GetFormattedTime(time.Now(), "Mon Jan 2 15:04:05 2006")
+
+	qResponse.QuestionID = questionID
+
 	if delErr != nil {
-		// Display a log message
-		errMsg := "Error updating record: " + delErr.Error()
-		log.Printf(errMsg)
+		delErr = operationError(ctx, "Error deleting record", delErr)
 
+		// Update response fields
+		qResponse.RecordsAffected = strconv.FormatInt(messages.RESULTS_DEFAULT, 10)
+		qResponse.Message = errorMessage(delErr)
 		qResponse.Error = delErr.Error()
 
-		// Update response fields
-		qResponse.Error = errMsg
+		return qResponse, delErr
+	}
 
-		return qResponse, errors.New(errMsg)
+	// Build QuestionResponse
+	qResponse.RecordsAffected = strconv.FormatInt(rowsAffected, 10)
+	qResponse.Message = "Question with QuestionID = " + questionID + " has been deleted"
 
-	} else {
-		qResponse.Message = "Question with QuestionID = " + questionID + " has been deleted"
+	return qResponse, nil
+}
+
+// Reap removes the expired records that the datastore does not remove by itself
+func (m *Model) Reap(ctx context.Context) (int64, error) {
+	ctx, span := tracing.Start(ctx, "Model.Reap")
+	defer span.End()
+
+	dbModel := m.activeDBModel()
+	ctx, cancel := m.operationContext(ctx, m.cfgData.BatchTimeoutMS)
+	defer cancel()
+
+	rowsAffected, reapErr := dbModel.Reap(ctx)
+	if reapErr != nil {
+		return rowsAffected, operationError(ctx, "Error removing expired records", reapErr)
 	}
 
-	return qResponse, nil
+	if rowsAffected > 0 {
+		log.Print("expired records removed: ", rowsAffected)
+	}
+
+	return rowsAffected, nil
 }
 
+// Close releases the connections held by the datastore driver
+func (m *Model) Close() error {
+	m.dbModelMutex.Lock()
+	defer m.dbModelMutex.Unlock()
+
+	if m.dbModel == nil {
+		return nil
+	}
+
+	closeErr := m.dbModel.Close()
+	if closeErr != nil {
+		return operationError(context.Background(), "Error closing datastore", closeErr)
+	}
+
+	return nil
+}
+
+// NewDBModel creates the driver of the active datastore, its operations are recorded in the metrics.
+// A driver that failed to be created is a typed nil, it is not wrapped so the model stays nil.
 func (m *Model) NewDBModel(activeDriver string) messages.IDBModel {
 	if m.dbModel == nil {
 		switch activeDriver {
 		case config.GOCACHE_DRIVER:
-			return gocache.GetGoCacheModel(m.cfgData)
+			if dbModel := gocache.GetGoCacheModel(m.cfgData); dbModel != nil {
+				return instrument(activeDriver, dbModel)
+			}
 		case config.REDIS_DRIVER:
-			return goredis.GetRedisModel(m.cfgData)
+			if dbModel := goredis.GetRedisModel(m.cfgData); dbModel != nil {
+				return instrument(activeDriver, dbModel)
+			}
 		case config.POSTGRESQL_DRIVER:
-			return dspostgresql.GetPostGreSQLModel(m.cfgData)
+			if dbModel := dspostgresql.GetPostGreSQLModel(m.cfgData); dbModel != nil {
+				return instrument(activeDriver, dbModel)
+			}
+		case config.MYSQL_DRIVER:
+			if dbModel := dsmysql.GetMySQLModel(m.cfgData); dbModel != nil {
+				return instrument(activeDriver, dbModel)
+			}
+		case config.SQLITE_DRIVER:
+			if dbModel := dssqlite.GetSQLiteModel(m.cfgData); dbModel != nil {
+				return instrument(activeDriver, dbModel)
+			}
 		default:
 			log.Print("Unsupported database driver, active driver: ", activeDriver)
 		}
@@ -215,5 +957,8 @@ func New() *Model {
 		return nil
 	}
 
+	// Answer matcher
+	model.answerMatcher = matcher.New(model.cfgData)
+
 	return model
 }
@@ -1,34 +1,50 @@
 package models
 
 import (
+	"context"
+	"errors"
 	"log"
 	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
 	"github.com/sflewis2970/datastore-service/config"
+	"github.com/sflewis2970/datastore-service/metrics"
+	"github.com/sflewis2970/datastore-service/models/gocache"
 	"github.com/sflewis2970/datastore-service/models/messages"
 )
 
+// The SQLite database file used by the driver tests
+var sqliteTestPath string = filepath.Join(os.TempDir(), "datastore-service-test.db")
+
 func checkDBDriver(t *testing.T, driverName string, gotDBModel messages.IDBModel) {
 	if gotDBModel == nil {
 		t.Errorf("NewDBModel(%v): returned an invalid object", gotDBModel)
 		return
 	}
 
+	ctx := context.Background()
+
 	// Test insert question
 	var qRequest messages.QuestionRequest
 	qRequest.QuestionID = "aaaaqqqq"
 	qRequest.Question = "What is 4 / 2?"
 	qRequest.Answer = "2"
 
-	_, insertErr := gotDBModel.Insert(qRequest)
+	_, insertErr := gotDBModel.Insert(ctx, qRequest)
 	if insertErr != nil {
 		t.Error("Error inserting new record...")
 		return
 	}
 
 	// Test get question
-	qt, getErr := gotDBModel.Get(qRequest.QuestionID)
+	qt, getErr := gotDBModel.Get(ctx, qRequest.QuestionID)
 	if getErr != nil {
 		t.Error("Error retrieving record...")
 		return
@@ -46,28 +62,382 @@ func checkDBDriver(t *testing.T, driverName string, gotDBModel messages.IDBModel
 
 	// Test update question
 	qRequest.Category = "general"
-	updateRowsAffected, updateErr := gotDBModel.Update(qRequest)
+	updateRowsAffected, updateVersion, updateErr := gotDBModel.Update(ctx, qRequest)
 	if updateErr != nil {
 		t.Error("Error updating existing record...")
 		return
 	}
 
+	if updateVersion != qt.Version+1 {
+		t.Errorf("Unexpected version returned by an unconditional update: %d", updateVersion)
+	}
+
 	if driverName != config.GOCACHE_DRIVER && updateRowsAffected == 0 {
 		t.Error("No rows affected when attempting to update existing record...")
 		return
 	}
 
+	// Test multiple-choice fields are stored
+	qRequest.Options = messages.StringList{"1", "2", "3"}
+	qRequest.CorrectOptions = messages.IntList{1}
+	shuffle := true
+	qRequest.Shuffle = &shuffle
+	_, _, updateErr = gotDBModel.Update(ctx, qRequest)
+	if updateErr != nil {
+		t.Error("Error updating existing record...")
+		return
+	}
+
+	qt, getErr = gotDBModel.Get(ctx, qRequest.QuestionID)
+	if getErr != nil {
+		t.Error("Error retrieving record...")
+		return
+	}
+
+	if len(qt.Options) != len(qRequest.Options) || len(qt.CorrectOptions) != 1 || qt.CorrectOptions[0] != 1 || !qt.Shuffle {
+		t.Errorf("Error request options do NOT match retrieved options: %v %v %t", qt.Options, qt.CorrectOptions, qt.Shuffle)
+		return
+	}
+
 	// Test delete question
-	deletedRowsAffected, deleteErr := gotDBModel.Delete(qRequest.QuestionID)
+	deletedRowsAffected, deleteErr := gotDBModel.Delete(ctx, qRequest.QuestionID, 0)
 	if deleteErr != nil {
 		t.Error("Error deleting record...")
 	}
 
-	if driverName != config.GOCACHE_DRIVER && deletedRowsAffected == 0 {
+	if deletedRowsAffected == 0 {
 		t.Error("No rows affected when attempting to delete existing record...")
 		return
 	}
 
+	// Test consume question, only one of the concurrent consumers receives the question
+	_, insertErr = gotDBModel.Insert(ctx, qRequest)
+	if insertErr != nil {
+		t.Error("Error inserting new record...")
+		return
+	}
+
+	const consumers int = 8
+	var consumed int32
+	var wg sync.WaitGroup
+	for idx := 0; idx < consumers; idx++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			qt, consumeErr := gotDBModel.Consume(ctx, qRequest.QuestionID)
+			if errors.Is(consumeErr, messages.ErrNotFound) {
+				return
+			} else if consumeErr != nil {
+				t.Error("Error consuming record...")
+				return
+			}
+
+			if len(qt.Question) > 0 {
+				atomic.AddInt32(&consumed, 1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if consumed != 1 {
+		t.Errorf("Record consumed %d times, expected exactly once...", consumed)
+	}
+
+	qt, getErr = gotDBModel.Get(ctx, qRequest.QuestionID)
+	if !errors.Is(getErr, messages.ErrNotFound) || len(qt.Question) > 0 {
+		t.Errorf("Consumed record is still stored: %v, %v", qt, getErr)
+	}
+
+	// Test concurrent deletes, only one of them deletes the question
+	_, insertErr = gotDBModel.Insert(ctx, qRequest)
+	if insertErr != nil {
+		t.Error("Error inserting new record...")
+		return
+	}
+
+	var deleted int32
+	for idx := 0; idx < consumers; idx++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			rowsAffected, deleteErr := gotDBModel.Delete(ctx, qRequest.QuestionID, 0)
+			if errors.Is(deleteErr, messages.ErrNotFound) {
+				return
+			} else if deleteErr != nil {
+				t.Error("Error deleting record...")
+				return
+			}
+
+			if rowsAffected > 0 {
+				atomic.AddInt32(&deleted, 1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if deleted != 1 {
+		t.Errorf("Record deleted %d times, expected exactly once...", deleted)
+	}
+
+	// Test batch insert
+	qRequests := []messages.QuestionRequest{
+		{QuestionID: "aaaatttt", Question: "What is 4 + 4?", Category: "math", Answer: "8"},
+		{QuestionID: "aaaarrrr", Question: "What is 2 + 2?", Category: "math", Answer: "4"},
+		{QuestionID: "aaaassss", Question: "What is the chemical symbol of gold?", Category: "science", Answer: "Au"},
+	}
+
+	itemErrs, batchErr := gotDBModel.InsertBatch(ctx, qRequests)
+	if batchErr != nil || len(itemErrs) != len(qRequests) {
+		t.Error("Error inserting batch of records...")
+		return
+	}
+
+	for idx, qRequest := range qRequests {
+		if itemErrs[idx] != nil {
+			t.Errorf("Error inserting record %s: %s", qRequest.QuestionID, itemErrs[idx])
+		}
+
+		qt, getErr = gotDBModel.Get(ctx, qRequest.QuestionID)
+		if getErr != nil || qt.Answer != qRequest.Answer {
+			t.Errorf("Batch record %s was not stored...", qRequest.QuestionID)
+		}
+	}
+
+	// Stored records are not overwritten
+	itemErrs, batchErr = gotDBModel.InsertBatch(ctx, qRequests[:1])
+	if batchErr != nil || len(itemErrs) != 1 || !errors.Is(itemErrs[0], messages.ErrRecordExists) {
+		t.Errorf("Stored record was not reported as existing: %v, %v", itemErrs, batchErr)
+	}
+
+	// Test list questions in question ID order
+	qRecords, listErr := gotDBModel.List(ctx, messages.ListRequest{Limit: 2})
+	if listErr != nil || len(qRecords) != 2 || qRecords[0].QuestionID != "aaaarrrr" || qRecords[1].QuestionID != "aaaassss" {
+		t.Errorf("Unexpected first page: %v, %v", qRecords, listErr)
+	}
+
+	qRecords, listErr = gotDBModel.List(ctx, messages.ListRequest{After: "aaaassss", Limit: 2})
+	if listErr != nil || len(qRecords) != 1 || qRecords[0].QuestionID != "aaaatttt" || qRecords[0].Answer != "8" {
+		t.Errorf("Unexpected second page: %v, %v", qRecords, listErr)
+	}
+
+	qRecords, listErr = gotDBModel.List(ctx, messages.ListRequest{Category: "math", Limit: 10})
+	if listErr != nil || len(qRecords) != 2 || qRecords[0].QuestionID != "aaaarrrr" || qRecords[1].QuestionID != "aaaatttt" {
+		t.Errorf("Unexpected category page: %v, %v", qRecords, listErr)
+	}
+
+	// Test draw questions, a drawn question is consumed
+	qRecord, drawErr := gotDBModel.Draw(ctx, messages.DrawRequest{Category: "math", Exclude: []string{"aaaarrrr"}})
+	if drawErr != nil || qRecord.QuestionID != "aaaatttt" || qRecord.Answer != "8" {
+		t.Errorf("Unexpected question drawn: %v, %v", qRecord, drawErr)
+	}
+
+	qRecord, drawErr = gotDBModel.Draw(ctx, messages.DrawRequest{Category: "math", Exclude: []string{"aaaarrrr"}})
+	if !errors.Is(drawErr, messages.ErrNotFound) || len(qRecord.QuestionID) > 0 {
+		t.Errorf("Unexpected question drawn: %v, %v", qRecord, drawErr)
+	}
+
+	qRecord, drawErr = gotDBModel.Draw(ctx, messages.DrawRequest{Category: "science"})
+	if drawErr != nil || qRecord.QuestionID != "aaaassss" {
+		t.Errorf("Unexpected question drawn: %v, %v", qRecord, drawErr)
+	}
+
+	for _, qRequest := range qRequests {
+		gotDBModel.Delete(ctx, qRequest.QuestionID, 0)
+	}
+
+	// Test concurrent draws, every question is drawn once and none is missed
+	drawRequests := make([]messages.QuestionRequest, consumers)
+	for idx := range drawRequests {
+		drawRequests[idx] = messages.QuestionRequest{QuestionID: "aaaadrw" + string(rune('a'+idx)), Question: "What is 5 - 5?", Category: "draw", Answer: "0"}
+	}
+	gotDBModel.InsertBatch(ctx, drawRequests)
+
+	var drawnMutex sync.Mutex
+	drawn := make(map[string]bool)
+	for idx := 0; idx < consumers; idx++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			qRecord, drawErr := gotDBModel.Draw(ctx, messages.DrawRequest{Category: "draw"})
+			if drawErr != nil {
+				t.Errorf("Error drawing record while questions remain: %v", drawErr)
+				return
+			}
+
+			drawnMutex.Lock()
+			defer drawnMutex.Unlock()
+			if drawn[qRecord.QuestionID] {
+				t.Errorf("Record %s drawn twice...", qRecord.QuestionID)
+			}
+			drawn[qRecord.QuestionID] = true
+		}()
+	}
+	wg.Wait()
+
+	qRecord, drawErr = gotDBModel.Draw(ctx, messages.DrawRequest{Category: "draw"})
+	if len(drawn) != consumers || !errors.Is(drawErr, messages.ErrNotFound) {
+		t.Errorf("Unexpected draws: %d drawn, then %v, %v", len(drawn), qRecord, drawErr)
+	}
+
+	// Test expiry, the expiry time is stored and an expired question is no longer returned
+	expiresAt := time.Now().Add(time.Hour).UTC().Truncate(time.Millisecond)
+	qRequest = messages.QuestionRequest{QuestionID: "aaaaeeee", Question: "What is 3 * 3?", Answer: "9", ExpiresAt: &expiresAt}
+	gotDBModel.Insert(ctx, qRequest)
+
+	qt, getErr = gotDBModel.Get(ctx, qRequest.QuestionID)
+	if getErr != nil || qt.ExpiresAt == nil || !qt.ExpiresAt.Equal(expiresAt) {
+		t.Errorf("Unexpected expiry time: %v, %v", qt.ExpiresAt, getErr)
+	}
+
+	expiresAt = time.Now().Add(100 * time.Millisecond).UTC().Truncate(time.Millisecond)
+	gotDBModel.Update(ctx, qRequest)
+	time.Sleep(200 * time.Millisecond)
+
+	qt, getErr = gotDBModel.Get(ctx, qRequest.QuestionID)
+	if !errors.Is(getErr, messages.ErrNotFound) || len(qt.Question) > 0 {
+		t.Errorf("Expired question returned: %v, %v", qt, getErr)
+	}
+
+	_, reapErr := gotDBModel.Reap(ctx)
+	if reapErr != nil {
+		t.Error("Error removing expired records: ", reapErr)
+	}
+
+	gotDBModel.Delete(ctx, qRequest.QuestionID, 0)
+
+	// Test lease, a checked out question cannot be consumed until the lease is confirmed
+	qRequest = messages.QuestionRequest{QuestionID: "aaaallll", Question: "What is 7 - 5?", Answer: "2"}
+	gotDBModel.Insert(ctx, qRequest)
+
+	qt, leaseErr := gotDBModel.Lease(ctx, qRequest.QuestionID, "lease-1", time.Now().Add(time.Hour))
+	if leaseErr != nil || qt.Question != qRequest.Question {
+		t.Errorf("Unexpected leased question: %v, %v", qt, leaseErr)
+	}
+
+	qt, leaseErr = gotDBModel.Lease(ctx, qRequest.QuestionID, "lease-2", time.Now().Add(time.Hour))
+	if !errors.Is(leaseErr, messages.ErrNotFound) || len(qt.Question) > 0 {
+		t.Errorf("Question leased twice: %v, %v", qt, leaseErr)
+	}
+
+	qt, consumeErr := gotDBModel.Consume(ctx, qRequest.QuestionID)
+	if !errors.Is(consumeErr, messages.ErrNotFound) || len(qt.Question) > 0 {
+		t.Errorf("Leased question consumed: %v, %v", qt, consumeErr)
+	}
+
+	qRecord, drawErr = gotDBModel.Draw(ctx, messages.DrawRequest{})
+	if !errors.Is(drawErr, messages.ErrNotFound) || len(qRecord.QuestionID) > 0 {
+		t.Errorf("Leased question drawn: %v, %v", qRecord, drawErr)
+	}
+
+	qt, confirmErr := gotDBModel.Confirm(ctx, qRequest.QuestionID, "lease-2")
+	if !errors.Is(confirmErr, messages.ErrNotFound) || len(qt.Question) > 0 {
+		t.Errorf("Lease confirmed with the wrong token: %v, %v", qt, confirmErr)
+	}
+
+	qt, confirmErr = gotDBModel.Confirm(ctx, qRequest.QuestionID, "lease-1")
+	if confirmErr != nil || qt.Question != qRequest.Question {
+		t.Errorf("Unexpected confirmed question: %v, %v", qt, confirmErr)
+	}
+
+	qt, getErr = gotDBModel.Get(ctx, qRequest.QuestionID)
+	if !errors.Is(getErr, messages.ErrNotFound) || len(qt.Question) > 0 {
+		t.Errorf("Confirmed question not consumed: %v, %v", qt, getErr)
+	}
+
+	// Test lease expiry, the question returns to the pool
+	gotDBModel.Insert(ctx, qRequest)
+	gotDBModel.Lease(ctx, qRequest.QuestionID, "lease-3", time.Now().Add(100*time.Millisecond))
+	time.Sleep(200 * time.Millisecond)
+
+	qt, confirmErr = gotDBModel.Confirm(ctx, qRequest.QuestionID, "lease-3")
+	if !errors.Is(confirmErr, messages.ErrNotFound) || len(qt.Question) > 0 {
+		t.Errorf("Expired lease confirmed: %v, %v", qt, confirmErr)
+	}
+
+	qt, consumeErr = gotDBModel.Consume(ctx, qRequest.QuestionID)
+	if consumeErr != nil || qt.Question != qRequest.Question {
+		t.Errorf("Question not returned to the pool: %v, %v", qt, consumeErr)
+	}
+
+	// Test versions, a conditional write only succeeds at the stored version
+	qRequest = messages.QuestionRequest{QuestionID: "aaaavvvv", Question: "What is 8 / 4?", Answer: "2"}
+	gotDBModel.Insert(ctx, qRequest)
+
+	qt, getErr = gotDBModel.Get(ctx, qRequest.QuestionID)
+	if getErr != nil || qt.Version != messages.INITIAL_VERSION {
+		t.Errorf("Unexpected initial version: %d, %v", qt.Version, getErr)
+	}
+
+	qRequest.Version = messages.INITIAL_VERSION + 1
+	updateRowsAffected, _, updateErr = gotDBModel.Update(ctx, qRequest)
+	if !errors.Is(updateErr, messages.ErrVersionMismatch) || updateRowsAffected > 0 {
+		t.Errorf("Update applied at another version: %d, %v", updateRowsAffected, updateErr)
+	}
+
+	qRequest.Version = messages.INITIAL_VERSION
+	updateRowsAffected, updateVersion, updateErr = gotDBModel.Update(ctx, qRequest)
+	if updateErr != nil || updateRowsAffected != 1 || updateVersion != messages.INITIAL_VERSION+1 {
+		t.Errorf("Update not applied at the stored version: %d, %d, %v", updateRowsAffected, updateVersion, updateErr)
+	}
+
+	qt, getErr = gotDBModel.Get(ctx, qRequest.QuestionID)
+	if getErr != nil || qt.Version != messages.INITIAL_VERSION+1 {
+		t.Errorf("Version not incremented: %d, %v", qt.Version, getErr)
+	}
+
+	deleteRowsAffected, deleteErr := gotDBModel.Delete(ctx, qRequest.QuestionID, messages.INITIAL_VERSION)
+	if !errors.Is(deleteErr, messages.ErrVersionMismatch) || deleteRowsAffected > 0 {
+		t.Errorf("Delete applied at another version: %d, %v", deleteRowsAffected, deleteErr)
+	}
+
+	deleteRowsAffected, deleteErr = gotDBModel.Delete(ctx, qRequest.QuestionID, messages.INITIAL_VERSION+1)
+	if deleteErr != nil || deleteRowsAffected != 1 {
+		t.Errorf("Delete not applied at the stored version: %d, %v", deleteRowsAffected, deleteErr)
+	}
+
+	updateRowsAffected, _, updateErr = gotDBModel.Update(ctx, qRequest)
+	if !errors.Is(updateErr, messages.ErrNotFound) || updateRowsAffected > 0 {
+		t.Errorf("Deleted record updated: %d, %v", updateRowsAffected, updateErr)
+	}
+
+	// Test insert is create-only, an upsert overwrites the stored question
+	qRequest = messages.QuestionRequest{QuestionID: "aaaauuuu", Question: "What is 9 / 3?", Answer: "3"}
+	_, insertErr = gotDBModel.Insert(ctx, qRequest)
+	if insertErr != nil {
+		t.Errorf("Error inserting new record: %v", insertErr)
+	}
+
+	qRequest.Answer = "three"
+	_, insertErr = gotDBModel.Insert(ctx, qRequest)
+	if !errors.Is(insertErr, messages.ErrRecordExists) {
+		t.Errorf("Stored record was not reported as existing: %v", insertErr)
+	}
+
+	qt, getErr = gotDBModel.Get(ctx, qRequest.QuestionID)
+	if getErr != nil || qt.Answer != "3" {
+		t.Errorf("Stored record was overwritten by insert: %s, %v", qt.Answer, getErr)
+	}
+
+	_, upsertErr := gotDBModel.Upsert(ctx, qRequest)
+	if upsertErr != nil {
+		t.Errorf("Error overwriting stored record: %v", upsertErr)
+	}
+
+	qt, getErr = gotDBModel.Get(ctx, qRequest.QuestionID)
+	if getErr != nil || qt.Answer != "three" || qt.Version != messages.INITIAL_VERSION+1 {
+		t.Errorf("Stored record was not overwritten by upsert: %s, %d, %v", qt.Answer, qt.Version, getErr)
+	}
+
+	// An upsert of a new question inserts it
+	qRequest = messages.QuestionRequest{QuestionID: "aaaawwww", Question: "What is 6 / 3?", Answer: "2"}
+	_, upsertErr = gotDBModel.Upsert(ctx, qRequest)
+	qt, getErr = gotDBModel.Get(ctx, qRequest.QuestionID)
+	if upsertErr != nil || getErr != nil || qt.Answer != "2" || qt.Version != messages.INITIAL_VERSION {
+		t.Errorf("New record was not inserted by upsert: %s, %d, %v, %v", qt.Answer, qt.Version, upsertErr, getErr)
+	}
 }
 
 func checkInvalidDriver(t *testing.T, driverName string, gotDBModel messages.IDBModel) {
@@ -133,6 +503,18 @@ func setConfigEnv(driverName string) error {
 			log.Print("Error setting config vars...")
 			return setErr
 		}
+	case config.MYSQL_DRIVER:
+		setErr = os.Setenv(config.MYSQL_CONNECTION, "root:devStation@tcp(127.0.0.1:3306)/")
+		if setErr != nil {
+			log.Print("Error setting config vars...")
+			return setErr
+		}
+	case config.SQLITE_DRIVER:
+		setErr = os.Setenv(config.SQLITE_PATH, sqliteTestPath)
+		if setErr != nil {
+			log.Print("Error setting config vars...")
+			return setErr
+		}
 	}
 
 	return nil
@@ -145,6 +527,10 @@ func TestNewSDBModel(t *testing.T) {
 	// new model
 	model := New()
 
+	// Start the SQLite driver test from an empty database file
+	os.Remove(sqliteTestPath)
+	defer os.Remove(sqliteTestPath)
+
 	// Test cases
 	testCases := []struct {
 		testName   string
@@ -154,6 +540,8 @@ func TestNewSDBModel(t *testing.T) {
 		{testName: "GoCache driver test", testActive: true, driverName: config.GOCACHE_DRIVER},
 		{testName: "Redis driver test", testActive: false, driverName: config.REDIS_DRIVER},
 		{testName: "PostgreSQL driver test", testActive: false, driverName: config.POSTGRESQL_DRIVER},
+		{testName: "MySQL driver test", testActive: false, driverName: config.MYSQL_DRIVER},
+		{testName: "SQLite driver test", testActive: true, driverName: config.SQLITE_DRIVER},
 		{testName: "No driver test", testActive: true, driverName: ""},
 		{testName: "Bad driver test", testActive: true, driverName: "baddrivername"},
 	}
@@ -176,6 +564,10 @@ func TestNewSDBModel(t *testing.T) {
 			case config.REDIS_DRIVER:
 				fallthrough
 			case config.POSTGRESQL_DRIVER:
+				fallthrough
+			case config.MYSQL_DRIVER:
+				fallthrough
+			case config.SQLITE_DRIVER:
 				if tc.testActive {
 					checkDBDriver(t, tc.driverName, gotDBModel)
 				}
@@ -187,3 +579,141 @@ func TestNewSDBModel(t *testing.T) {
 		})
 	}
 }
+
+// Driver whose reads only return once their context is done, as a datastore that stopped
+// answering. The error returned does not tell that the deadline was exceeded.
+type stalledDBModel struct {
+	messages.IDBModel
+}
+
+func (dbm stalledDBModel) Get(ctx context.Context, questionID string) (messages.QuestionTable, error) {
+	<-ctx.Done()
+	return messages.QuestionTable{}, errors.New("interrupted")
+}
+
+func TestOperationTimeout(t *testing.T) {
+	model := &Model{cfgData: &config.ConfigData{OperationTimeoutMS: 50}, dbModel: stalledDBModel{}}
+
+	start := time.Now()
+	_, getErr := model.Get(context.Background(), messages.AnswerRequest{QuestionID: "aaaaqqqq", Peek: true})
+	if !errors.Is(getErr, messages.ErrTimeout) {
+		t.Errorf("Get: got error %v, want a timeout", getErr)
+	}
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Get: returned after %v, the operation timeout is 50ms", elapsed)
+	}
+
+	// A request that ends before the deadline ends the operation as well
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	model.cfgData.OperationTimeoutMS = 60000
+	_, getErr = model.Get(ctx, messages.AnswerRequest{QuestionID: "aaaaqqqq", Peek: true})
+	if getErr == nil || errors.Is(getErr, messages.ErrTimeout) {
+		t.Errorf("Get: got error %v, want the error of the canceled request", getErr)
+	}
+}
+
+// Driver returning records whose expiry passed, as a datastore where they are not removed yet
+type expiredDBModel struct {
+	messages.IDBModel
+}
+
+func (dbm expiredDBModel) Get(ctx context.Context, questionID string) (messages.QuestionTable, error) {
+	qt, getErr := dbm.IDBModel.Get(ctx, questionID)
+
+	expiresAt := time.Now().Add(-time.Minute).UTC()
+	qt.ExpiresAt = &expiresAt
+
+	return qt, getErr
+}
+
+func TestPatch(t *testing.T) {
+	setConfigEnv(config.GOCACHE_DRIVER)
+
+	cfgData, getCfgDataErr := config.Get().GetData(config.REFRESH_CONFIG_DATA)
+	if getCfgDataErr != nil {
+		t.Errorf("Error getting config data...")
+		return
+	}
+
+	ctx := context.Background()
+	dbModel := gocache.GetGoCacheModel(cfgData)
+	model := &Model{cfgData: cfgData, dbModel: dbModel}
+
+	shuffle := true
+	qRequest := messages.QuestionRequest{QuestionID: "aaaapppp", Question: "Which planet is known as the red planet?", Options: messages.StringList{"Venus", "Mars"}, Answer: "Mars", Shuffle: &shuffle}
+	_, insertErr := model.Insert(ctx, qRequest)
+	if insertErr != nil {
+		t.Fatalf("Insert: %v", insertErr)
+	}
+
+	// A patch can turn off the shuffle
+	shuffle = false
+	_, patchErr := model.Patch(ctx, messages.QuestionRequest{QuestionID: "aaaapppp", Shuffle: &shuffle})
+	if patchErr != nil {
+		t.Errorf("Patch of the shuffle: %v", patchErr)
+	}
+
+	qt, getErr := dbModel.Get(ctx, "aaaapppp")
+	if getErr != nil || qt.Shuffle || qt.Answer != "Mars" {
+		t.Errorf("Patched record: got %+v, %v", qt, getErr)
+	}
+
+	// A patch setting an expiry that passed is rejected
+	expiresAt := time.Now().Add(-time.Second)
+	_, patchErr = model.Patch(ctx, messages.QuestionRequest{QuestionID: "aaaapppp", ExpiresAt: &expiresAt})
+	if !errors.Is(patchErr, messages.ErrInvalid) {
+		t.Errorf("Patch of a past expiry: got error %v, want an invalid request", patchErr)
+	}
+
+	// A patch that does not set the expiry keeps the stored one, even when it already passed
+	model.SetDBModel(expiredDBModel{dbModel})
+	_, patchErr = model.Patch(ctx, messages.QuestionRequest{QuestionID: "aaaapppp", Category: "science"})
+	if patchErr != nil {
+		t.Errorf("Patch of the category of an expired record: %v", patchErr)
+	}
+}
+
+// Number of latency samples recorded for an operation of a driver
+func operationCount(driverName string, operation string) uint64 {
+	var metric dto.Metric
+	metrics.OperationDuration.WithLabelValues(driverName, operation).(prometheus.Histogram).Write(&metric)
+
+	return metric.GetHistogram().GetSampleCount()
+}
+
+func TestOperationMetrics(t *testing.T) {
+	setConfigEnv(config.GOCACHE_DRIVER)
+
+	cfgData, getCfgDataErr := config.Get().GetData(config.REFRESH_CONFIG_DATA)
+	if getCfgDataErr != nil {
+		t.Errorf("Error getting config data...")
+		return
+	}
+
+	model := &Model{cfgData: cfgData}
+	gotDBModel := model.NewDBModel(config.GOCACHE_DRIVER)
+
+	ctx := context.Background()
+	getCount := operationCount(config.GOCACHE_DRIVER, "get")
+	notFound := testutil.ToFloat64(metrics.OperationErrors.WithLabelValues(config.GOCACHE_DRIVER, "get", messages.NOT_FOUND_CODE))
+
+	// Every operation is recorded, a failed one is counted by its error code
+	gotDBModel.Insert(ctx, messages.QuestionRequest{QuestionID: "metrics1", Question: "What is 1 + 1?", Answer: "2"})
+	gotDBModel.Get(ctx, "metrics1")
+	gotDBModel.Get(ctx, "metrics2")
+
+	if got := testutil.ToFloat64(metrics.OperationErrors.WithLabelValues(config.GOCACHE_DRIVER, "get", messages.NOT_FOUND_CODE)); got != notFound+1 {
+		t.Errorf("not_found get errors: got %v, want %v", got, notFound+1)
+	}
+
+	if got := operationCount(config.GOCACHE_DRIVER, "get"); got != getCount+2 {
+		t.Errorf("get latency samples: got %d, want %d", got, getCount+2)
+	}
+
+	if testutil.ToFloat64(metrics.OperationErrors.WithLabelValues(config.GOCACHE_DRIVER, "insert", messages.NOT_FOUND_CODE)) != 0 {
+		t.Errorf("A successful insert was counted as failed")
+	}
+}
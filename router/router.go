@@ -2,13 +2,17 @@ package router
 
 import (
 	"log"
+	"net/http"
 
 	"github.com/gorilla/mux"
 	"github.com/sflewis2970/datastore-service/controllers"
+	"github.com/sflewis2970/datastore-service/metrics"
+	"github.com/sflewis2970/datastore-service/tracing"
 )
 
 type MessageRouter struct {
 	MuxRouter *mux.Router
+	Handler   http.Handler
 }
 
 var msgRouter *MessageRouter
@@ -17,10 +21,36 @@ func (rs *MessageRouter) setupRoutes() {
 	// Display log message
 	log.Print("Setting up Datastore service routes")
 
-	// Setup routes
+	// Setup probe and metrics routes
+	rs.MuxRouter.HandleFunc("/healthz", controllers.Healthz).Methods("GET")
+	rs.MuxRouter.HandleFunc("/readyz", controllers.Readyz).Methods("GET")
+	rs.MuxRouter.Handle("/metrics", metrics.Handler()).Methods("GET")
+
+	// Setup v1 routes
 	rs.MuxRouter.HandleFunc("/api/v1/ds/status", controllers.Status).Methods("GET")
 	rs.MuxRouter.HandleFunc("/api/v1/ds/insert", controllers.Insert).Methods("POST")
+	rs.MuxRouter.HandleFunc("/api/v1/ds/insertbatch", controllers.InsertBatch).Methods("POST")
 	rs.MuxRouter.HandleFunc("/api/v1/ds/get", controllers.Get).Methods("POST")
+	rs.MuxRouter.HandleFunc("/api/v1/ds/list", controllers.List).Methods("GET")
+	rs.MuxRouter.HandleFunc("/api/v1/ds/draw", controllers.Draw).Methods("POST")
+	rs.MuxRouter.HandleFunc("/api/v1/ds/checkanswer", controllers.CheckAnswer).Methods("POST")
+	rs.MuxRouter.HandleFunc("/api/v1/ds/update", controllers.Update).Methods("PUT")
+	rs.MuxRouter.HandleFunc("/api/v1/ds/update", controllers.Patch).Methods("PATCH")
+	rs.MuxRouter.HandleFunc("/api/v1/ds/delete", controllers.Delete).Methods("DELETE")
+
+	// Setup v2 routes
+	v2Router := rs.MuxRouter.PathPrefix("/api/v2").Subrouter()
+	v2Router.HandleFunc("/questions", controllers.List).Methods("GET")
+	v2Router.HandleFunc("/questions", controllers.CreateQuestion).Methods("POST")
+	v2Router.HandleFunc("/questions/batch", controllers.CreateQuestions).Methods("POST")
+	v2Router.HandleFunc("/questions/draw", controllers.Draw).Methods("POST")
+	v2Router.HandleFunc("/questions/{id}", controllers.GetQuestion).Methods("GET")
+	v2Router.HandleFunc("/questions/{id}", controllers.ReplaceQuestion).Methods("PUT")
+	v2Router.HandleFunc("/questions/{id}", controllers.PatchQuestion).Methods("PATCH")
+	v2Router.HandleFunc("/questions/{id}", controllers.DeleteQuestion).Methods("DELETE")
+	v2Router.HandleFunc("/questions/{id}/consume", controllers.ConsumeQuestion).Methods("POST")
+	v2Router.HandleFunc("/questions/{id}/checkout", controllers.CheckoutQuestion).Methods("POST")
+	v2Router.HandleFunc("/questions/{id}/answer", controllers.AnswerQuestion).Methods("POST")
 }
 
 func New() *MessageRouter {
@@ -32,5 +62,10 @@ func New() *MessageRouter {
 	// Setting up routes
 	msgRouter.setupRoutes()
 
+	// Trace the requests, count them and record their latency, around the router so the requests
+	// no route matches are recorded too
+	metricsHandler := metrics.Middleware(msgRouter.MuxRouter, msgRouter.MuxRouter)
+	msgRouter.Handler = tracing.Middleware(msgRouter.MuxRouter, metricsHandler)
+
 	return msgRouter
 }
@@ -0,0 +1,168 @@
+package router
+
+import (
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/sflewis2970/datastore-service/common"
+	"github.com/sflewis2970/datastore-service/config"
+	"github.com/sflewis2970/datastore-service/controllers"
+	"github.com/sflewis2970/datastore-service/models/messages"
+	"github.com/sflewis2970/datastore-service/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func setConfigEnv() error {
+	// Set config environment variables of the go-cache driver
+	envVars := map[string]string{
+		config.HOST:               "",
+		config.PORT:               ":9090",
+		config.ACTIVEDRIVER:       config.GOCACHE_DRIVER,
+		config.DEFAULT_EXPIRATION: "1",
+		config.CLEANUP_INTERVAL:   "30",
+	}
+
+	for envVar, value := range envVars {
+		setErr := os.Setenv(envVar, value)
+		if setErr != nil {
+			log.Print("Error setting config vars...")
+			return setErr
+		}
+	}
+
+	return nil
+}
+
+func RouterTest(t *testing.T, handler http.Handler, method string, path string, expectedStatus int) *httptest.ResponseRecorder {
+	// Create new request
+	request := httptest.NewRequest(method, path, nil)
+
+	// Setup recoder
+	rRecorder := httptest.NewRecorder()
+	handler.ServeHTTP(rRecorder, request)
+
+	// Check response code
+	status := rRecorder.Code
+	if status != expectedStatus {
+		t.Errorf("handler returned invalid status code: got %d, expected: %d\n", status, expectedStatus)
+	}
+
+	return rRecorder
+}
+
+func TestRequestMetrics(t *testing.T) {
+	// Set config environment variables
+	setConfigEnv()
+
+	// Initialize controllers object
+	controllers.New(config.REFRESH_CONFIG_DATA)
+
+	// Create router
+	msgRouter := New()
+
+	// A request matching a route, a request matching no route and a request with a method no route allows
+	RouterTest(t, msgRouter.Handler, "GET", "/api/v2/questions/metrics1", http.StatusNotFound)
+	RouterTest(t, msgRouter.Handler, "GET", "/api/v2/unknown", http.StatusNotFound)
+	RouterTest(t, msgRouter.Handler, "DELETE", "/api/v1/ds/status", http.StatusMethodNotAllowed)
+
+	// Scrape the metrics
+	body := RouterTest(t, msgRouter.Handler, "GET", "/metrics", http.StatusOK).Body.String()
+
+	for _, series := range []string{
+		`datastore_http_requests_total{method="GET",route="/api/v2/questions/{id}",status="404"}`,
+		`datastore_http_requests_total{method="GET",route="unmatched",status="404"}`,
+		`datastore_http_requests_total{method="DELETE",route="unmatched",status="405"}`,
+	} {
+		if !strings.Contains(body, series) {
+			t.Errorf("Series %s not found in the metrics", series)
+		}
+	}
+}
+
+func TestRequestTracing(t *testing.T) {
+	// Set config environment variables
+	setConfigEnv()
+
+	// Initialize controllers object
+	controllers.New(config.REFRESH_CONFIG_DATA)
+
+	// Create router
+	msgRouter := New()
+
+	// Record the spans
+	spanRecorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spanRecorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	defer otel.SetTracerProvider(trace.NewNoopTracerProvider())
+
+	// Read a question that does not exist as part of the trace of the caller
+	request := httptest.NewRequest("GET", "/api/v2/questions/trace1", nil)
+	request.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+
+	rRecorder := httptest.NewRecorder()
+	msgRouter.Handler.ServeHTTP(rRecorder, request)
+	if rRecorder.Code != http.StatusNotFound {
+		t.Errorf("handler returned invalid status code: got %d, expected: %d\n", rRecorder.Code, http.StatusNotFound)
+	}
+
+	// A request matching no route is traced too
+	RouterTest(t, msgRouter.Handler, "GET", "/api/v2/unknown", http.StatusNotFound)
+
+	spans := make(map[string]sdktrace.ReadOnlySpan)
+	for _, span := range spanRecorder.Ended() {
+		if span.SpanContext().TraceID().String() == "4bf92f3577b34da6a3ce929d0e0e4736" {
+			spans[span.Name()] = span
+		}
+	}
+
+	// The spans of the request continue the trace of the caller
+	serverSpan, found := spans["GET /api/v2/questions/{id}"]
+	if !found || serverSpan.Parent().SpanID().String() != "00f067aa0ba902b7" {
+		t.Fatalf("Request span not found in the trace of the caller, spans: %v", spans)
+	}
+
+	for _, name := range []string{"question lock", "Model.Get", config.GOCACHE_DRIVER + ".get"} {
+		if _, found := spans[name]; !found {
+			t.Errorf("Span %s not found in the trace of the caller", name)
+		}
+	}
+
+	// The driver span is a child of the model span, and fails with the datastore error
+	driverSpan := spans[config.GOCACHE_DRIVER+".get"]
+	modelSpan := spans["Model.Get"]
+	if driverSpan == nil || modelSpan == nil || driverSpan.Parent().SpanID() != modelSpan.SpanContext().SpanID() {
+		t.Fatalf("Driver span is not a child of the model span")
+	}
+
+	if driverSpan.Status().Code != codes.Error || modelSpan.Status().Code != codes.Error {
+		t.Errorf("Failed read not recorded: driver span %v, model span %v", driverSpan.Status(), modelSpan.Status())
+	}
+
+	attrs := make(map[attribute.Key]attribute.Value)
+	for _, attr := range driverSpan.Attributes() {
+		attrs[attr.Key] = attr.Value
+	}
+
+	if attrs[tracing.DriverKey].AsString() != config.GOCACHE_DRIVER || attrs[tracing.QuestionIDKey].AsString() != "trace1" || attrs[tracing.ErrorCodeKey].AsString() != messages.NOT_FOUND_CODE {
+		t.Errorf("Driver span attributes: got %v", attrs)
+	}
+
+	unmatchedFound := false
+	for _, span := range spanRecorder.Ended() {
+		unmatchedFound = unmatchedFound || span.Name() == "GET "+common.UNMATCHED_ROUTE
+	}
+
+	if !unmatchedFound {
+		t.Errorf("Request span of the request matching no route not found")
+	}
+}
@@ -0,0 +1,181 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+
+	"github.com/gorilla/mux"
+	"github.com/sflewis2970/datastore-service/common"
+	"github.com/sflewis2970/datastore-service/config"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Name of the tracer and of the service in the exported spans
+const (
+	TRACER_NAME  string = "github.com/sflewis2970/datastore-service"
+	SERVICE_NAME string = "datastore-service"
+)
+
+// Span exporters
+const (
+	NONE_EXPORTER   string = "none"
+	STDOUT_EXPORTER string = "stdout"
+	FILE_EXPORTER   string = "file"
+)
+
+const UNKNOWN_EXPORTER_ERROR string = "unknown trace exporter: "
+
+// Attributes of the datastore spans
+var (
+	DriverKey       = attribute.Key("datastore.driver")
+	QuestionIDKey   = attribute.Key("datastore.key")
+	RowsAffectedKey = attribute.Key("datastore.rows_affected")
+	RecordsKey      = attribute.Key("datastore.records")
+	ErrorCodeKey    = attribute.Key("datastore.error_code")
+)
+
+// ExporterFunc creates the span exporter selected by the tracing settings
+type ExporterFunc func(tracingCfg config.Tracing) (sdktrace.SpanExporter, error)
+
+var exportersMutex sync.Mutex
+var exporters = map[string]ExporterFunc{
+	STDOUT_EXPORTER: newStdoutExporter,
+	FILE_EXPORTER:   newFileExporter,
+}
+
+// RegisterExporter makes an exporter available to the exporter setting, an exporter registered
+// with the name of another one replaces it
+func RegisterExporter(name string, newExporter ExporterFunc) {
+	exportersMutex.Lock()
+	defer exportersMutex.Unlock()
+
+	exporters[name] = newExporter
+}
+
+// Write the spans to stdout, one indented JSON object per span
+func newStdoutExporter(tracingCfg config.Tracing) (sdktrace.SpanExporter, error) {
+	return stdouttrace.New(stdouttrace.WithPrettyPrint())
+}
+
+// Span exporter closing the file it writes to once it is shut down
+type fileExporter struct {
+	sdktrace.SpanExporter
+	file *os.File
+}
+
+func (fe fileExporter) Shutdown(ctx context.Context) error {
+	shutdownErr := fe.SpanExporter.Shutdown(ctx)
+	closeErr := fe.file.Close()
+	if shutdownErr != nil {
+		return shutdownErr
+	}
+
+	return closeErr
+}
+
+// Append the spans to the trace file, one JSON object per line
+func newFileExporter(tracingCfg config.Tracing) (sdktrace.SpanExporter, error) {
+	file, openErr := os.OpenFile(tracingCfg.File, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if openErr != nil {
+		return nil, openErr
+	}
+
+	spanExporter, newErr := stdouttrace.New(stdouttrace.WithWriter(file))
+	if newErr != nil {
+		file.Close()
+		return nil, newErr
+	}
+
+	return fileExporter{SpanExporter: spanExporter, file: file}, nil
+}
+
+// Setup installs the tracer provider exporting the spans with the configured exporter, and the
+// W3C trace context propagation. The returned function flushes the spans left and stops the
+// exporter, it is called once the server stopped serving requests.
+func Setup(tracingCfg config.Tracing) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	if tracingCfg.Exporter == NONE_EXPORTER {
+		log.Print("Tracing spans are not exported")
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exportersMutex.Lock()
+	newExporter, found := exporters[tracingCfg.Exporter]
+	exportersMutex.Unlock()
+
+	if !found {
+		return nil, errors.New(UNKNOWN_EXPORTER_ERROR + tracingCfg.Exporter)
+	}
+
+	spanExporter, newErr := newExporter(tracingCfg)
+	if newErr != nil {
+		return nil, newErr
+	}
+
+	log.Printf("Exporting tracing spans, exporter: %s, sample ratio: %g", tracingCfg.Exporter, tracingCfg.SampleRatio)
+	tracerProvider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(spanExporter),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(SERVICE_NAME))),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(tracingCfg.SampleRatio))),
+	)
+	otel.SetTracerProvider(tracerProvider)
+
+	return tracerProvider.Shutdown, nil
+}
+
+// Start a span as a child of the span in the context
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(TRACER_NAME).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// SetError records the error on the span and marks the span failed, a nil error is ignored
+func SetError(span trace.Span, spanErr error) {
+	if spanErr != nil {
+		span.RecordError(spanErr)
+		span.SetStatus(codes.Error, spanErr.Error())
+	}
+}
+
+// End the span, failed when the error is not nil
+func End(span trace.Span, spanErr error) {
+	SetError(span, spanErr)
+	span.End()
+}
+
+// Middleware starts the span of every request, continuing the trace of the traceparent header
+// when the request carries one. The span is named after the route template, it wraps the router
+// so the requests no route matches are traced too.
+func Middleware(router *mux.Router, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+
+		route := common.MatchRouteTemplate(router, r)
+		ctx, span := otel.Tracer(TRACER_NAME).Start(ctx, r.Method+" "+route,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(semconv.HTTPMethod(r.Method), semconv.HTTPRoute(route), semconv.HTTPTarget(r.URL.RequestURI())),
+		)
+		defer span.End()
+
+		recorder := common.NewStatusRecorder(rw)
+		next.ServeHTTP(recorder, r.WithContext(ctx))
+
+		// Client errors are answered as expected, only server errors fail the span
+		span.SetAttributes(semconv.HTTPStatusCode(recorder.Status))
+		if recorder.Status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(recorder.Status))
+		}
+	})
+}